@@ -1592,6 +1592,19 @@ func (c *config) HWASanEnabledForPath(path string) bool {
 	return HasAnyPrefix(path, c.productVariables.HWASanIncludePaths)
 }
 
+// IncludeLayeringCheckEnabledForPath returns true if C/C++ modules in the given directory should
+// report the headers they include from libraries that are not direct dependencies.
+func (c *config) IncludeLayeringCheckEnabledForPath(path string) bool {
+	return HasAnyPrefix(path, c.productVariables.IncludeLayeringCheckPaths) ||
+		c.IncludeLayeringCheckEnforcedForPath(path)
+}
+
+// IncludeLayeringCheckEnforcedForPath returns true if C/C++ modules in the given directory must
+// not include headers from libraries that are not direct dependencies.
+func (c *config) IncludeLayeringCheckEnforcedForPath(path string) bool {
+	return HasAnyPrefix(path, c.productVariables.IncludeLayeringCheckEnforcePaths)
+}
+
 func (c *config) VendorConfig(name string) VendorConfig {
 	return soongconfig.Config(c.productVariables.VendorVars[name])
 }
@@ -348,6 +348,9 @@ type productVariables struct {
 
 	HWASanIncludePaths []string `json:",omitempty"`
 
+	IncludeLayeringCheckPaths        []string `json:",omitempty"`
+	IncludeLayeringCheckEnforcePaths []string `json:",omitempty"`
+
 	VendorPath    *string `json:",omitempty"`
 	OdmPath       *string `json:",omitempty"`
 	ProductPath   *string `json:",omitempty"`
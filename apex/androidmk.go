@@ -379,6 +379,13 @@ func (a *apexBundle) androidMkForType() android.AndroidMkData {
 						goal, a.installedFilesFile.String(), distFile)
 					fmt.Fprintf(w, "$(call declare-0p-target,%s)\n", a.installedFilesFile.String())
 				}
+				if a.sizeReportFile != nil {
+					goal := "checkbuild"
+					distFile := name + "-size-report.json"
+					fmt.Fprintf(w, "$(call dist-for-goals,%s,%s:%s)\n",
+						goal, a.sizeReportFile.String(), distFile)
+					fmt.Fprintf(w, "$(call declare-0p-target,%s)\n", a.sizeReportFile.String())
+				}
 				for _, dist := range data.Entries.GetDistForGoals(a) {
 					fmt.Fprintf(w, dist)
 				}
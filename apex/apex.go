@@ -201,6 +201,10 @@ type apexBundleProperties struct {
 	// in a special way that include the digest of the lib file under /lib(64)?
 	Dynamic_common_lib_apex *bool
 
+	// Size limits of this APEX. When exceeded, the build fails with a breakdown of the APEX
+	// contents by module and by file type, compared against the previous build.
+	Size_budget apexSizeBudgetProperties
+
 	// Canonical name of this APEX bundle. Used to determine the path to the
 	// activated APEX on device (i.e. /apex/<apexVariationName>), and used for the
 	// apex mutator variations. For override_apex modules, this is the name of the
@@ -277,6 +281,20 @@ func (a *ApexNativeDependencies) Merge(b ApexNativeDependencies) {
 	a.Exclude_filesystems = append(a.Exclude_filesystems, b.Exclude_filesystems...)
 }
 
+type apexSizeBudgetProperties struct {
+	// Maximum size in bytes of the signed, uncompressed APEX file.
+	Uncompressed *int64
+
+	// Maximum size in bytes of the signed, compressed APEX (.capex) file. Only allowed when the
+	// APEX is compressed.
+	Compressed *int64
+
+	// A size report of a previous build that was within the budget, e.g. the
+	// <name>-size-report.json disted by that build, checked in to the source tree. When the APEX
+	// exceeds its budget, the size breakdown by module and by file type is diffed against it.
+	Reference *string `android:"path"`
+}
+
 type apexMultilibProperties struct {
 	// Native dependencies whose compile_multilib is "first"
 	First ApexNativeDependencies
@@ -476,6 +494,10 @@ type apexBundle struct {
 	// debugging purpose.
 	installedFilesFile android.WritablePath
 
+	// JSON report of the size of this APEX and its contents. Only generated when size_budget is
+	// set.
+	sizeReportFile android.WritablePath
+
 	// List of module names that this APEX is including (to be shown via *-deps-info target).
 	// Used for debugging purpose.
 	android.ApexBundleDepsInfo
@@ -1582,6 +1604,11 @@ func (a *apexBundle) dynamic_common_lib_apex() bool {
 	return proptools.BoolDefault(a.properties.Dynamic_common_lib_apex, false)
 }
 
+// See the size_budget property
+func (a *apexBundle) hasSizeBudget() bool {
+	return a.properties.Size_budget.Uncompressed != nil || a.properties.Size_budget.Compressed != nil
+}
+
 // See the list of libs to trim
 func (a *apexBundle) libs_to_trim(ctx android.ModuleContext) []string {
 	dclaModules := ctx.GetDirectDepsWithTag(dclaTag)
@@ -9061,6 +9061,83 @@ func TestCompressedApex(t *testing.T) {
 	ensureContains(t, androidMk, "LOCAL_MODULE_STEM := myapex.capex\n")
 }
 
+func TestApexSizeBudget(t *testing.T) {
+	ctx := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			compressible: true,
+			updatable: false,
+			size_budget: {
+				uncompressed: 1048576,
+				compressed: 524288,
+				reference: "myapex-size-report.json",
+			},
+		}
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["myapex"],
+		}
+	`,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.CompressedApex = proptools.BoolPtr(true)
+		}),
+		android.FixtureAddTextFile("myapex-size-report.json", "{}"),
+	)
+
+	module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+
+	fileModules := android.ContentFromFileRuleForTests(t, module.Output("size-budget-file-modules.txt"))
+	ensureContains(t, fileModules, "lib64/mylib.so mylib SHARED_LIBRARIES")
+
+	check := module.Rule("size_budget")
+	ensureContains(t, check.RuleParams.Command, "--max-size 1048576")
+	ensureContains(t, check.RuleParams.Command, "--max-compressed-size 524288")
+	ensureContains(t, check.RuleParams.Command, "--apex out/soong/.intermediates/myapex/android_common_myapex_image/myapex.apex ")
+	ensureContains(t, check.RuleParams.Command, "--capex out/soong/.intermediates/myapex/android_common_myapex_image/myapex.capex ")
+	ensureContains(t, check.RuleParams.Command, "--reference myapex-size-report.json ")
+	android.AssertStringListContains(t, "inputs", check.Implicits.Strings(), "myapex-size-report.json")
+
+	// The installed APEX must depend on the check so that an APEX over budget fails the build.
+	install := module.Description("install myapex.capex")
+	android.AssertStringListContains(t, "install deps", install.OrderOnly.Strings(),
+		"out/soong/.intermediates/myapex/android_common_myapex_image/size-budget.timestamp")
+
+	ab := module.Module().(*apexBundle)
+	data := android.AndroidMkDataForTest(t, ctx, ab)
+	var builder strings.Builder
+	data.Custom(&builder, ab.BaseModuleName(), "TARGET_", "", data)
+	androidMk := builder.String()
+	ensureContains(t, androidMk, "size-report.json:myapex-size-report.json")
+}
+
+func TestApexSizeBudgetCompressedWithoutCompression(t *testing.T) {
+	testApexError(t, `size_budget.compressed: can only be set for a compressed APEX`, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			updatable: false,
+			size_budget: {
+				compressed: 524288,
+			},
+		}
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`)
+}
+
 func TestPreferredPrebuiltSharedLibDep(t *testing.T) {
 	ctx := testApex(t, `
 		apex {
@@ -400,6 +400,57 @@ func (a *apexBundle) buildInstalledFilesFile(ctx android.ModuleContext, builtApe
 	return output.OutputPath
 }
 
+// buildSizeBudgetCheck creates a build rule that checks the size of the signed APEX, and of the
+// compressed APEX if there is one, against the size_budget property. The rule also writes a JSON
+// report that attributes every file in the APEX to the module that provided it. When the APEX
+// exceeds its budget, the breakdown of its size is diffed against the size_budget.reference report.
+// The returned timestamp file is only written when the APEX is within its budget.
+func (a *apexBundle) buildSizeBudgetCheck(ctx android.ModuleContext, signedApex android.Path, imageDir android.Path) android.Path {
+	var fileModules []string
+	for _, fi := range a.filesInfo {
+		moduleName := fi.androidMkModuleName
+		if fi.module != nil {
+			moduleName = fi.module.Name()
+		}
+		fileModules = append(fileModules, fi.path()+" "+moduleName+" "+fi.class.nameInMake())
+	}
+	sort.Strings(fileModules)
+	fileModulesFile := android.PathForModuleOut(ctx, "size-budget-file-modules.txt")
+	android.WriteFileRule(ctx, fileModulesFile, strings.Join(fileModules, "\n"))
+
+	budget := a.properties.Size_budget
+	sizeReportFile := android.PathForModuleOut(ctx, "size-report.json")
+	timestamp := android.PathForModuleOut(ctx, "size-budget.timestamp")
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	cmd := rule.Command().
+		BuiltTool("check_apex_size").
+		FlagWithArg("--name ", a.Name()).
+		FlagWithArg("--image-dir ", imageDir.String()).
+		FlagWithInput("--file-modules ", fileModulesFile).
+		FlagWithInput("--apex ", signedApex)
+	if budget.Uncompressed != nil {
+		cmd.FlagWithArg("--max-size ", strconv.FormatInt(*budget.Uncompressed, 10))
+	}
+	if a.isCompressed {
+		cmd.FlagWithInput("--capex ", a.outputFile)
+		if budget.Compressed != nil {
+			cmd.FlagWithArg("--max-compressed-size ", strconv.FormatInt(*budget.Compressed, 10))
+		}
+	} else if budget.Compressed != nil {
+		ctx.PropertyErrorf("size_budget.compressed", "can only be set for a compressed APEX")
+	}
+	if budget.Reference != nil {
+		cmd.FlagWithInput("--reference ", android.PathForModuleSrc(ctx, *budget.Reference))
+	}
+	cmd.FlagWithOutput("--report ", sizeReportFile).
+		FlagWithOutput("--output ", timestamp)
+	rule.Build("size_budget", "Check size budget of "+a.Name())
+
+	a.sizeReportFile = sizeReportFile
+	return timestamp
+}
+
 // buildBundleConfig creates a build rule for the bundle config file that will control the bundle
 // creation process.
 func (a *apexBundle) buildBundleConfig(ctx android.ModuleContext) android.OutputPath {
@@ -935,9 +986,16 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 		a.SkipInstall()
 	}
 
+	// The size budget check is a dependency of the installed APEX so that an APEX that is over
+	// budget fails the build rather than being caught late in release.
+	installDeps := a.compatSymlinks.Paths()
+	if a.hasSizeBudget() {
+		installDeps = append(installDeps, a.buildSizeBudgetCheck(ctx, signedOutputFile, imageDir))
+	}
+
 	// Install to $OUT/soong/{target,host}/.../apex.
 	a.installedFile = ctx.InstallFile(a.installDir, a.Name()+installSuffix, a.outputFile,
-		a.compatSymlinks.Paths()...)
+		installDeps...)
 
 	// installed-files.txt is dist'ed
 	a.installedFilesFile = a.buildInstalledFilesFile(ctx, a.outputFile, imageDir)
@@ -14,16 +14,35 @@
 
 // Package bloaty implements a singleton that measures binary (e.g. ELF
 // executable, shared library or Rust rlib) section sizes at build time.
+//
+// When BLOATY_SIZE_REPORT=true, the native binaries are measured too and the
+// sizes are attributed to the modules and partitions of the files in a size
+// report. When BLOATY_SYMBOLS=true, the report includes the largest symbols of
+// each file. When BLOATY_REFERENCE_REPORT is set to the path of the report of a
+// previous build, the report starts with the largest size regressions since
+// that build.
 package bloaty
 
 import (
+	"path/filepath"
+	"sort"
+	"strings"
+
 	"android/soong/android"
 
 	"github.com/google/blueprint"
 )
 
 const bloatyDescriptorExt = ".bloaty.csv"
+const bloatySymbolsExt = ".bloaty.symbols.csv"
 const protoFilename = "binary_sizes.pb.gz"
+const modulesFilename = "binary_sizes_modules.csv"
+const reportFilename = "binary_sizes_report.json"
+const reportTextFilename = "binary_sizes_report.txt"
+
+// The number of symbols listed per file when BLOATY_SYMBOLS=true, the others
+// are listed together.
+const symbolsPerFile = "50"
 
 var (
 	fileSizeMeasurerKey blueprint.ProviderKey
@@ -36,6 +55,14 @@ var (
 			CommandDeps: []string{"${bloaty}"},
 		})
 
+	// bloatySymbols is used to measure the largest symbols of a binary, using
+	// the debug info of its unstripped version if any.
+	bloatySymbols = pctx.AndroidStaticRule("bloatySymbols",
+		blueprint.RuleParams{
+			Command:     "${bloaty} -d symbols -n " + symbolsPerFile + " --csv ${debugFile} ${in} > ${out}",
+			CommandDeps: []string{"${bloaty}"},
+		}, "debugFile")
+
 	// The bloaty merger script is used to combine the outputs from bloaty
 	// into a single protobuf.
 	bloatyMerger = pctx.AndroidStaticRule("bloatyMerger",
@@ -45,12 +72,21 @@ var (
 			Rspfile:        "${out}.lst",
 			RspfileContent: "${in}",
 		})
+
+	// The bloaty report script attributes the outputs from bloaty to modules
+	// and partitions, and diffs them against a reference report.
+	bloatyReport = pctx.AndroidStaticRule("bloatyReport",
+		blueprint.RuleParams{
+			Command:     "${bloatyReport} --modules ${in} ${reference} --json ${out} --text ${text}",
+			CommandDeps: []string{"${bloatyReport}"},
+		}, "reference", "text")
 )
 
 func init() {
 	pctx.VariableConfigMethod("hostPrebuiltTag", android.Config.PrebuiltOS)
 	pctx.SourcePathVariable("bloaty", "prebuilts/build-tools/${hostPrebuiltTag}/bin/bloaty")
 	pctx.HostBinToolVariable("bloatyMerger", "bloaty_merger")
+	pctx.HostBinToolVariable("bloatyReport", "bloaty_report")
 	android.RegisterSingletonType("file_metrics", fileSizesSingleton)
 	fileSizeMeasurerKey = blueprint.NewProvider(measuredFiles{})
 }
@@ -58,13 +94,27 @@ func init() {
 // measuredFiles contains the paths of the files measured by a module.
 type measuredFiles struct {
 	paths []android.WritablePath
+
+	// The partition the module is installed to, or "host" for host modules.
+	partition string
+}
+
+// SizeReportEnabled returns true if the size report attributing the sizes to
+// modules should be generated.
+func SizeReportEnabled(config android.Config) bool {
+	return config.IsEnvTrue("BLOATY_SIZE_REPORT")
 }
 
 // MeasureSizeForPaths should be called by binary producers to measure the
 // sizes of artifacts. It must only be called once per module; it will panic
 // otherwise.
 func MeasureSizeForPaths(ctx android.ModuleContext, paths ...android.OptionalPath) {
-	mf := measuredFiles{}
+	mf := measuredFiles{
+		partition: android.PathForModuleInstall(ctx).Partition(),
+	}
+	if ctx.Host() {
+		mf.partition = "host"
+	}
 	for _, p := range paths {
 		if !p.Valid() {
 			continue
@@ -83,12 +133,35 @@ func fileSizesSingleton() android.Singleton {
 }
 
 func (singleton *sizesSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	sizeReport := SizeReportEnabled(ctx.Config())
+	measureSymbols := sizeReport && ctx.Config().IsEnvTrue("BLOATY_SYMBOLS")
+
 	var deps android.Paths
+	var reportDeps android.Paths
+	modules := []string{"module,partition,sections,symbols"}
 	ctx.VisitAllModules(func(m android.Module) {
 		if !ctx.ModuleHasProvider(m, fileSizeMeasurerKey) {
 			return
 		}
 		filePaths := ctx.ModuleProvider(m, fileSizeMeasurerKey).(measuredFiles)
+
+		// When a file is measured along with its unstripped version, which is not
+		// installed, the debug info of the unstripped version is used to measure the
+		// symbols of the stripped file in the size report.
+		measured := make(map[string]bool)
+		for _, path := range filePaths.paths {
+			measured[path.(android.ModuleOutPath).Rel()] = true
+		}
+		debugFiles := make(map[string]android.Path)
+		isDebugFile := make(map[string]bool)
+		for _, path := range filePaths.paths {
+			filePath := path.(android.ModuleOutPath)
+			if stripped := strings.TrimPrefix(filePath.Rel(), "unstripped/"); stripped != filePath.Rel() && measured[stripped] {
+				debugFiles[stripped] = filePath
+				isDebugFile[filePath.Rel()] = true
+			}
+		}
+
 		for _, path := range filePaths.paths {
 			filePath := path.(android.ModuleOutPath)
 			sizeFile := filePath.InSameDir(ctx, filePath.Base()+bloatyDescriptorExt)
@@ -99,6 +172,37 @@ func (singleton *sizesSingleton) GenerateBuildActions(ctx android.SingletonConte
 				Output:      sizeFile,
 			})
 			deps = append(deps, sizeFile)
+
+			// The size report only attributes the files that are installed.
+			if isDebugFile[filePath.Rel()] {
+				continue
+			}
+			reportDeps = append(reportDeps, sizeFile)
+
+			symbolsFile := ""
+			if measureSymbols {
+				symbolsPath := filePath.InSameDir(ctx, filePath.Base()+bloatySymbolsExt)
+				debugFileFlag := ""
+				var implicits android.Paths
+				if debugFile, ok := debugFiles[filePath.Rel()]; ok {
+					debugFileFlag = "--debug-file=" + debugFile.String()
+					implicits = append(implicits, debugFile)
+				}
+				ctx.Build(pctx, android.BuildParams{
+					Rule:        bloatySymbols,
+					Description: "bloaty symbols " + filePath.Rel(),
+					Input:       filePath,
+					Implicits:   implicits,
+					Output:      symbolsPath,
+					Args: map[string]string{
+						"debugFile": debugFileFlag,
+					},
+				})
+				reportDeps = append(reportDeps, symbolsPath)
+				symbolsFile = symbolsPath.String()
+			}
+			modules = append(modules, strings.Join([]string{
+				ctx.ModuleName(m), filePaths.partition, sizeFile.String(), symbolsFile}, ","))
 		}
 	})
 
@@ -107,8 +211,52 @@ func (singleton *sizesSingleton) GenerateBuildActions(ctx android.SingletonConte
 		Inputs: android.SortedUniquePaths(deps),
 		Output: android.PathForOutput(ctx, protoFilename),
 	})
+
+	if !sizeReport {
+		return
+	}
+
+	sort.Strings(modules[1:])
+	modulesFile := android.PathForOutput(ctx, modulesFilename)
+	android.WriteFileRule(ctx, modulesFile, strings.Join(modules, "\n"))
+
+	reference := ""
+	if ref := ctx.Config().Getenv("BLOATY_REFERENCE_REPORT"); ref != "" {
+		if filepath.IsAbs(ref) || strings.HasPrefix(filepath.Clean(ref)+"/", ctx.Config().OutDir()+"/") {
+			// The report of a previous build is usually outside of the source tree, e.g. in
+			// its dist directory, where it can't be a dependency. Setting another reference
+			// changes the command, which still regenerates the report.
+			reference = "--reference " + ref
+		} else {
+			refPath := android.ExistentPathForSource(ctx, ref)
+			if !refPath.Valid() {
+				ctx.Errorf("BLOATY_REFERENCE_REPORT %q does not exist", ref)
+				return
+			}
+			reportDeps = append(reportDeps, refPath.Path())
+			reference = "--reference " + refPath.String()
+		}
+	}
+
+	reportText := android.PathForOutput(ctx, reportTextFilename)
+	ctx.Build(pctx, android.BuildParams{
+		Rule:           bloatyReport,
+		Description:    "bloaty report",
+		Input:          modulesFile,
+		Implicits:      android.SortedUniquePaths(reportDeps),
+		Output:         android.PathForOutput(ctx, reportFilename),
+		ImplicitOutput: reportText,
+		Args: map[string]string{
+			"reference": reference,
+			"text":      reportText.String(),
+		},
+	})
 }
 
 func (singleton *sizesSingleton) MakeVars(ctx android.MakeVarsContext) {
 	ctx.DistForGoalWithFilename("checkbuild", android.PathForOutput(ctx, protoFilename), protoFilename)
+	if SizeReportEnabled(ctx.Config()) {
+		ctx.DistForGoalWithFilename("checkbuild", android.PathForOutput(ctx, reportFilename), reportFilename)
+		ctx.DistForGoalWithFilename("checkbuild", android.PathForOutput(ctx, reportTextFilename), reportTextFilename)
+	}
 }
@@ -0,0 +1,189 @@
+# Copyright 2023 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Bloaty Size Report
+
+Attributes the sizes measured by Bloaty to modules and partitions, and
+optionally diffs them against the report of a previous build. For instance:
+
+    $ bloaty_report --modules binary_sizes_modules.csv \\
+        --reference old/binary_sizes_report.json \\
+        --json binary_sizes_report.json --text binary_sizes_report.txt
+
+The modules file is a CSV file with a module, partition, sections and symbols
+column per measured file. The sections column is the path of the Bloaty CSV
+file listing the sections of the file, and the symbols column is the path of
+the Bloaty CSV file listing its largest symbols, or empty.
+"""
+
+import argparse
+import csv
+import json
+
+BLOATY_EXTENSION = ".bloaty.csv"
+
+
+def read_sizes(path, key):
+    """Reads a Bloaty-generated CSV file.
+
+    Args:
+      path: The filepath to the CSV file.
+      key: The name of the column of the entries, e.g. sections.
+
+    Returns:
+      A dict from the entries to a dict of their file_size and vm_size.
+    """
+    sizes = {}
+    with open(path, newline='') as csv_file:
+        for row in csv.DictReader(csv_file):
+            sizes[row[key]] = {
+                "file_size": int(row["filesize"]),
+                "vm_size": int(row["vmsize"]),
+            }
+    return sizes
+
+
+def total(sizes):
+    """Returns the sum of the sizes of a dict of entries."""
+    return {
+        "file_size": sum(s["file_size"] for s in sizes.values()),
+        "vm_size": sum(s["vm_size"] for s in sizes.values()),
+    }
+
+
+def create_report(modules_file):
+    """Creates the size report from the modules file.
+
+    Returns:
+      A dict from partitions to a dict from modules to their sizes. The sizes
+      of a module are the sums of the sizes of its files, which are listed with
+      their sections and, if measured, symbols.
+    """
+    partitions = {}
+    with open(modules_file, newline='') as f:
+        for row in csv.DictReader(f):
+            sections_csv = row["sections"]
+            path = sections_csv
+            if path.endswith(BLOATY_EXTENSION):
+                path = path[: -len(BLOATY_EXTENSION)]
+            file_report = {"sections": read_sizes(sections_csv, "sections")}
+            file_report.update(total(file_report["sections"]))
+            if row["symbols"]:
+                file_report["symbols"] = read_sizes(row["symbols"], "symbols")
+
+            modules = partitions.setdefault(row["partition"], {})
+            module = modules.setdefault(row["module"], {"files": {}})
+            module["files"][path] = file_report
+
+    for modules in partitions.values():
+        for module in modules.values():
+            module.update(total(module["files"]))
+    return partitions
+
+
+def section_sizes(module):
+    """Returns the file sizes of the sections of a module, summed over its files."""
+    sizes = {}
+    for file_report in module["files"].values():
+        for name, size in file_report["sections"].items():
+            sizes[name] = sizes.get(name, 0) + size["file_size"]
+    return sizes
+
+
+def diff_reports(report, reference):
+    """Diffs the file sizes of the modules of two reports.
+
+    Returns:
+      A list of (partition, module, delta, section deltas) tuples for the
+      modules whose size changed, largest regression first. The section deltas
+      are (section, delta) tuples, largest regression first. Modules that are
+      missing from one of the reports have a size of 0 in that report.
+    """
+    empty = {"file_size": 0, "files": {}}
+    diffs = []
+    for partition in sorted(set(report) | set(reference)):
+        modules = report.get(partition, {})
+        reference_modules = reference.get(partition, {})
+        for name in sorted(set(modules) | set(reference_modules)):
+            module = modules.get(name, empty)
+            reference_module = reference_modules.get(name, empty)
+            delta = module["file_size"] - reference_module["file_size"]
+            if delta == 0:
+                continue
+            sections = section_sizes(module)
+            reference_sections = section_sizes(reference_module)
+            section_deltas = []
+            for section in sorted(set(sections) | set(reference_sections)):
+                section_delta = sections.get(section, 0) - reference_sections.get(section, 0)
+                if section_delta != 0:
+                    section_deltas.append((section, section_delta))
+            section_deltas.sort(key=lambda d: -d[1])
+            diffs.append((partition, name, delta, section_deltas))
+    diffs.sort(key=lambda d: -d[2])
+    return diffs
+
+
+def format_report(report, diffs, top):
+    """Formats the report, preceded by the largest regressions if diffed."""
+    lines = []
+    if diffs is not None:
+        regressions = [d for d in diffs if d[2] > 0][:top]
+        total_delta = sum(d[2] for d in diffs)
+        lines.append("Largest size regressions (total change: %+d bytes)" % total_delta)
+        for partition, module, delta, section_deltas in regressions:
+            lines.append("  %s/%s: %+d bytes" % (partition, module, delta))
+            for section, section_delta in section_deltas:
+                if section_delta > 0:
+                    lines.append("    %s: %+d bytes" % (section, section_delta))
+        if not regressions:
+            lines.append("  none")
+        lines.append("")
+
+    for partition in sorted(report):
+        modules = report[partition]
+        partition_size = sum(m["file_size"] for m in modules.values())
+        lines.append("%s: %d bytes in %d modules" % (partition, partition_size, len(modules)))
+        for name in sorted(modules, key=lambda n: (-modules[n]["file_size"], n)):
+            module = modules[name]
+            lines.append("  %s: %d bytes (%d bytes in memory)" % (name, module["file_size"],
+                                                                 module["vm_size"]))
+            sections = section_sizes(module)
+            for section in sorted(sections, key=lambda s: (-sections[s], s)):
+                lines.append("    %s: %d bytes" % (section, sections[section]))
+        lines.append("")
+    return "\n".join(lines)
+
+
+def main():
+    parser = argparse.ArgumentParser()
+    parser.add_argument("--modules", required=True, help="CSV file of the measured files.")
+    parser.add_argument("--reference", help="Report of a previous build to diff against.")
+    parser.add_argument("--top", type=int, default=20, help="Number of regressions to list.")
+    parser.add_argument("--json", required=True, help="Output JSON report.")
+    parser.add_argument("--text", required=True, help="Output text report.")
+    args = parser.parse_args()
+
+    report = create_report(args.modules)
+    diffs = None
+    if args.reference:
+        with open(args.reference) as f:
+            diffs = diff_reports(report, json.load(f))
+
+    with open(args.json, "w") as f:
+        json.dump(report, f, indent=2, sort_keys=True)
+    with open(args.text, "w") as f:
+        f.write(format_report(report, diffs, args.top))
+
+
+if __name__ == '__main__':
+    main()
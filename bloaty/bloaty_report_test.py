@@ -0,0 +1,107 @@
+# Copyright 2023 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+import os
+import tempfile
+import unittest
+
+import bloaty_report
+
+
+class BloatyReportTestCase(unittest.TestCase):
+    def setUp(self):
+        self.tmp = tempfile.TemporaryDirectory()
+        self.addCleanup(self.tmp.cleanup)
+
+    def create_file(self, name, contents):
+        path = os.path.join(self.tmp.name, name)
+        with open(path, "w") as f:
+            f.write(contents)
+        return path
+
+    def create_report(self):
+        libfoo64 = self.create_file("libfoo64.so.bloaty.csv",
+                                    "sections,vmsize,filesize\n.text,10,20\n.data,5,5\n")
+        libfoo32 = self.create_file("libfoo32.so.bloaty.csv",
+                                    "sections,vmsize,filesize\n.text,4,8\n")
+        symbols = self.create_file("libfoo64.so.bloaty.symbols.csv",
+                                   "symbols,vmsize,filesize\nfoo(),6,6\n")
+        libbar = self.create_file("libbar.so.bloaty.csv",
+                                  "sections,vmsize,filesize\n.text,1,2\n")
+        modules = self.create_file("modules.csv",
+                                   "module,partition,sections,symbols\n" +
+                                   "libfoo,system,%s,%s\n" % (libfoo64, symbols) +
+                                   "libfoo,system,%s,\n" % libfoo32 +
+                                   "libbar,vendor,%s,\n" % libbar)
+        return bloaty_report.create_report(modules)
+
+    def test_create_report(self):
+        report = self.create_report()
+        self.assertEqual(sorted(report), ["system", "vendor"])
+
+        libfoo = report["system"]["libfoo"]
+        self.assertEqual(libfoo["file_size"], 33)
+        self.assertEqual(libfoo["vm_size"], 19)
+        libfoo64 = libfoo["files"][os.path.join(self.tmp.name, "libfoo64.so")]
+        self.assertEqual(libfoo64["sections"][".data"], {"file_size": 5, "vm_size": 5})
+        self.assertEqual(libfoo64["symbols"]["foo()"], {"file_size": 6, "vm_size": 6})
+        self.assertNotIn("symbols", libfoo["files"][os.path.join(self.tmp.name, "libfoo32.so")])
+
+        self.assertEqual(report["vendor"]["libbar"]["file_size"], 2)
+
+    def test_diff_reports(self):
+        report = self.create_report()
+        reference = {
+            "system": {
+                "libfoo": {
+                    "file_size": 25,
+                    "files": {"libfoo.so": {"sections": {".text": {"file_size": 25}}}},
+                },
+                "libgone": {
+                    "file_size": 7,
+                    "files": {"libgone.so": {"sections": {".text": {"file_size": 7}}}},
+                },
+            },
+            "vendor": {
+                "libbar": {
+                    "file_size": 2,
+                    "files": {"libbar.so": {"sections": {".text": {"file_size": 2}}}},
+                },
+            },
+        }
+
+        diffs = bloaty_report.diff_reports(report, reference)
+        self.assertEqual(diffs, [
+            ("system", "libfoo", 8, [(".data", 5), (".text", 3)]),
+            ("system", "libgone", -7, [(".text", -7)]),
+        ])
+
+        text = bloaty_report.format_report(report, diffs, 1)
+        self.assertIn("Largest size regressions (total change: +1 bytes)\n" +
+                      "  system/libfoo: +8 bytes\n" +
+                      "    .data: +5 bytes\n" +
+                      "    .text: +3 bytes\n", text)
+        self.assertNotIn("libgone", text)
+        self.assertIn("system: 33 bytes in 1 modules\n" +
+                      "  libfoo: 33 bytes (19 bytes in memory)\n" +
+                      "    .text: 28 bytes\n" +
+                      "    .data: 5 bytes\n", text)
+
+    def test_no_reference(self):
+        text = bloaty_report.format_report(self.create_report(), None, 20)
+        self.assertNotIn("regressions", text)
+
+
+if __name__ == '__main__':
+    suite = unittest.TestLoader().loadTestsFromTestCase(BloatyReportTestCase)
+    unittest.TextTestRunner(verbosity=2).run(suite)
@@ -195,6 +195,17 @@ func (r FixRequest) AddBase() (result FixRequest) {
 	return result
 }
 
+// AddRemoveUnusedNativeDeps adds a step that removes the given unused dependencies, as reported by
+// the unused native dependency analysis of Soong.
+func (r FixRequest) AddRemoveUnusedNativeDeps(unused UnusedNativeDeps) (result FixRequest) {
+	result.steps = append([]FixStep(nil), r.steps...)
+	result.steps = append(result.steps, FixStep{
+		Name: "removeUnusedNativeDeps",
+		Fix:  removeUnusedNativeDeps(unused),
+	})
+	return result
+}
+
 func (r FixRequest) AddMatchingExtensions(pattern string) (result FixRequest) {
 	result.steps = append([]FixStep(nil), r.steps...)
 	for _, extension := range fixStepsExtensions {
@@ -803,6 +814,49 @@ func removeEmptyLibDependencies(f *Fixer) error {
 	return nil
 }
 
+// UnusedNativeDeps maps each module name to its unused dependencies keyed by property name, e.g.
+// {"libfoo": {"shared_libs": ["libbar"]}}.
+type UnusedNativeDeps map[string]map[string][]string
+
+// Removes the unused dependencies from the shared_libs and static_libs properties of the modules.
+// Only the top level properties are modified, dependencies listed in arch or target specific
+// properties or in defaults are kept.
+func removeUnusedNativeDeps(unused UnusedNativeDeps) func(f *Fixer) error {
+	return func(f *Fixer) error {
+		for _, def := range f.tree.Defs {
+			mod, ok := def.(*parser.Module)
+			if !ok {
+				continue
+			}
+			name, ok := getLiteralStringPropertyValue(mod, "name")
+			if !ok || unused[name] == nil {
+				continue
+			}
+			for _, field := range []string{"shared_libs", "static_libs"} {
+				removals := unused[name][field]
+				listValue, ok := getLiteralListProperty(mod, field)
+				if !ok || len(removals) == 0 {
+					continue
+				}
+				newValues := []parser.Expression{}
+				for _, v := range listValue.Values {
+					stringValue, ok := v.(*parser.String)
+					if ok && inList(stringValue.Value, removals) {
+						continue
+					}
+					newValues = append(newValues, v)
+				}
+				if len(newValues) == 0 && len(listValue.Values) != 0 {
+					removeProperty(mod, field)
+				} else {
+					listValue.Values = newValues
+				}
+			}
+		}
+		return nil
+	}
+}
+
 // Removes hidl_interface 'types' which are no longer needed
 func removeHidlInterfaceTypes(f *Fixer) error {
 	for _, def := range f.tree.Defs {
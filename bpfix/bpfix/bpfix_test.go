@@ -1013,6 +1013,87 @@ func TestRemoveEmptyLibDependencies(t *testing.T) {
 	}
 }
 
+func TestRemoveUnusedNativeDeps(t *testing.T) {
+	unused := UnusedNativeDeps{
+		"foo": {
+			"shared_libs": []string{"libbar"},
+			"static_libs": []string{"libbaz"},
+		},
+	}
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{
+			name: "remove sole static lib",
+			in: `
+				cc_library {
+					name: "foo",
+					static_libs: ["libbaz"],
+				}
+			`,
+			out: `
+				cc_library {
+					name: "foo",
+
+				}
+			`,
+		},
+		{
+			name: "remove a shared lib",
+			in: `
+				cc_library {
+					name: "foo",
+					shared_libs: [
+						"libbar",
+						"libfoo",
+					],
+					arch: {
+						arm: {
+							shared_libs: ["libbar"],
+						},
+					},
+				}
+			`,
+			out: `
+				cc_library {
+					name: "foo",
+					shared_libs: [
+
+						"libfoo",
+					],
+					arch: {
+						arm: {
+							shared_libs: ["libbar"],
+						},
+					},
+				}
+			`,
+		},
+		{
+			name: "other module",
+			in: `
+				cc_library {
+					name: "other",
+					shared_libs: ["libbar"],
+				}
+			`,
+			out: `
+				cc_library {
+					name: "other",
+					shared_libs: ["libbar"],
+				}
+			`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runPass(t, test.in, test.out, removeUnusedNativeDeps(unused))
+		})
+	}
+}
+
 func TestRemoveHidlInterfaceTypes(t *testing.T) {
 	tests := []struct {
 		name string
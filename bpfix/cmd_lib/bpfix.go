@@ -20,6 +20,7 @@ package cmd_lib
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -38,6 +39,10 @@ var (
 	list   = flag.Bool("l", false, "list files whose formatting differs from bpfmt's")
 	write  = flag.Bool("w", false, "write result to (source) file instead of stdout")
 	doDiff = flag.Bool("d", false, "display diffs instead of rewriting files")
+
+	// additional fixes
+	removeUnusedDeps = flag.String("remove-unused-deps", "",
+		"remove the shared_libs and static_libs listed in the given unused_native_deps.json file")
 )
 
 var (
@@ -133,6 +138,20 @@ func Run() {
 
 	fixRequest := bpfix.NewFixRequest().AddAll()
 
+	if *removeUnusedDeps != "" {
+		data, err := ioutil.ReadFile(*removeUnusedDeps)
+		if err != nil {
+			report(err)
+			return
+		}
+		var unused bpfix.UnusedNativeDeps
+		if err := json.Unmarshal(data, &unused); err != nil {
+			report(fmt.Errorf("%s: %s", *removeUnusedDeps, err))
+			return
+		}
+		fixRequest = fixRequest.AddRemoveUnusedNativeDeps(unused)
+	}
+
 	if flag.NArg() == 0 {
 		if *write {
 			fmt.Fprintln(os.Stderr, "error: cannot use -w with standard input")
@@ -99,6 +99,10 @@ func (afdo *afdo) flags(ctx ModuleContext, flags Flags) Flags {
 		pathForSrc := android.PathForSource(ctx, *path)
 		flags.CFlagsDeps = append(flags.CFlagsDeps, pathForSrc)
 		flags.LdFlagsDeps = append(flags.LdFlagsDeps, pathForSrc)
+
+		if profileStalenessReportEnabled(ctx) {
+			flags.ProfileStalenessCheck = *path
+		}
 	}
 
 	return flags
@@ -461,3 +461,74 @@ func TestAfdoDepsWithoutProfile(t *testing.T) {
 		t.Errorf("libFoo missing dependency on non-afdo variant of libBar")
 	}
 }
+
+func TestProfileStalenessReport(t *testing.T) {
+	t.Parallel()
+	bp := `
+	cc_library_shared {
+		name: "libTest",
+		srcs: ["test.c"],
+		static_libs: ["libFoo"],
+		afdo: true,
+	}
+
+	cc_library_static {
+		name: "libFoo",
+		srcs: ["foo.c", "asm.S"],
+	}
+	`
+
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithFdoProfile,
+		prepareForCcTest,
+		android.FixtureAddTextFile("afdo_profiles_package/libTest.afdo", ""),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.AfdoProfiles = []string{
+				"libTest://afdo_profiles_package:libTest_afdo",
+			}
+		}),
+		android.MockFS{
+			"afdo_profiles_package/Android.bp": []byte(`
+				fdo_profile {
+					name: "libTest_afdo",
+					profile: "libTest.afdo",
+				}
+			`),
+		}.AddToFixture(),
+		android.FixtureMergeEnv(map[string]string{
+			"PROFILE_STALENESS_REPORT":    "true",
+			"PROFILE_STALENESS_THRESHOLD": "75",
+		}),
+	).RunTestWithBp(t, bp)
+
+	libTest := result.ModuleForTests("libTest", "android_arm64_armv8-a_shared")
+	check := libTest.Output("obj/test.profile_check")
+	android.AssertStringEquals(t, "rule", ccProfileCheck.String(), check.Rule.String())
+	android.AssertStringDoesContain(t, "cFlags", check.Args["cFlags"],
+		"-fprofile-sample-use=afdo_profiles_package/libTest.afdo")
+
+	// The afdo variant of the static library is compiled with the profile of libTest, except for
+	// its assembly sources.
+	libFooAfdoVariant := result.ModuleForTests("libFoo", "android_arm64_armv8-a_static_afdo-libTest")
+	libFooAfdoVariant.Output("obj/foo.profile_check")
+	if rule := libFooAfdoVariant.MaybeOutput("obj/asm.profile_check"); rule.Rule != nil {
+		t.Errorf("Expected no profile staleness check of assembly sources")
+	}
+	libFoo := result.ModuleForTests("libFoo", "android_arm64_armv8-a_static")
+	if rule := libFoo.MaybeOutput("obj/foo.profile_check"); rule.Rule != nil {
+		t.Errorf("Expected no profile staleness check of libFoo without a profile")
+	}
+
+	singleton := result.SingletonForTests("profile_staleness_report")
+	report := singleton.Output("profile_staleness/profile_staleness_report.json")
+	android.AssertStringEquals(t, "threshold", "75", report.Args["threshold"])
+	android.AssertStringListContains(t, "report inputs", android.PathsRelativeToTop(report.Implicits),
+		"out/soong/.intermediates/libTest/android_arm64_armv8-a_shared/obj/test.profile_check")
+
+	manifest := android.StringRelativeToTop(result.Config,
+		android.ContentFromFileRuleForTests(t, singleton.Output("profile_staleness/profile_check_files.txt")))
+	android.AssertStringEquals(t, "manifest",
+		"afdo_profiles_package/libTest.afdo\tlibFoo\tout/soong/.intermediates/libFoo/android_arm64_armv8-a_static_afdo-libTest/obj/foo.profile_check\n"+
+			"afdo_profiles_package/libTest.afdo\tlibTest\tout/soong/.intermediates/libTest/android_arm64_armv8-a_shared/obj/test.profile_check",
+		manifest)
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+// This file contains the build-id debug info layout. When building with BUILD_ID_DEBUG_INFO=true,
+// stripping a binary or shared library, including Rust ones, also writes its debug info with
+// compressed sections to a separate file, which the stripped file links to with a .gnu_debuglink
+// section. The separate files are zipped at their .build-id/xx/yyyy.debug path and merged into
+// out/soong/debuginfo/build_id_symbols.zip when the build-id-symbols target is built. The zip can
+// be extracted into the debug file directory of a debugger, or served as is by a
+// debuginfod-compatible server.
+
+import (
+	"android/soong/android"
+	"github.com/google/blueprint"
+)
+
+func init() {
+	pctx.HostBinToolVariable("buildIdDebugZipCmd", "build_id_debug_zip")
+}
+
+var buildIdDebugInfoZip = pctx.AndroidStaticRule("buildIdDebugInfoZip",
+	blueprint.RuleParams{
+		Command:     "CLANG_BIN=${config.ClangBin} $buildIdDebugZipCmd --input $in --output $out",
+		CommandDeps: []string{"$buildIdDebugZipCmd"},
+	})
+
+var buildIdDebugInfoZipsKey = android.NewOnceKey("BuildIdDebugInfoZips")
+
+// buildIdDebugInfoEnabled returns true if the separate debug info files should be written.
+func buildIdDebugInfoEnabled(ctx android.PathContext) bool {
+	return ctx.Config().IsEnvTrue("BUILD_ID_DEBUG_INFO")
+}
+
+// addBuildIdDebugInfo creates the rule to zip a separate debug info file at its path in the
+// build-id tree, and adds the zip to the symbols artifact.
+func addBuildIdDebugInfo(ctx android.ModuleContext, debugFile android.ModuleOutPath) {
+	zip := android.PathForModuleOut(ctx, debugFile.Rel()+".zip")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        buildIdDebugInfoZip,
+		Description: "build-id debug info " + debugFile.Base(),
+		Output:      zip,
+		Input:       debugFile,
+	})
+	getNamedMapForConfig(ctx.Config(), buildIdDebugInfoZipsKey).Store(zip.String(), zip)
+}
+
+func buildIdDebugInfoSingletonFactory() android.Singleton {
+	return &buildIdDebugInfoSingleton{}
+}
+
+type buildIdDebugInfoSingleton struct{}
+
+// GenerateBuildActions merges the build-id debug info zips of all the modules into the symbols
+// artifact.
+func (s *buildIdDebugInfoSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !buildIdDebugInfoEnabled(ctx) {
+		return
+	}
+
+	var zips android.Paths
+	getNamedMapForConfig(ctx.Config(), buildIdDebugInfoZipsKey).Range(func(_, value interface{}) bool {
+		zips = append(zips, value.(android.Path))
+		return true
+	})
+	zips = android.SortedUniquePaths(zips)
+
+	// Variants with identical outputs have identical build IDs, so duplicate entries are expected.
+	output := android.PathForOutput(ctx, "debuginfo", "build_id_symbols.zip")
+	rspFile := android.PathForOutput(ctx, "debuginfo", "build_id_symbols.rsp")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("merge_zips").
+		Flag("-s").
+		Flag("-ignore-duplicates").
+		Output(output).
+		FlagWithRspFileInputList("@", rspFile, zips)
+	rule.Build("build_id_symbols", "merge build-id debug info")
+
+	ctx.Phony("build-id-symbols", output)
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestBuildIdDebugInfo(t *testing.T) {
+	t.Parallel()
+	bp := `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.cpp"],
+		}
+
+		cc_library {
+			name: "libbar",
+			srcs: ["bar.cpp"],
+		}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeEnv(map[string]string{
+			"BUILD_ID_DEBUG_INFO": "true",
+		}),
+	).RunTestWithBp(t, bp)
+
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a")
+	strip := foo.Output("foo")
+	android.AssertStringDoesContain(t, "strip args", strip.Args["args"],
+		"--split-debug-info=out/soong/.intermediates/foo/android_arm64_armv8-a/debuginfo/foo.debug")
+	android.AssertStringDoesNotContain(t, "strip args", strip.Args["args"], "--add-gnu-debuglink")
+	android.AssertPathsRelativeToTopEquals(t, "strip implicit outputs",
+		[]string{"out/soong/.intermediates/foo/android_arm64_armv8-a/debuginfo/foo.debug"},
+		strip.ImplicitOutputs.Paths())
+
+	fooZip := foo.Output("debuginfo/foo.debug.zip")
+	android.AssertPathRelativeToTopEquals(t, "zipped debug info",
+		"out/soong/.intermediates/foo/android_arm64_armv8-a/debuginfo/foo.debug", fooZip.Input)
+
+	libbar := result.ModuleForTests("libbar", "android_arm64_armv8-a_shared")
+	libbarZip := libbar.Output("debuginfo/libbar.so.debug.zip")
+
+	// Static libraries are not stripped into a separate debug info file.
+	if rule := result.ModuleForTests("libbar", "android_arm64_armv8-a_static").MaybeOutput("debuginfo/libbar.a.debug.zip"); rule.Rule != nil {
+		t.Errorf("expected no build-id debug info for the static variant of libbar")
+	}
+
+	merge := result.SingletonForTests("build_id_debug_info").Output("debuginfo/build_id_symbols.zip")
+	inputs := append(merge.Inputs.Strings(), merge.Implicits.Strings()...)
+	android.AssertStringListContains(t, "merged zips", inputs, fooZip.Output.String())
+	android.AssertStringListContains(t, "merged zips", inputs, libbarZip.Output.String())
+
+	// Without BUILD_ID_DEBUG_INFO, no separate debug info file is written.
+	result = prepareForCcTest.RunTestWithBp(t, bp)
+	strip = result.ModuleForTests("foo", "android_arm64_armv8-a").Output("foo")
+	android.AssertStringDoesNotContain(t, "strip args", strip.Args["args"], "--split-debug-info")
+	if rule := result.ModuleForTests("foo", "android_arm64_armv8-a").MaybeOutput("debuginfo/foo.debug.zip"); rule.Rule != nil {
+		t.Errorf("expected no build-id debug info without BUILD_ID_DEBUG_INFO")
+	}
+}
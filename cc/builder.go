@@ -50,6 +50,36 @@ var (
 		},
 		"ccCmd", "cFlags")
 
+	// Rule to precompile a C++ header with given command and flags. Outputs a .d depfile.
+	ccPch = pctx.AndroidRemoteStaticRule("ccPch", android.RemoteRuleSupports{Goma: true, RBE: true},
+		blueprint.RuleParams{
+			Depfile:     "${out}.d",
+			Deps:        blueprint.DepsGCC,
+			Command:     "$relPwd ${config.CcWrapper}$ccCmd -x c++-header $cFlags -MD -MF ${out}.d -o $out $in",
+			CommandDeps: []string{"$ccCmd"},
+		},
+		"ccCmd", "cFlags")
+
+	// Rule to list the include tree of a source file for the include layering check. clang -H
+	// prints every header, including the skipped ones, with one dot per level of nesting, so that
+	// the check can tell the headers included by the module itself from those included by the
+	// headers of its dependencies.
+	ccIncludeDeps = pctx.AndroidStaticRule("ccIncludeDeps",
+		blueprint.RuleParams{
+			Command:     "echo $in > $out && $relPwd $ccCmd $cFlags -E -w -H -fshow-skipped-includes -o /dev/null $in 2>> $out",
+			CommandDeps: []string{"$ccCmd"},
+		},
+		"ccCmd", "cFlags")
+
+	// Rule to compile a source again with the diagnostics that report how much of its AFDO or PGO
+	// profile still applies, keeping them for the profile staleness report.
+	ccProfileCheck = pctx.AndroidStaticRule("ccProfileCheck",
+		blueprint.RuleParams{
+			Command:     "$relPwd $ccCmd -c $cFlags $profileCheckFlags -o /dev/null $in 2> $out",
+			CommandDeps: []string{"$ccCmd"},
+		},
+		"ccCmd", "cFlags")
+
 	// Rule to invoke gcc with given command and flags, but no dependencies.
 	ccNoDeps = pctx.AndroidStaticRule("ccNoDeps",
 		blueprint.RuleParams{
@@ -383,6 +413,16 @@ type builderFlags struct {
 	sAbiDump      bool
 	emitXrefs     bool
 
+	includeLayeringCheck bool
+
+	// True if the staleness of the AFDO or PGO profile of the sources should be checked.
+	profileStalenessCheck bool
+
+	// True if the ThinLTO backend compiles of the link run as separate actions.
+	distributedThinLTO bool
+	// The object files of the static libraries linked with distributed ThinLTO, by archive path.
+	thinLTOArchiveObjs map[string]android.Paths
+
 	assemblerWithCpp bool // True if .s files should be processed with the c preprocessor.
 
 	systemIncludeFlags string
@@ -393,6 +433,8 @@ type builderFlags struct {
 
 	yacc *YaccProperties
 	lex  *LexProperties
+
+	pch android.Path // Header to precompile for the C++ sources, if any.
 }
 
 // StripFlags represents flags related to stripping. This is separate from builderFlags, as these
@@ -415,6 +457,10 @@ type Objects struct {
 	coverageFiles android.Paths
 	sAbiDumpFiles android.Paths
 	kytheFiles    android.Paths
+
+	includeDepsFiles android.Paths // headers included by each source, for the layering check
+
+	profileCheckFiles android.Paths // profile diagnostics of each source, for the staleness check
 }
 
 func (a Objects) Copy() Objects {
@@ -425,6 +471,10 @@ func (a Objects) Copy() Objects {
 		coverageFiles: append(android.Paths{}, a.coverageFiles...),
 		sAbiDumpFiles: append(android.Paths{}, a.sAbiDumpFiles...),
 		kytheFiles:    append(android.Paths{}, a.kytheFiles...),
+
+		includeDepsFiles: append(android.Paths{}, a.includeDepsFiles...),
+
+		profileCheckFiles: append(android.Paths{}, a.profileCheckFiles...),
 	}
 }
 
@@ -436,6 +486,10 @@ func (a Objects) Append(b Objects) Objects {
 		coverageFiles: append(a.coverageFiles, b.coverageFiles...),
 		sAbiDumpFiles: append(a.sAbiDumpFiles, b.sAbiDumpFiles...),
 		kytheFiles:    append(a.kytheFiles, b.kytheFiles...),
+
+		includeDepsFiles: append(a.includeDepsFiles, b.includeDepsFiles...),
+
+		profileCheckFiles: append(a.profileCheckFiles, b.profileCheckFiles...),
 	}
 }
 
@@ -469,6 +523,14 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 	if flags.emitXrefs {
 		kytheFiles = make(android.Paths, 0, len(srcFiles))
 	}
+	var includeDepsFiles android.Paths
+	if flags.includeLayeringCheck {
+		includeDepsFiles = make(android.Paths, 0, len(srcFiles))
+	}
+	var profileCheckFiles android.Paths
+	if flags.profileStalenessCheck {
+		profileCheckFiles = make(android.Paths, 0, len(srcFiles))
+	}
 
 	// Produce fully expanded flags for use by C tools, C compiles, C++ tools, C++ compiles, and asm compiles
 	// respectively.
@@ -560,6 +622,11 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		return "$" + kind + n
 	}
 
+	// The precompiled header, if any, is built along with the first C++ source. All the C++
+	// sources are compiled with the same flags, which the header is precompiled with too, as
+	// clang rejects a precompiled header built with incompatible flags.
+	var pchFile android.WritablePath
+
 	for i, srcFile := range srcFiles {
 		objFile := android.ObjPathWithExt(ctx, subdir, srcFile, "o")
 
@@ -594,6 +661,8 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		dump := flags.sAbiDump
 		rule := cc
 		emitXref := flags.emitXrefs
+		includeLayeringCheck := flags.includeLayeringCheck
+		profileCheck := flags.profileStalenessCheck
 
 		switch srcFile.Ext() {
 		case ".s":
@@ -608,6 +677,8 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			coverage = false
 			dump = false
 			emitXref = false
+			includeLayeringCheck = false
+			profileCheck = false
 		case ".c":
 			ccCmd = "clang"
 			moduleFlags = cflags
@@ -635,6 +706,29 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			ccCmd = "${config.ClangBin}/" + ccCmd
 		}
 
+		var pchFlags, pchIncludeFlags string
+		var implicits android.Paths
+		if flags.pch != nil && ccDesc == "clang++" && srcFile.Ext() != ".mm" {
+			if pchFile == nil {
+				pchFile = android.ObjPathWithExt(ctx, subdir, flags.pch, "pch")
+				ctx.Build(pctx, android.BuildParams{
+					Rule:        ccPch,
+					Description: "precompile header " + flags.pch.Rel(),
+					Output:      pchFile,
+					Input:       flags.pch,
+					Implicits:   cFlagsDeps,
+					OrderOnly:   pathDeps,
+					Args: map[string]string{
+						"cFlags": shareFlags("cFlags", moduleFlags+extraFlags),
+						"ccCmd":  ccCmd,
+					},
+				})
+			}
+			pchFlags = " -include-pch " + pchFile.String()
+			implicits = append(implicits, pchFile)
+			pchIncludeFlags = " -include " + flags.pch.String()
+		}
+
 		var implicitOutputs android.WritablePaths
 		if coverage {
 			gcnoFile := android.ObjPathWithExt(ctx, subdir, srcFile, "gcno")
@@ -648,14 +742,19 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			Output:          objFile,
 			ImplicitOutputs: implicitOutputs,
 			Input:           srcFile,
-			Implicits:       cFlagsDeps,
+			Implicits:       append(implicits, cFlagsDeps...),
 			OrderOnly:       pathDeps,
 			Args: map[string]string{
-				"cFlags": shareFlags("cFlags", moduleFlags+extraFlags),
+				"cFlags": shareFlags("cFlags", moduleFlags+extraFlags) + pchFlags,
 				"ccCmd":  ccCmd, // short and not shared
 			},
 		})
 
+		// The tools include the header itself instead of the precompiled header, which they
+		// may not be able to read.
+		moduleFlags += pchIncludeFlags
+		moduleToolingFlags += pchIncludeFlags
+
 		// Register post-process build statements (such as for tidy or kythe).
 		if emitXref {
 			kytheFile := android.ObjPathWithExt(ctx, subdir, srcFile, "kzip")
@@ -711,6 +810,40 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			})
 		}
 
+		if includeLayeringCheck {
+			includeDepsFile := android.ObjPathWithExt(ctx, subdir, srcFile, "incdeps")
+			includeDepsFiles = append(includeDepsFiles, includeDepsFile)
+			ctx.Build(pctx, android.BuildParams{
+				Rule:        ccIncludeDeps,
+				Description: "list includes " + srcFile.Rel(),
+				Output:      includeDepsFile,
+				Input:       srcFile,
+				Implicits:   cFlagsDeps,
+				OrderOnly:   pathDeps,
+				Args: map[string]string{
+					"cFlags": shareFlags("cFlags", moduleFlags),
+					"ccCmd":  ccCmd,
+				},
+			})
+		}
+
+		if profileCheck {
+			profileCheckFile := android.ObjPathWithExt(ctx, subdir, srcFile, "profile_check")
+			profileCheckFiles = append(profileCheckFiles, profileCheckFile)
+			ctx.Build(pctx, android.BuildParams{
+				Rule:        ccProfileCheck,
+				Description: "profile staleness check " + srcFile.Rel(),
+				Output:      profileCheckFile,
+				Input:       srcFile,
+				Implicits:   cFlagsDeps,
+				OrderOnly:   pathDeps,
+				Args: map[string]string{
+					"cFlags": shareFlags("cFlags", moduleFlags+extraFlags),
+					"ccCmd":  ccCmd,
+				},
+			})
+		}
+
 		if dump {
 			sAbiDumpFile := android.ObjPathWithExt(ctx, subdir, srcFile, "sdump")
 			sAbiDumpFiles = append(sAbiDumpFiles, sAbiDumpFile)
@@ -747,6 +880,10 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		coverageFiles: coverageFiles,
 		sAbiDumpFiles: sAbiDumpFiles,
 		kytheFiles:    kytheFiles,
+
+		includeDepsFiles: includeDepsFiles,
+
+		profileCheckFiles: profileCheckFiles,
 	}
 }
 
@@ -803,6 +940,42 @@ func transformObjToDynamicBinary(ctx android.ModuleContext,
 	groupLate bool, flags builderFlags, outputFile android.WritablePath,
 	implicitOutputs android.WritablePaths, validations android.Paths) {
 
+	if flags.distributedThinLTO {
+		transformObjToDynamicBinaryDistributedThinLTO(ctx, objFiles, sharedLibs, staticLibs,
+			lateStaticLibs, wholeStaticLibs, deps, crtBegin, crtEnd, groupLate, flags, outputFile,
+			implicitOutputs, validations)
+		return
+	}
+
+	args, deps := dynamicBinaryLinkArgs(ctx, sharedLibs, staticLibs, lateStaticLibs, wholeStaticLibs,
+		deps, crtBegin, crtEnd, groupLate, flags)
+
+	rule := ld
+	if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_CXX_LINKS") {
+		rule = ldRE
+		args["implicitOutputs"] = strings.Join(implicitOutputs.Strings(), ",")
+		args["implicitInputs"] = strings.Join(deps.Strings(), ",")
+	}
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:            rule,
+		Description:     "link " + outputFile.Base(),
+		Output:          outputFile,
+		ImplicitOutputs: implicitOutputs,
+		Inputs:          objFiles,
+		Implicits:       deps,
+		OrderOnly:       sharedLibs,
+		Validations:     validations,
+		Args:            args,
+	})
+}
+
+// dynamicBinaryLinkArgs returns the arguments of the ld rule to link a binary or shared library
+// against the given libraries, and the files the link depends on.
+func dynamicBinaryLinkArgs(ctx android.ModuleContext,
+	sharedLibs, staticLibs, lateStaticLibs, wholeStaticLibs, deps, crtBegin, crtEnd android.Paths,
+	groupLate bool, flags builderFlags) (map[string]string, android.Paths) {
+
 	var ldCmd string
 	var extraFlags string
 	if flags.sdclang {
@@ -852,7 +1025,6 @@ func transformObjToDynamicBinary(ctx android.ModuleContext,
 	deps = append(deps, crtBegin...)
 	deps = append(deps, crtEnd...)
 
-	rule := ld
 	args := map[string]string{
 		"ldCmd":         ldCmd,
 		"crtBegin":      strings.Join(crtBegin.Strings(), " "),
@@ -861,23 +1033,7 @@ func transformObjToDynamicBinary(ctx android.ModuleContext,
 		"ldFlags":       flags.globalLdFlags + " " + flags.localLdFlags + " " + extraFlags,
 		"crtEnd":        strings.Join(crtEnd.Strings(), " "),
 	}
-	if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_CXX_LINKS") {
-		rule = ldRE
-		args["implicitOutputs"] = strings.Join(implicitOutputs.Strings(), ",")
-		args["implicitInputs"] = strings.Join(deps.Strings(), ",")
-	}
-
-	ctx.Build(pctx, android.BuildParams{
-		Rule:            rule,
-		Description:     "link " + outputFile.Base(),
-		Output:          outputFile,
-		ImplicitOutputs: implicitOutputs,
-		Inputs:          objFiles,
-		Implicits:       deps,
-		OrderOnly:       sharedLibs,
-		Validations:     validations,
-		Args:            args,
-	})
+	return args, deps
 }
 
 // Generate a rule to combine .dump sAbi dump files from multiple source files
@@ -1047,8 +1203,10 @@ func transformBinaryPrefixSymbols(ctx android.ModuleContext, prefix string, inpu
 }
 
 // Registers a build statement to invoke `strip` (to discard symbols and data from object files).
+// If debugFile is not nil, the debug info is also written to it, and the stripped file links to it
+// with a .gnu_debuglink section.
 func transformStrip(ctx android.ModuleContext, inputFile android.Path,
-	outputFile android.WritablePath, flags StripFlags) {
+	outputFile android.WritablePath, debugFile android.WritablePath, flags StripFlags) {
 
 	args := ""
 	if flags.StripAddGnuDebuglink {
@@ -1066,12 +1224,18 @@ func transformStrip(ctx android.ModuleContext, inputFile android.Path,
 	if flags.StripKeepSymbolsAndDebugFrame {
 		args += " --keep-symbols-and-debug-frame"
 	}
+	var implicitOutputs android.WritablePaths
+	if debugFile != nil {
+		args += " --split-debug-info=" + debugFile.String()
+		implicitOutputs = append(implicitOutputs, debugFile)
+	}
 
 	ctx.Build(pctx, android.BuildParams{
-		Rule:        strip,
-		Description: "strip " + outputFile.Base(),
-		Output:      outputFile,
-		Input:       inputFile,
+		Rule:            strip,
+		Description:     "strip " + outputFile.Base(),
+		Output:          outputFile,
+		ImplicitOutputs: implicitOutputs,
+		Input:           inputFile,
 		Args: map[string]string{
 			"args": args,
 		},
@@ -29,6 +29,7 @@ import (
 
 	"android/soong/android"
 	"android/soong/bazel/cquery"
+	"android/soong/bloaty"
 	"android/soong/cc/config"
 	"android/soong/fuzz"
 	"android/soong/genrule"
@@ -83,6 +84,11 @@ func RegisterCCBuildComponents(ctx android.RegistrationContext) {
 	})
 
 	ctx.RegisterSingletonType("kythe_extract_all", kytheExtractAllFactory)
+	ctx.RegisterSingletonType("unused_native_deps", unusedNativeDepsSingletonFactory)
+	ctx.RegisterSingletonType("build_id_debug_info", buildIdDebugInfoSingletonFactory)
+	ctx.RegisterSingletonType("sanitizer_report", sanitizerReportSingletonFactory)
+	ctx.RegisterSingletonType("profile_staleness_report", profileStalenessSingletonFactory)
+	ctx.RegisterSingletonType("symbols_map", symbolsMapSingletonFactory)
 }
 
 // Deps is a struct containing module names of dependencies, separated by the kind of dependency.
@@ -159,6 +165,10 @@ type PathDeps struct {
 	// the libs from all whole_static_lib dependencies.
 	WholeStaticLibsFromPrebuilts android.Paths
 
+	// Paths to the .o files of the static libraries by the path of their .a file, when the module
+	// is linked with distributed ThinLTO.
+	ThinLTOArchiveObjs map[string]android.Paths
+
 	// Paths to generated source files
 	GeneratedSources android.Paths
 	GeneratedDeps    android.Paths
@@ -226,6 +236,13 @@ type Flags struct {
 	SAbiDump      bool // True if header abi dumps should be generated.
 	EmitXrefs     bool // If true, generate Ninja rules to generate emitXrefs input files for Kythe
 
+	IncludeLayeringCheck bool // True if the includes of the sources should be checked.
+
+	ProfileStalenessCheck string // The AFDO or PGO profile whose staleness should be checked, if any.
+
+	DistributedThinLTO bool                     // True if the link should use distributed ThinLTO.
+	ThinLTOArchiveObjs map[string]android.Paths // Object files of the static libraries, by archive path.
+
 	// The instruction set required for clang ("arm" or "thumb").
 	RequiredInstructionSet string
 	// The target-device system path to the dynamic linker.
@@ -243,6 +260,8 @@ type Flags struct {
 
 	Yacc *YaccProperties
 	Lex  *LexProperties
+
+	Pch android.Path // Header to precompile for the C++ sources, if any.
 }
 
 // Properties used to compile all C or C++ modules
@@ -882,6 +901,12 @@ type Module struct {
 	makeLinkType string
 	// Kythe (source file indexer) paths for this compilation module
 	kytheFiles android.Paths
+	// Include layering check report for this compilation module, if enabled
+	includeLayeringReport android.Path
+	// Profile staleness check outputs of the sources of this compilation module, if enabled
+	profileCheckFiles android.Paths
+	// Unused native dependency report for this linked module, if enabled
+	unusedNativeDepsReport android.Path
 	// Object .o file output paths for this compilation module
 	objFiles android.Paths
 	// Tidy .tidy file output paths for this compilation module
@@ -2075,6 +2100,9 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 	}
 
 	flags.Local.LdFlags = append(flags.Local.LdFlags, deps.LdFlags...)
+	if flags.DistributedThinLTO {
+		flags.ThinLTOArchiveObjs = deps.ThinLTOArchiveObjs
+	}
 
 	c.flags = flags
 	// We need access to all the flags seen by a source file.
@@ -2083,6 +2111,7 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 	}
 
 	flags.AssemblerWithCpp = inList("-xassembler-with-cpp", flags.Local.AsFlags)
+	flags.IncludeLayeringCheck = includeLayeringCheckEnabled(ctx)
 
 	var objs Objects
 	if c.compiler != nil {
@@ -2090,7 +2119,11 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 		if ctx.Failed() {
 			return
 		}
+		if len(objs.includeDepsFiles) > 0 {
+			c.includeLayeringReport = c.buildIncludeLayeringCheck(ctx, objs.includeDepsFiles)
+		}
 		c.kytheFiles = objs.kytheFiles
+		c.profileCheckFiles = objs.profileCheckFiles
 		c.objFiles = objs.objFiles
 		c.tidyFiles = objs.tidyFiles
 	}
@@ -2102,6 +2135,25 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 		}
 		c.outputFile = android.OptionalPathForPath(outputFile)
 
+		if bloaty.SizeReportEnabled(ctx.Config()) && c.measuredForSizeReport(ctx, apexInfo) {
+			// With BLOATY_SYMBOLS, the unstripped file is passed too so that the symbols of the
+			// stripped file can be attributed with its debug info.
+			var unstripped android.OptionalPath
+			if ctx.Config().IsEnvTrue("BLOATY_SYMBOLS") {
+				unstripped = android.OptionalPathForPath(c.linker.unstrippedOutputFilePath())
+				if unstripped.Valid() && unstripped.Path().String() == outputFile.String() {
+					unstripped = android.OptionalPath{}
+				}
+			}
+			bloaty.MeasureSizeForPaths(ctx, c.outputFile, unstripped)
+		}
+
+		if unusedNativeDepsEnabled(ctx) && !c.static() {
+			if unstripped := c.linker.unstrippedOutputFilePath(); unstripped != nil {
+				c.unusedNativeDepsReport = c.buildUnusedNativeDepsReport(ctx, unstripped)
+			}
+		}
+
 		c.maybeUnhideFromMake()
 
 		// glob exported headers for snapshot, if BOARD_VNDK_VERSION is current or
@@ -2132,6 +2184,24 @@ func (c *Module) maybeUnhideFromMake() {
 	}
 }
 
+// measuredForSizeReport returns true if the size of the module counts towards the size of its
+// partition in the native size report, i.e. if it is a binary or shared library that is installed
+// on the device. APEX variants are measured as part of the APEX rather than the partition. The
+// vendor and product variants of a module that has a core variant too are only installed when a
+// product asks for them, which is not known here, so only the core variant is measured.
+func (c *Module) measuredForSizeReport(ctx ModuleContext, apexInfo android.ApexInfo) bool {
+	if !ctx.Device() || c.IsStubs() || !(c.Binary() || c.library != nil && c.library.shared()) {
+		return false
+	}
+	if !apexInfo.IsForPlatform() || c.IsSkipInstall() || !installable(c, apexInfo) {
+		return false
+	}
+	if (c.InVendor() || c.InProduct()) && c.HasNonSystemVariants() {
+		return false
+	}
+	return true
+}
+
 // maybeInstall is called at the end of both GenerateAndroidBuildActions and
 // ProcessBazelQueryResponse to run the install hooks for installable modules,
 // like binaries and tests.
@@ -3177,6 +3247,15 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 
 				staticLibraryInfo := ctx.OtherModuleProvider(dep, StaticLibraryInfoProvider).(StaticLibraryInfo)
 				linkFile = android.OptionalPathForPath(staticLibraryInfo.StaticLibrary)
+				// The objects of a static library don't include the members of the prebuilt
+				// archives it whole links, so those libraries are linked from the archive.
+				if c.lto.DistributedThinLTO() && len(staticLibraryInfo.Objects.objFiles) > 0 &&
+					len(staticLibraryInfo.WholeStaticLibsFromPrebuilts) == 0 {
+					if depPaths.ThinLTOArchiveObjs == nil {
+						depPaths.ThinLTOArchiveObjs = make(map[string]android.Paths)
+					}
+					depPaths.ThinLTOArchiveObjs[staticLibraryInfo.StaticLibrary.String()] = staticLibraryInfo.Objects.objFiles
+				}
 				if libDepTag.wholeStatic {
 					ptr = &depPaths.WholeStaticLibs
 					if len(staticLibraryInfo.Objects.objFiles) > 0 {
@@ -3603,6 +3682,8 @@ func (c *Module) OutputFiles(tag string) (android.Paths, error) {
 			return android.PathsIfNonNil(c.linker.unstrippedOutputFilePath()), nil
 		}
 		return nil, nil
+	case "include_layering":
+		return android.PathsIfNonNil(c.includeLayeringReport), nil
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
@@ -26,6 +26,7 @@ import (
 
 	"android/soong/android"
 	"android/soong/bazel/cquery"
+	"android/soong/bloaty"
 )
 
 func init() {
@@ -5049,3 +5050,139 @@ func TestDclaLibraryInApex(t *testing.T) {
 	expectedOutputFiles := []string{"outputbase/execroot/__main__/foo.so"}
 	android.AssertDeepEquals(t, "output files", expectedOutputFiles, outputFiles.Strings())
 }
+
+func TestBinarySizes(t *testing.T) {
+	t.Parallel()
+	bp := `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.cpp"],
+		}
+
+		cc_library {
+			name: "libbar",
+			srcs: ["bar.cpp"],
+			vendor: true,
+		}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		bloaty.PrepareForTestWithBloatyDefaultModules,
+		android.FixtureAddTextFile("sizes/reference.json", "{}"),
+		android.FixtureMergeEnv(map[string]string{
+			"BLOATY_SIZE_REPORT":      "true",
+			"BLOATY_SYMBOLS":          "true",
+			"BLOATY_REFERENCE_REPORT": "sizes/reference.json",
+		}),
+	).RunTestWithBp(t, bp)
+
+	metrics := result.SingletonForTests("file_metrics")
+	fooDir := "out/soong/.intermediates/foo/android_arm64_armv8-a/"
+	metrics.Output(fooDir + "foo.bloaty.csv")
+	// The unstripped file is measured, but in the report it only provides the debug info of the
+	// symbols.
+	metrics.Output(fooDir + "unstripped/foo.bloaty.csv")
+	symbols := metrics.Output(fooDir + "foo.bloaty.symbols.csv")
+	android.AssertStringEquals(t, "symbols debug file", "--debug-file="+fooDir+"unstripped/foo",
+		symbols.Args["debugFile"])
+
+	libbarDir := "out/soong/.intermediates/libbar/android_vendor.29_arm64_armv8-a_shared/"
+	modules := android.ContentFromFileRuleForTests(t, metrics.Output("binary_sizes_modules.csv"))
+	android.AssertStringDoesContain(t, "modules", modules,
+		"foo,system,"+fooDir+"foo.bloaty.csv,"+fooDir+"foo.bloaty.symbols.csv")
+	android.AssertStringDoesContain(t, "modules", modules,
+		"libbar,vendor,"+libbarDir+"libbar.so.bloaty.csv,"+libbarDir+"libbar.so.bloaty.symbols.csv")
+	android.AssertStringDoesNotContain(t, "modules", modules, "unstripped")
+
+	report := metrics.Output("binary_sizes_report.json")
+	android.AssertStringEquals(t, "reference", "--reference sizes/reference.json", report.Args["reference"])
+
+	// Static libraries are not measured.
+	if rule := result.SingletonForTests("file_metrics").MaybeOutput(
+		"out/soong/.intermediates/libbar/android_vendor.29_arm64_armv8-a_static/libbar.a.bloaty.csv"); rule.Rule != nil {
+		t.Errorf("expected no size measurement for the static variant of libbar")
+	}
+
+	// A reference outside of the source tree is passed as is.
+	result = android.GroupFixturePreparers(
+		prepareForCcTest,
+		bloaty.PrepareForTestWithBloatyDefaultModules,
+		android.FixtureMergeEnv(map[string]string{
+			"BLOATY_SIZE_REPORT":      "true",
+			"BLOATY_REFERENCE_REPORT": "/dist/binary_sizes_report.json",
+		}),
+	).RunTestWithBp(t, bp)
+	report = result.SingletonForTests("file_metrics").Output("binary_sizes_report.json")
+	android.AssertStringEquals(t, "reference", "--reference /dist/binary_sizes_report.json",
+		report.Args["reference"])
+
+	android.GroupFixturePreparers(
+		prepareForCcTest,
+		bloaty.PrepareForTestWithBloatyDefaultModules,
+		android.FixtureMergeEnv(map[string]string{
+			"BLOATY_SIZE_REPORT":      "true",
+			"BLOATY_REFERENCE_REPORT": "sizes/missing.json",
+		}),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`BLOATY_REFERENCE_REPORT "sizes/missing.json" does not exist`)).
+		RunTestWithBp(t, bp)
+
+	// Without BLOATY_SIZE_REPORT native modules are not measured and there is no report.
+	result = android.GroupFixturePreparers(
+		prepareForCcTest,
+		bloaty.PrepareForTestWithBloatyDefaultModules,
+	).RunTestWithBp(t, bp)
+	metrics = result.SingletonForTests("file_metrics")
+	if rule := metrics.MaybeOutput(fooDir + "foo.bloaty.csv"); rule.Rule != nil {
+		t.Errorf("expected no size measurement for foo without BLOATY_SIZE_REPORT")
+	}
+	if rule := metrics.MaybeOutput("binary_sizes_report.json"); rule.Rule != nil {
+		t.Errorf("expected no size report without BLOATY_SIZE_REPORT")
+	}
+}
+
+func TestBinarySizesInstalledVariants(t *testing.T) {
+	t.Parallel()
+	bp := `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.cpp"],
+		}
+
+		cc_binary {
+			name: "uninstallable",
+			srcs: ["foo.cpp"],
+			installable: false,
+		}
+
+		cc_library_shared {
+			name: "libvendoravailable",
+			srcs: ["foo.cpp"],
+			vendor_available: true,
+		}
+	`
+	prepareForSizeReport := android.GroupFixturePreparers(
+		prepareForCcTest,
+		bloaty.PrepareForTestWithBloatyDefaultModules,
+		android.FixtureMergeEnv(map[string]string{
+			"BLOATY_SIZE_REPORT": "true",
+		}),
+	)
+
+	result := prepareForSizeReport.RunTestWithBp(t, bp)
+	modules := android.ContentFromFileRuleForTests(t,
+		result.SingletonForTests("file_metrics").Output("binary_sizes_modules.csv"))
+	android.AssertStringDoesContain(t, "installed binary", modules, "foo,system,")
+	android.AssertStringDoesContain(t, "core variant", modules, "libvendoravailable,system,")
+	android.AssertStringDoesNotContain(t, "vendor variant", modules, "libvendoravailable,vendor,")
+	android.AssertStringDoesNotContain(t, "uninstallable binary", modules, "uninstallable")
+
+	// APEX variants are measured as part of their APEX rather than the partition.
+	result = android.GroupFixturePreparers(
+		prepareForSizeReport,
+		android.FixtureRegisterWithContext(registerTestMutators),
+	).RunTestWithBp(t, bp)
+	modules = android.ContentFromFileRuleForTests(t,
+		result.SingletonForTests("file_metrics").Output("binary_sizes_modules.csv"))
+	android.AssertStringDoesNotContain(t, "apex variant", modules, "foo,")
+}
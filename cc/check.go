@@ -40,6 +40,12 @@ func CheckBadCompilerFlags(ctx BaseModuleContext, prop string, flags []string) {
 			ctx.PropertyErrorf(prop, "Bad flag: `%s`, use native_coverage instead", flag)
 		} else if flag == "-fwhole-program-vtables" {
 			ctx.PropertyErrorf(prop, "Bad flag: `%s`, use whole_program_vtables instead", flag)
+		} else if flag == "-include-pch" || strings.HasPrefix(flag, "-include-pch ") {
+			ctx.PropertyErrorf(prop, "Bad flag: `%s`, use pch instead", flag)
+		} else if flag == "-fno-validate-pch" || flag == "-Xclang -fno-validate-pch" {
+			// Precompiled headers are only safe to use because clang rejects those that were
+			// built with flags that are incompatible with the compile.
+			ctx.PropertyErrorf(prop, "Bad flag: `%s`, precompiled headers must be validated", flag)
 		} else if flag == "-Weverything" {
 			if !ctx.Config().IsEnvTrue("ANDROID_TEMPORARILY_ALLOW_WEVERYTHING") {
 				ctx.PropertyErrorf(prop, "-Weverything is not allowed in Android.bp files.  "+
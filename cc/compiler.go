@@ -49,6 +49,13 @@ type BaseCompilerProperties struct {
 	// This is most useful in the arch/multilib variants to remove non-common files
 	Exclude_srcs []string `android:"path,arch_variant"`
 
+	// header file to precompile once per variant with the flags of the module. The precompiled
+	// header is passed with -include-pch to every C++ compile, which behaves as if each C++ source
+	// started with #include of the header. Useful for modules whose sources all include heavy
+	// headers. The header should only include headers that are used by most of the sources, and
+	// must not depend on macros that are defined differently between sources.
+	Pch *string `android:"path,arch_variant"`
+
 	// list of module-specific flags that will be used for C and C++ compiles.
 	Cflags []string `android:"arch_variant"`
 
@@ -379,6 +386,16 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags, deps
 	flags.Yacc = compiler.Properties.Yacc
 	flags.Lex = compiler.Properties.Lex
 
+	if compiler.Properties.Pch != nil {
+		pch := android.PathForModuleSrc(ctx, *compiler.Properties.Pch)
+		switch pch.Ext() {
+		case ".h", ".hh", ".hpp", ".hxx":
+			flags.Pch = pch
+		default:
+			ctx.PropertyErrorf("pch", "%s is not a header file", pch)
+		}
+	}
+
 	// Include dir cflags
 	localIncludeDirs := android.PathsForModuleSrc(ctx, compiler.Properties.Local_include_dirs)
 	if len(localIncludeDirs) > 0 {
@@ -15,6 +15,7 @@
 package cc
 
 import (
+	"strings"
 	"testing"
 
 	"android/soong/android"
@@ -44,3 +45,149 @@ func TestIsThirdParty(t *testing.T) {
 		}
 	}
 }
+
+func TestPch(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"foo/pch.h":   nil,
+			"foo/foo.cpp": nil,
+			"foo/bar.c":   nil,
+		}),
+	).RunTestWithBp(t, `
+		cc_defaults {
+			name: "pch_defaults",
+			pch: "foo/pch.h",
+		}
+
+		cc_library_static {
+			name: "libfoo",
+			defaults: ["pch_defaults"],
+			srcs: ["foo/foo.cpp", "foo/bar.c"],
+			cflags: ["-DFOO"],
+		}
+	`)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_static")
+
+	pch := libfoo.Output("obj/foo/pch.pch")
+	android.AssertPathRelativeToTopEquals(t, "pch input", "foo/pch.h", pch.Input)
+
+	// The header is precompiled with the same flags as the C++ sources.
+	cpp := libfoo.Output("obj/foo/foo.o")
+	android.AssertStringEquals(t, "c++ flags",
+		pch.Args["cFlags"]+" -include-pch out/soong/.intermediates/libfoo/android_arm64_armv8-a_static/obj/foo/pch.pch",
+		cpp.Args["cFlags"])
+	android.AssertPathsRelativeToTopEquals(t, "c++ implicits",
+		[]string{"out/soong/.intermediates/libfoo/android_arm64_armv8-a_static/obj/foo/pch.pch"},
+		cpp.Implicits)
+
+	c := libfoo.Output("obj/foo/bar.o")
+	android.AssertStringDoesNotContain(t, "c flags", c.Args["cFlags"], "-include-pch")
+}
+
+func TestPchErrors(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name  string
+		bp    string
+		error string
+	}{
+		{
+			name: "not a header",
+			bp: `
+				cc_library_static {
+					name: "libfoo",
+					srcs: ["foo.cpp"],
+					pch: "foo.cpp",
+				}`,
+			error: "pch: foo.cpp is not a header file",
+		},
+		{
+			name: "validation disabled",
+			bp: `
+				cc_library_static {
+					name: "libfoo",
+					srcs: ["foo.cpp"],
+					cppflags: ["-fno-validate-pch"],
+				}`,
+			error: "precompiled headers must be validated",
+		},
+		{
+			name: "include-pch",
+			bp: `
+				cc_library_static {
+					name: "libfoo",
+					srcs: ["foo.cpp"],
+					cflags: ["-include-pch"],
+				}`,
+			error: "use pch instead",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prepareForCcTest.
+				ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(tc.error)).
+				RunTestWithBp(t, tc.bp)
+		})
+	}
+}
+
+func TestIncludeLayeringCheck(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.IncludeLayeringCheckEnforcePaths = []string{"foo"}
+		}),
+		android.FixtureAddTextFile("foo/Android.bp", `
+			cc_library_shared {
+				name: "libfoo",
+				srcs: ["foo.cpp", "asm.S"],
+				include_dirs: ["external/qux"],
+				shared_libs: ["libbar"],
+			}
+		`),
+		android.FixtureAddTextFile("bar/Android.bp", `
+			cc_library_shared {
+				name: "libbar",
+				srcs: ["bar.cpp"],
+				export_include_dirs: ["include"],
+				shared_libs: ["libbaz"],
+				export_shared_lib_headers: ["libbaz"],
+			}
+		`),
+		android.FixtureAddTextFile("baz/Android.bp", `
+			cc_library_shared {
+				name: "libbaz",
+				srcs: ["baz.cpp"],
+				export_include_dirs: ["include"],
+			}
+		`),
+	).RunTest(t)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+
+	dirs := android.ContentFromFileRuleForTests(t, libfoo.Output("include_layering/dirs.txt"))
+	for _, line := range []string{
+		"own\tfoo",
+		"include_dirs\texternal/qux",
+		"allowed\tbar/include",
+		"transitive\tbaz/include\tlibbaz\tlibbar",
+	} {
+		android.AssertStringListContains(t, "include layering dirs", strings.Split(dirs, "\n"), line)
+	}
+
+	check := libfoo.Output("include_layering/report.txt")
+	android.AssertStringEquals(t, "enforced", "--enforce", check.Args["enforce"])
+	android.AssertPathsRelativeToTopEquals(t, "include deps",
+		[]string{"out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/obj/foo/foo.incdeps"},
+		check.Inputs)
+
+	// Modules outside of the configured directories are not checked.
+	libbar := result.ModuleForTests("libbar", "android_arm64_armv8-a_shared")
+	if rule := libbar.MaybeOutput("include_layering/report.txt"); rule.Rule != nil {
+		t.Errorf("expected no include layering check for libbar")
+	}
+}
@@ -233,6 +233,19 @@ type Coverage interface {
 	EnableCoverageIfNeeded()
 }
 
+// CoverageInstrumented is an interface for modules whose output may be built with coverage
+// instrumentation, so that reports can include their binaries.
+type CoverageInstrumented interface {
+	IsCoverageInstrumented() bool
+}
+
+// IsCoverageInstrumented returns true if the module is compiled with coverage instrumentation.
+func (c *Module) IsCoverageInstrumented() bool {
+	return c.coverage != nil && c.coverage.Properties.CoverageEnabled
+}
+
+var _ CoverageInstrumented = (*Module)(nil)
+
 func coverageMutator(mctx android.BottomUpMutatorContext) {
 	if c, ok := mctx.Module().(*Module); ok && c.coverage != nil {
 		needCoverageVariant := c.coverage.Properties.NeedCoverageVariant
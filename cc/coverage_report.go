@@ -0,0 +1,217 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+// Rules for generating llvm-cov source-based coverage reports from the profiles of native test
+// runs, for both C/C++ and Rust tests.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+func init() {
+	registerNativeCoverageReportBuildComponents(android.InitRegistrationContext)
+
+	pctx.HostBinToolVariable("nativeCoverageReportCmd", "native_coverage_report")
+}
+
+func registerNativeCoverageReportBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterModuleType("native_coverage_report", NativeCoverageReportFactory)
+}
+
+var PrepareForTestWithNativeCoverageReport = android.FixtureRegisterWithContext(registerNativeCoverageReportBuildComponents)
+
+var nativeCoverageReportTestTag = dependencyTag{name: "native-coverage-report-test"}
+
+// The default locations of the profiles, relative to the directory of the module.
+var nativeCoverageReportDefaultProfiles = []string{"coverage/**/*.profraw", "coverage/**/*.profdata"}
+
+var nativeCoverageReport = pctx.AndroidStaticRule("nativeCoverageReport",
+	blueprint.RuleParams{
+		Command: "CLANG_BIN=${config.ClangBin} $nativeCoverageReportCmd $binaries " +
+			"--profdata $profdata --lcov $lcov --json $jsonReport --html $out $in",
+		CommandDeps: []string{"$nativeCoverageReportCmd"},
+	}, "binaries", "profdata", "lcov", "jsonReport")
+
+type nativeCoverageReportProperties struct {
+	// The native test modules whose coverage is reported, either cc_test or rust_test modules.
+	// The report includes the test binaries and all the shared libraries they depend on that are
+	// built with coverage instrumentation, i.e. whose paths are listed in NATIVE_COVERAGE_PATHS.
+	Tests []string
+
+	// The .profraw or .profdata files produced by running the tests. The profiles from all test
+	// runs are merged before generating the report. Defaults to "coverage/**/*.profraw" and
+	// "coverage/**/*.profdata", so the profiles pulled from each test run can simply be dropped into
+	// a coverage directory next to the Android.bp file.
+	Profiles []string `android:"path"`
+}
+
+type nativeCoverageReportModule struct {
+	android.ModuleBase
+
+	properties nativeCoverageReportProperties
+
+	// The merged profile of all the test runs.
+	profdata android.Path
+
+	// The zip containing the HTML report.
+	htmlReport android.Path
+
+	// The LCOV report.
+	lcovReport android.Path
+
+	// The JSON report exported by llvm-cov.
+	jsonReport android.Path
+}
+
+// native_coverage_report generates LCOV, JSON and HTML llvm-cov source-based coverage reports for
+// a set of native tests from the profiles produced by running them. The source paths in the
+// reports are relative to the root of the source tree.
+//
+// The module depends on the coverage variants of the tests, so the reports can only be built with
+// CLANG_COVERAGE=true and NATIVE_COVERAGE_PATHS covering the code under test.
+func NativeCoverageReportFactory() android.Module {
+	module := &nativeCoverageReportModule{}
+	module.AddProperties(&module.properties)
+	android.InitAndroidArchModule(module, android.DeviceSupported, android.MultilibFirst)
+	return module
+}
+
+var _ UseCoverage = (*nativeCoverageReportModule)(nil)
+
+// IsNativeCoverageNeeded makes the module depend on the coverage variants of the tests.
+func (r *nativeCoverageReportModule) IsNativeCoverageNeeded(ctx android.BaseModuleContext) bool {
+	return ctx.Device() && ctx.DeviceConfig().NativeCoverageEnabled()
+}
+
+func (r *nativeCoverageReportModule) DepsMutator(ctx android.BottomUpMutatorContext) {
+	ctx.AddVariationDependencies(nil, nativeCoverageReportTestTag, r.properties.Tests...)
+}
+
+func (r *nativeCoverageReportModule) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	profdata := android.PathForModuleOut(ctx, ctx.ModuleName()+".profdata")
+	htmlReport := android.PathForModuleOut(ctx, ctx.ModuleName()+"-html.zip")
+	lcovReport := android.PathForModuleOut(ctx, ctx.ModuleName()+".lcov")
+	jsonReport := android.PathForModuleOut(ctx, ctx.ModuleName()+".json")
+	r.profdata = profdata
+	r.htmlReport = htmlReport
+	r.lcovReport = lcovReport
+	r.jsonReport = jsonReport
+
+	profilesProperty := r.properties.Profiles
+	if profilesProperty == nil {
+		profilesProperty = nativeCoverageReportDefaultProfiles
+	}
+	profiles := android.PathsForModuleSrc(ctx, profilesProperty)
+
+	var binaries android.Paths
+	var uninstrumented []string
+
+	ctx.WalkDeps(func(child, parent android.Module) bool {
+		tag := ctx.OtherModuleDependencyTag(child)
+		isTest := parent == ctx.Module() && tag == nativeCoverageReportTestTag
+		if !isTest && !IsSharedDepTag(tag) {
+			return false
+		}
+		dep, ok := child.(LinkableInterface)
+		if !ok {
+			if isTest {
+				ctx.PropertyErrorf("tests", "%q is not a native module", ctx.OtherModuleName(child))
+			}
+			return false
+		}
+		if cov, ok := child.(CoverageInstrumented); ok && cov.IsCoverageInstrumented() {
+			if unstripped := dep.UnstrippedOutputFile(); unstripped != nil {
+				binaries = append(binaries, unstripped)
+			}
+		} else if isTest {
+			uninstrumented = append(uninstrumented, ctx.OtherModuleName(child))
+		}
+		return true
+	})
+
+	if ctx.Failed() {
+		return
+	}
+
+	// Whether a test is instrumented depends on the environment of the build, so report problems
+	// when the report is built rather than failing the whole build.
+	var errorMessage string
+	if !ctx.DeviceConfig().ClangCoverageEnabled() {
+		errorMessage = fmt.Sprintf("%s: native coverage is not enabled, build with CLANG_COVERAGE=true and NATIVE_COVERAGE_PATHS",
+			ctx.ModuleName())
+	} else if len(uninstrumented) > 0 {
+		errorMessage = fmt.Sprintf("%s: tests %q are not instrumented, add their paths to NATIVE_COVERAGE_PATHS",
+			ctx.ModuleName(), uninstrumented)
+	} else if len(profiles) == 0 {
+		errorMessage = fmt.Sprintf("%s: no profiles found in %q", ctx.ModuleName(), profilesProperty)
+	}
+	if errorMessage != "" {
+		ctx.Build(pctx, android.BuildParams{
+			Rule:    android.ErrorRule,
+			Outputs: android.WritablePaths{profdata, htmlReport, lcovReport, jsonReport},
+			Args: map[string]string{
+				"error": errorMessage,
+			},
+		})
+		return
+	}
+
+	binaries = android.FirstUniquePaths(binaries)
+	binaryArgs := make([]string, 0, len(binaries))
+	for _, binary := range binaries {
+		binaryArgs = append(binaryArgs, "--binary "+binary.String())
+	}
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:            nativeCoverageReport,
+		Description:     "native coverage report " + ctx.ModuleName(),
+		Output:          htmlReport,
+		ImplicitOutputs: android.WritablePaths{profdata, lcovReport, jsonReport},
+		Inputs:          profiles,
+		Implicits:       binaries,
+		Args: map[string]string{
+			"binaries":   strings.Join(binaryArgs, " "),
+			"profdata":   profdata.String(),
+			"lcov":       lcovReport.String(),
+			"jsonReport": jsonReport.String(),
+		},
+	})
+}
+
+// OutputFiles returns the reports, or the merged profile for the ".profdata" tag.
+func (r *nativeCoverageReportModule) OutputFiles(tag string) (android.Paths, error) {
+	switch tag {
+	case "":
+		return android.Paths{r.htmlReport, r.lcovReport, r.jsonReport}, nil
+	case ".html":
+		return android.Paths{r.htmlReport}, nil
+	case ".lcov":
+		return android.Paths{r.lcovReport}, nil
+	case ".json":
+		return android.Paths{r.jsonReport}, nil
+	case ".profdata":
+		return android.Paths{r.profdata}, nil
+	default:
+		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
+	}
+}
+
+var _ android.OutputFileProducer = (*nativeCoverageReportModule)(nil)
@@ -0,0 +1,83 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+const nativeCoverageReportBp = `
+	cc_library_shared {
+		name: "libfoo",
+		srcs: ["foo.cpp"],
+	}
+
+	cc_test {
+		name: "foo_test",
+		srcs: ["foo_test.cpp"],
+		shared_libs: ["libfoo"],
+		gtest: false,
+	}
+
+	native_coverage_report {
+		name: "foo_coverage",
+		tests: ["foo_test"],
+	}
+`
+
+var prepareForNativeCoverageReportTest = android.GroupFixturePreparers(
+	prepareForCcTest,
+	PrepareForTestWithNativeCoverageReport,
+	android.FixtureMergeMockFs(android.MockFS{
+		"coverage/run1/default.profraw": nil,
+		"coverage/run2/foo.profdata":    nil,
+	}),
+)
+
+func TestNativeCoverageReport(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForNativeCoverageReportTest,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.ClangCoverage = BoolPtr(true)
+			variables.Native_coverage = BoolPtr(true)
+			variables.NativeCoveragePaths = []string{"*"}
+		}),
+	).RunTestWithBp(t, nativeCoverageReportBp)
+
+	report := result.ModuleForTests("foo_coverage", "android_arm64_armv8-a_cov").Output("foo_coverage-html.zip")
+	android.AssertStringEquals(t, "rule", nativeCoverageReport.String(), report.Rule.String())
+	android.AssertPathsRelativeToTopEquals(t, "profiles",
+		[]string{"coverage/run1/default.profraw", "coverage/run2/foo.profdata"}, report.Inputs)
+
+	test := result.ModuleForTests("foo_test", "android_arm64_armv8-a_cov").Module().(*Module)
+	lib := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared_cov").Module().(*Module)
+	android.AssertDeepEquals(t, "binaries",
+		android.Paths{test.UnstrippedOutputFile(), lib.UnstrippedOutputFile()}, report.Implicits)
+	android.AssertStringEquals(t, "binaries arg",
+		"--binary "+test.UnstrippedOutputFile().String()+" --binary "+lib.UnstrippedOutputFile().String(),
+		report.Args["binaries"])
+	android.AssertStringDoesContain(t, "lcov report", report.Args["lcov"], "foo_coverage.lcov")
+}
+
+func TestNativeCoverageReportNotEnabled(t *testing.T) {
+	result := prepareForNativeCoverageReportTest.RunTestWithBp(t, nativeCoverageReportBp)
+
+	// Coverage depends on the environment, so the failure is deferred to build time.
+	report := result.ModuleForTests("foo_coverage", "android_arm64_armv8-a").Output("foo_coverage.lcov")
+	android.AssertStringEquals(t, "rule", android.ErrorRule.String(), report.Rule.String())
+	android.AssertStringDoesContain(t, "error", report.Args["error"], "native coverage is not enabled")
+}
@@ -0,0 +1,149 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+// This file contains the exported symbol ABI check, a cheap alternative to the header ABI checker
+// in sabi.go for shared libraries that it does not cover. It compares the dynamic symbol table of
+// the linked library with checked-in .abi.txt files.
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"android/soong/android"
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+)
+
+func init() {
+	pctx.HostBinToolVariable("abiSymbolsCmd", "abi_symbols")
+}
+
+var exportedSymbolsAbiDump = pctx.AndroidStaticRule("exportedSymbolsAbiDump",
+	blueprint.RuleParams{
+		Command:     "CLANG_BIN=${config.ClangBin} $abiSymbolsCmd dump --input $in --output $out",
+		CommandDeps: []string{"$abiSymbolsCmd"},
+	})
+
+type exportedSymbolsAbiProperties struct {
+	// Compare the exported symbols of the shared library, with their versions and the sizes of
+	// data symbols, with checked-in .abi.txt files, and fail the build when they differ. Run
+	// `m <module name>-update-abi-txt` to create or update the files.
+	Enabled *bool
+
+	// Directory of the checked-in .abi.txt files, relative to the module directory. The file of a
+	// variant is <ref_dir>/<arch>/<library name>.abi.txt, or
+	// <ref_dir>/{vendor,product}/<arch>/<library name>.abi.txt for vendor and product variants.
+	// Defaults to "abi".
+	Ref_dir *string
+}
+
+// exportedSymbolsAbiImage returns the subdirectory of the .abi.txt files of the image of the
+// variant, and false if the variant is not checked.
+func exportedSymbolsAbiImage(ctx ModuleContext) (string, bool) {
+	// Only check the variants installed on the platform partitions, which each have their own
+	// .abi.txt file so that updating them does not race.
+	if !ctx.Device() || !ctx.isForPlatform() || ctx.inRamdisk() || ctx.inVendorRamdisk() || ctx.inRecovery() {
+		return "", false
+	}
+	if ctx.inVendor() {
+		return "vendor", true
+	} else if ctx.inProduct() {
+		return "product", true
+	}
+	return "", true
+}
+
+// checkExportedSymbolsAbi creates the rules to compare the exported symbols of the shared library
+// with the checked-in .abi.txt file, and to update the file.
+func (library *libraryDecorator) checkExportedSymbolsAbi(ctx ModuleContext, soFile android.Path) {
+	props := library.Properties.Exported_symbols_abi
+	if !proptools.Bool(props.Enabled) || library.buildStubs() {
+		return
+	}
+	image, ok := exportedSymbolsAbiImage(ctx)
+	if !ok {
+		return
+	}
+
+	libName := library.getLibName(ctx)
+	abiFileName := libName + ".abi.txt"
+	refDir := filepath.Join(proptools.StringDefault(props.Ref_dir, "abi"), image, ctx.Arch().ArchType.String())
+
+	dump := android.PathForModuleOut(ctx, "exported_symbols_abi", abiFileName)
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        exportedSymbolsAbiDump,
+		Description: "dump exported symbols " + libName,
+		Output:      dump,
+		Input:       soFile,
+	})
+
+	updateTarget := ctx.ModuleName() + "-update-abi-txt"
+	refFile := android.ExistentPathForSource(ctx, ctx.ModuleDir(), refDir, abiFileName)
+	refFilePath := filepath.Join(ctx.ModuleDir(), refDir, abiFileName)
+
+	checkTimestamp := android.PathForModuleOut(ctx, "exported_symbols_abi", "check.timestamp")
+	rule := android.NewRuleBuilder(pctx, ctx)
+
+	rule.Command().Text("( true")
+
+	cmd := rule.Command().
+		BuiltTool("abi_symbols").
+		Text("check").
+		FlagWithArg("--library ", libName)
+	if refFile.Valid() {
+		cmd.FlagWithInput("--reference ", refFile.Path())
+	}
+	cmd.FlagWithInput("--current ", dump)
+
+	msg := fmt.Sprintf(`\n******************************\n`+
+		`The exported symbols of %s differ from %s.\n`+
+		`Removing or changing exported symbols breaks the modules that use the library.\n\n`+
+		`If the changes are intended, update the .abi.txt files by running:\n`+
+		`   m %s\n`+
+		`******************************\n`, libName, refFilePath, updateTarget)
+
+	rule.Command().
+		Text("touch").Output(checkTimestamp).
+		Text(") || (").
+		Text("echo").Flag("-e").Flag(`"` + msg + `"`).
+		Text("; exit 1").
+		Text(")")
+
+	rule.Build("exportedSymbolsAbiCheck", "check exported symbols "+libName)
+
+	// The droidcore phony target depends on the check so that the exported symbols cannot be
+	// changed accidentally.
+	checkTarget := ctx.ModuleName() + "-check-abi-txt"
+	ctx.Phony(checkTarget, checkTimestamp)
+	ctx.Phony("droidcore", android.PathForPhony(ctx, checkTarget))
+
+	updateTimestamp := android.PathForModuleOut(ctx, "exported_symbols_abi", "update.timestamp")
+	rule = android.NewRuleBuilder(pctx, ctx)
+
+	rule.Command().
+		Text("mkdir").Flag("-p").Flag(filepath.Dir(refFilePath))
+
+	rule.Command().
+		Text("cp").Flag("-f").
+		Input(dump).Flag(refFilePath)
+
+	rule.Command().
+		Text("touch").Output(updateTimestamp)
+
+	rule.Build("exportedSymbolsAbiUpdate", "update exported symbols "+libName)
+
+	ctx.Phony(updateTarget, updateTimestamp)
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestExportedSymbolsAbi(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddTextFile("abi/arm64/libfoo.abi.txt", ""),
+	).RunTestWithBp(t, `
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.cpp"],
+			exported_symbols_abi: {
+				enabled: true,
+			},
+		}
+
+		cc_library_shared {
+			name: "libbar",
+			srcs: ["bar.cpp"],
+		}
+	`)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+
+	dump := libfoo.Output("exported_symbols_abi/libfoo.abi.txt")
+	android.AssertPathRelativeToTopEquals(t, "dumped library",
+		"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/unstripped/libfoo.so", dump.Input)
+
+	check := libfoo.Output("exported_symbols_abi/check.timestamp")
+	android.AssertStringDoesContain(t, "check command", check.RuleParams.Command,
+		"check --library libfoo --reference abi/arm64/libfoo.abi.txt "+
+			"--current out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/exported_symbols_abi/libfoo.abi.txt")
+	android.AssertStringDoesContain(t, "check message", check.RuleParams.Command,
+		"m libfoo-update-abi-txt")
+
+	update := libfoo.Output("exported_symbols_abi/update.timestamp")
+	android.AssertStringDoesContain(t, "update command", update.RuleParams.Command,
+		"cp -f out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/exported_symbols_abi/libfoo.abi.txt "+
+			"abi/arm64/libfoo.abi.txt")
+
+	// A missing .abi.txt file fails the check until it is created by the update target.
+	libfooArm := result.ModuleForTests("libfoo", "android_arm_armv7-a-neon_shared")
+	checkArm := libfooArm.Output("exported_symbols_abi/check.timestamp")
+	android.AssertStringDoesNotContain(t, "check command without reference",
+		checkArm.RuleParams.Command, "--reference")
+	updateArm := libfooArm.Output("exported_symbols_abi/update.timestamp")
+	android.AssertStringDoesContain(t, "update command without reference", updateArm.RuleParams.Command,
+		"mkdir -p abi/arm")
+
+	// Static variants and libraries that did not opt in are not checked.
+	if rule := result.ModuleForTests("libfoo", "android_arm64_armv8-a_static").MaybeOutput("exported_symbols_abi/check.timestamp"); rule.Rule != nil {
+		t.Errorf("expected no exported symbols ABI check for the static variant of libfoo")
+	}
+	if rule := result.ModuleForTests("libbar", "android_arm64_armv8-a_shared").MaybeOutput("exported_symbols_abi/check.timestamp"); rule.Rule != nil {
+		t.Errorf("expected no exported symbols ABI check for libbar")
+	}
+}
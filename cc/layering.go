@@ -0,0 +1,169 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+// This file contains the include layering check, which verifies that a module only includes
+// headers exported by its direct dependencies. Exported include directories are re-exported
+// transitively, e.g. with export_shared_lib_headers, and include_dirs can point anywhere, which
+// lets a module include headers of libraries that it does not depend on. Such includes break when
+// the dependencies of the libraries are refactored.
+//
+// The check is enabled per directory with the IncludeLayeringCheckPaths product variable, which
+// writes a report for each module, or with IncludeLayeringCheckEnforcePaths, which fails the
+// build on violations.
+//
+// The include tree of each source is listed by clang -H, as the depfiles of the compile rules
+// are consumed by ninja and do not say which file included a header. Only the headers included
+// by the sources and headers of the module itself are checked; the headers that a dependency
+// includes from its own headers are up to the dependency. Each header is attributed to the include
+// directory that contains it, which is either allowed, i.e. a directory of the module or one
+// exported by a direct dependency that owns it, or a violation, i.e. one that a direct dependency
+// re-exports for another library, or one from include_dirs.
+
+import (
+	"strings"
+
+	"android/soong/android"
+	"github.com/google/blueprint"
+)
+
+func init() {
+	pctx.HostBinToolVariable("checkIncludeLayeringCmd", "check_include_layering")
+}
+
+var checkIncludeLayering = pctx.AndroidStaticRule("checkIncludeLayering",
+	blueprint.RuleParams{
+		Command:        "$checkIncludeLayeringCmd --module $module --dirs $dirs --output $out $enforce @$out.rsp",
+		CommandDeps:    []string{"$checkIncludeLayeringCmd"},
+		Rspfile:        "$out.rsp",
+		RspfileContent: "$in",
+	},
+	"module", "dirs", "enforce")
+
+// includeLayeringCheckEnabled returns true if the include layering check applies to the module.
+func includeLayeringCheckEnabled(ctx ModuleContext) bool {
+	return ctx.Config().IncludeLayeringCheckEnabledForPath(ctx.ModuleDir())
+}
+
+// includeLayeringDirs returns the include directories that belong to the module itself and those
+// that come from include_dirs.
+func (compiler *baseCompiler) includeLayeringDirs(ctx ModuleContext) (own, includeDirs android.Paths) {
+	if compiler.includeBuildDirectory() {
+		own = append(own, android.PathForModuleSrc(ctx))
+	}
+	own = append(own, android.PathsForModuleSrc(ctx, compiler.Properties.Local_include_dirs)...)
+	includeDirs = android.PathsForSource(ctx, compiler.Properties.Include_dirs)
+	return own, includeDirs
+}
+
+// exportedIncludeDirs returns the include directories exported by a dependency.
+func exportedIncludeDirs(info FlagExporterInfo) android.Paths {
+	return append(append(android.Paths(nil), info.IncludeDirs...), info.SystemIncludeDirs...)
+}
+
+// buildIncludeLayeringCheck creates the rule that checks the include trees listed in the given
+// files by clang -H against the include directories of the module and of its dependencies, and returns the
+// report.
+func (c *Module) buildIncludeLayeringCheck(ctx ModuleContext, includeDepsFiles android.Paths) android.Path {
+	var lines []string
+	addDirs := func(kind string, dirs android.Paths, extra ...string) {
+		for _, dir := range dirs {
+			lines = append(lines, strings.Join(append([]string{kind, dir.String()}, extra...), "\t"))
+		}
+	}
+
+	if compiler, ok := c.compiler.(interface {
+		includeLayeringDirs(ModuleContext) (android.Paths, android.Paths)
+	}); ok {
+		own, includeDirs := compiler.includeLayeringDirs(ctx)
+		addDirs("own", own)
+		addDirs("include_dirs", includeDirs)
+	}
+	if exporter, ok := c.linker.(interface {
+		exportedIncludes(ModuleContext) android.Paths
+	}); ok {
+		addDirs("own", exporter.exportedIncludes(ctx))
+	}
+
+	// A directory exported by a module is owned by it unless one of its own dependencies exports
+	// the directory too, i.e. the module re-exports it.
+	exported := make(map[android.Module]android.Paths)
+	exportedByDeps := make(map[android.Module]map[string]bool)
+	var owners []android.Module
+	ctx.WalkDeps(func(child, parent android.Module) bool {
+		if !ctx.OtherModuleHasProvider(child, FlagExporterInfoProvider) {
+			return false
+		}
+		dirs := exportedIncludeDirs(ctx.OtherModuleProvider(child, FlagExporterInfoProvider).(FlagExporterInfo))
+		if exportedByDeps[parent] == nil {
+			exportedByDeps[parent] = make(map[string]bool)
+		}
+		for _, dir := range dirs {
+			exportedByDeps[parent][dir.String()] = true
+		}
+		if _, visited := exported[child]; visited {
+			return false
+		}
+		exported[child] = dirs
+		owners = append(owners, child)
+		return true
+	})
+	ownerOf := make(map[string]string)
+	for _, module := range owners {
+		for _, dir := range exported[module] {
+			if _, ok := ownerOf[dir.String()]; !ok && !exportedByDeps[module][dir.String()] {
+				ownerOf[dir.String()] = ctx.OtherModuleName(module)
+			}
+		}
+	}
+
+	ctx.VisitDirectDeps(func(dep android.Module) {
+		name := ctx.OtherModuleName(dep)
+		for _, dir := range exported[dep] {
+			if owner, ok := ownerOf[dir.String()]; !ok || owner == name {
+				addDirs("allowed", android.Paths{dir})
+			} else {
+				addDirs("transitive", android.Paths{dir}, owner, name)
+			}
+		}
+	})
+
+	dirsFile := android.PathForModuleOut(ctx, "include_layering", "dirs.txt")
+	android.WriteFileRule(ctx, dirsFile, strings.Join(android.FirstUniqueStrings(lines), "\n"))
+
+	enforce := ctx.Config().IncludeLayeringCheckEnforcedForPath(ctx.ModuleDir())
+	report := android.PathForModuleOut(ctx, "include_layering", "report.txt")
+	args := map[string]string{
+		"module": ctx.ModuleName(),
+		"dirs":   dirsFile.String(),
+	}
+	if enforce {
+		args["enforce"] = "--enforce"
+	}
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkIncludeLayering,
+		Description: "include layering check " + ctx.ModuleName(),
+		Output:      report,
+		Inputs:      includeDepsFiles,
+		Implicit:    dirsFile,
+		Args:        args,
+	})
+
+	ctx.Phony("include-layering-check", report)
+	if enforce {
+		ctx.Phony("droidcore", report)
+	}
+	return report
+}
@@ -88,6 +88,9 @@ type LibraryProperties struct {
 	// Properties for ABI compatibility checker.
 	Header_abi_checker headerAbiCheckerProperties
 
+	// Properties for the exported symbol ABI check.
+	Exported_symbols_abi exportedSymbolsAbiProperties
+
 	Target struct {
 		Vendor, Product struct {
 			// set suffix of the name of the output
@@ -1730,6 +1733,9 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 		implicitOutputs = append(implicitOutputs, importLibraryPath)
 	}
 
+	flags, whyExtractOutputs := library.addWhyExtractFlag(ctx, flags)
+	implicitOutputs = append(implicitOutputs, whyExtractOutputs...)
+
 	builderFlags := flagsToBuilderFlags(flags)
 
 	if ctx.Darwin() && deps.DarwinSecondArchOutput.Valid() {
@@ -1799,6 +1805,7 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 
 	library.coverageOutputFile = transformCoverageFilesToZip(ctx, objs, library.getLibName(ctx))
 	library.linkSAbiDumpFiles(ctx, objs, fileName, unstrippedOutputFile)
+	library.checkExportedSymbolsAbi(ctx, library.unstrippedOutputFile)
 
 	var transitiveStaticLibrariesForOrdering *android.DepSet
 	if static := ctx.GetDirectDepsWithTag(staticVariantTag); len(static) > 0 {
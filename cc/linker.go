@@ -268,6 +268,10 @@ type baseLinker struct {
 	}
 
 	sanitize *sanitize
+
+	// The file in which the link records why it extracted each archive member, for the unused
+	// native dependency analysis.
+	whyExtractFile android.OptionalPath
 }
 
 func (linker *baseLinker) appendLdflags(flags []string) {
@@ -47,6 +47,11 @@ type LTOProperties struct {
 		Never *bool `android:"arch_variant"`
 		Full  *bool `android:"arch_variant"`
 		Thin  *bool `android:"arch_variant"`
+
+		// Run the ThinLTO backend compiles of the link as separate actions instead of inside
+		// the link action, so that they can run in parallel, or remotely with
+		// RBE_THINLTO_BACKEND=true. Requires thin.
+		Distributed *bool `android:"arch_variant"`
 	} `android:"arch_variant"`
 
 	// Dep properties indicate that this module needs to be built with LTO
@@ -125,7 +130,14 @@ func (lto *lto) flags(ctx BaseModuleContext, flags Flags) Flags {
 			flags.Local.CFlags = append(flags.Local.CFlags, "-fwhole-program-vtables")
 		}
 
-		if (lto.DefaultThinLTO(ctx) || lto.ThinLTO()) && ctx.Config().IsEnvTrue("USE_THINLTO_CACHE") && lto.useClangLld(ctx) {
+		// Distributed ThinLTO relies on lld options, and on the object files of the static
+		// libraries being known, which is only the case for modules built from source.
+		if lto.DistributedThinLTO() && ctx.Device() && lto.useClangLld(ctx) {
+			flags.DistributedThinLTO = true
+		}
+
+		if (lto.DefaultThinLTO(ctx) || lto.ThinLTO()) && ctx.Config().IsEnvTrue("USE_THINLTO_CACHE") && lto.useClangLld(ctx) &&
+			!flags.DistributedThinLTO {
 			// Set appropriate ThinLTO cache policy
 			cacheDirFormat := "-Wl,--thinlto-cache-dir="
 			cacheDir := android.PathForOutput(ctx, "thinlto-cache").String()
@@ -174,6 +186,10 @@ func (lto *lto) ThinLTO() bool {
 	return lto != nil && (proptools.Bool(lto.Properties.Lto.Thin) || lto.Properties.ThinEnabled)
 }
 
+func (lto *lto) DistributedThinLTO() bool {
+	return lto.ThinLTO() && proptools.Bool(lto.Properties.Lto.Distributed)
+}
+
 func (lto *lto) Never() bool {
 	return lto != nil && (proptools.Bool(lto.Properties.Lto.Never) || lto.Properties.NoLtoEnabled)
 }
@@ -193,6 +209,9 @@ func ltoDepsMutator(mctx android.TopDownMutatorContext) {
 		if full && thin {
 			mctx.PropertyErrorf("LTO", "FullLTO and ThinLTO are mutually exclusive")
 		}
+		if m.lto != nil && proptools.Bool(m.lto.Properties.Lto.Distributed) && !thin {
+			mctx.PropertyErrorf("LTO", "Distributed requires ThinLTO")
+		}
 
 		mctx.WalkDeps(func(dep android.Module, parent android.Module) bool {
 			tag := mctx.OtherModuleDependencyTag(dep)
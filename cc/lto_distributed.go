@@ -0,0 +1,259 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+// This file contains the distributed ThinLTO link, enabled with `lto: { thin: true, distributed:
+// true }`. Instead of running the ThinLTO backend compiles inside the link action, the link is
+// split into:
+//   - an index step, which runs the linker with --thinlto-index-only to write the ThinLTO index and
+//     the list of imported modules of each object file,
+//   - a backend compile of each object file into native code, which ninja schedules in parallel and
+//     which runs remotely with RBE_THINLTO_BACKEND=true,
+//   - the final link of the native object files.
+//
+// The object files of the static libraries are linked between --start-lib and --end-lib instead
+// of as archives, so that the outputs of the index step are known when generating the build.
+// Prebuilt static libraries are still linked as archives, and any bitcode in them is compiled in
+// the final link.
+
+import (
+	"path/filepath"
+	"strings"
+
+	"android/soong/android"
+	"android/soong/remoteexec"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/pathtools"
+)
+
+var (
+	// Rule to write the ThinLTO index of a link. The linker only writes the index files of the
+	// bitcode object files, the others are created empty so that every object file has one.
+	thinLTOIndex = pctx.AndroidStaticRule("thinLTOIndex",
+		blueprint.RuleParams{
+			Command: "$ldCmd ${crtBegin} @${out}.rsp ${crtEnd} -o ${out}.unused ${ldFlags} ${extraLibFlags} " +
+				"-Wl,--thinlto-index-only=${out} -Wl,--thinlto-emit-imports-files " +
+				"-Wl,--thinlto-prefix-replace='${oldPrefix};${newPrefix}' && " +
+				"xargs touch -a < ${indexFiles}",
+			CommandDeps:    []string{"$ldCmd"},
+			Rspfile:        "${out}.rsp",
+			RspfileContent: "${in} ${libFlags}",
+		},
+		"ldCmd", "crtBegin", "libFlags", "crtEnd", "ldFlags", "extraLibFlags", "oldPrefix", "newPrefix", "indexFiles")
+
+	// Rules to compile an object file into native code with its ThinLTO index. Object files with an
+	// empty index are not bitcode, or are not used by the link, and are copied as is.
+	thinLTOBackend, thinLTOBackendRE = pctx.RemoteStaticRules("thinLTOBackend",
+		blueprint.RuleParams{
+			Command: "if [ -s ${index} ]; then " +
+				"$reTemplate$ccCmd -c -x ir -fthinlto-index=${index} ${cFlags} -o ${out} ${in}; " +
+				"else cp -f ${in} ${out}; fi",
+			CommandDeps: []string{"$ccCmd"},
+		},
+		&remoteexec.REParams{
+			Labels:       map[string]string{"type": "compile", "lang": "cpp", "compiler": "clang"},
+			ExecStrategy: "${config.REThinLTOBackendExecStrategy}",
+			Inputs:       []string{"${in}", "${index}", "$implicitInputs"},
+			// The imports file lists the other bitcode object files the backend reads.
+			RSPFiles:        []string{"${imports}"},
+			OutputFiles:     []string{"${out}"},
+			ToolchainInputs: []string{"$ccCmd"},
+			Platform:        map[string]string{remoteexec.PoolKey: "${config.RECXXPool}"},
+		}, []string{"ccCmd", "cFlags", "index"}, []string{"imports", "implicitInputs"})
+)
+
+// thinLTOObject holds the outputs of the index step for an object file of a distributed ThinLTO
+// link, and the output of its backend compile.
+type thinLTOObject struct {
+	obj     android.Path
+	index   android.WritablePath
+	imports android.WritablePath
+	native  android.WritablePath
+}
+
+// thinLTOBackendFlags returns the flags of the backend compiles, which match the code generation
+// options the linker uses for in-process ThinLTO, and the profiles they read.
+func thinLTOBackendFlags(triple string, ldFlags []string) (cFlags []string, profiles []string) {
+	// lld always places functions and data in their own sections.
+	cFlags = []string{"-target", triple, "-O2", "-ffunction-sections", "-fdata-sections"}
+	for _, flag := range ldFlags {
+		switch {
+		case strings.HasPrefix(flag, "-Wl,-mllvm,"):
+			cFlags = append(cFlags, "-mllvm", strings.TrimPrefix(flag, "-Wl,-mllvm,"))
+		case strings.HasPrefix(flag, "-Wl,-mllvm="):
+			cFlags = append(cFlags, "-mllvm", strings.TrimPrefix(flag, "-Wl,-mllvm="))
+		case strings.HasPrefix(flag, "-Wl,--lto-O"):
+			cFlags = append(cFlags, "-O"+strings.TrimPrefix(flag, "-Wl,--lto-O"))
+		case strings.HasPrefix(flag, "-Wl,-plugin-opt,O"):
+			cFlags = append(cFlags, "-O"+strings.TrimPrefix(flag, "-Wl,-plugin-opt,O"))
+		case strings.HasPrefix(flag, "-Wl,-plugin-opt,-"):
+			cFlags = append(cFlags, "-mllvm", strings.TrimPrefix(flag, "-Wl,-plugin-opt,"))
+		case strings.HasPrefix(flag, "-fprofile-sample-use="), strings.HasPrefix(flag, "-fprofile-use="):
+			cFlags = append(cFlags, flag)
+			profiles = append(profiles, flag[strings.Index(flag, "=")+1:])
+		}
+	}
+	return cFlags, profiles
+}
+
+// transformObjToDynamicBinaryDistributedThinLTO generates the rules to link a binary or shared
+// library with distributed ThinLTO. It takes the same arguments as transformObjToDynamicBinary,
+// and the object files of the static libraries in flags.thinLTOArchiveObjs.
+func transformObjToDynamicBinaryDistributedThinLTO(ctx android.ModuleContext,
+	objFiles, sharedLibs, staticLibs, lateStaticLibs, wholeStaticLibs, deps, crtBegin, crtEnd android.Paths,
+	groupLate bool, flags builderFlags, outputFile android.WritablePath,
+	implicitOutputs android.WritablePaths, validations android.Paths) {
+
+	soongOutDir := ctx.Config().SoongOutDir()
+	thinLTODir := android.PathForModuleOut(ctx, "thinlto")
+
+	// The index step writes the files of an object file at the same path relative to the
+	// thinlto directory as the object file relative to the output directory.
+	var objects []*thinLTOObject
+	objectsByPath := make(map[string]*thinLTOObject)
+	native := func(objs android.Paths) android.Paths {
+		ret := make(android.Paths, 0, len(objs))
+		for _, obj := range objs {
+			rel, err := filepath.Rel(soongOutDir, obj.String())
+			if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+				// Object files in the source tree are prebuilts, which are linked as is.
+				ret = append(ret, obj)
+				continue
+			}
+			o, ok := objectsByPath[obj.String()]
+			if !ok {
+				o = &thinLTOObject{
+					obj:     obj,
+					index:   android.PathForModuleOut(ctx, "thinlto", rel+".thinlto.bc"),
+					imports: android.PathForModuleOut(ctx, "thinlto", rel+".imports"),
+					native:  android.PathForModuleOut(ctx, "thinlto", pathtools.ReplaceExtension(rel, "native.o")),
+				}
+				objectsByPath[obj.String()] = o
+				objects = append(objects, o)
+			}
+			ret = append(ret, o.native)
+		}
+		return ret
+	}
+
+	// The object files of the whole static libraries are linked like the object files of the
+	// module, the ones of the other static libraries lazily like the members of an archive.
+	indexObjFiles := android.CopyOfPaths(objFiles)
+	nativeObjFiles := native(objFiles)
+	var archiveWholeStaticLibs android.Paths
+	for _, lib := range wholeStaticLibs {
+		if objs, ok := flags.thinLTOArchiveObjs[lib.String()]; ok {
+			indexObjFiles = append(indexObjFiles, objs...)
+			nativeObjFiles = append(nativeObjFiles, native(objs)...)
+		} else {
+			archiveWholeStaticLibs = append(archiveWholeStaticLibs, lib)
+		}
+	}
+
+	indexLibFlags := []string{flags.libFlags}
+	nativeLibFlags := []string{flags.libFlags}
+	indexDeps := android.CopyOfPaths(deps)
+	nativeDeps := android.CopyOfPaths(deps)
+	startLib := func(libs android.Paths) (archives android.Paths) {
+		for _, lib := range libs {
+			objs, ok := flags.thinLTOArchiveObjs[lib.String()]
+			if !ok {
+				archives = append(archives, lib)
+				continue
+			}
+			nativeObjs := native(objs)
+			indexLibFlags = append(indexLibFlags, "-Wl,--start-lib")
+			indexLibFlags = append(indexLibFlags, objs.Strings()...)
+			indexLibFlags = append(indexLibFlags, "-Wl,--end-lib")
+			nativeLibFlags = append(nativeLibFlags, "-Wl,--start-lib")
+			nativeLibFlags = append(nativeLibFlags, nativeObjs.Strings()...)
+			nativeLibFlags = append(nativeLibFlags, "-Wl,--end-lib")
+			indexDeps = append(indexDeps, objs...)
+			nativeDeps = append(nativeDeps, nativeObjs...)
+		}
+		return archives
+	}
+	archiveStaticLibs := startLib(staticLibs)
+	archiveLateStaticLibs := startLib(lateStaticLibs)
+
+	nativeCrtBegin := native(crtBegin)
+	nativeCrtEnd := native(crtEnd)
+
+	// The index step.
+	var indexFiles android.WritablePaths
+	for _, o := range objects {
+		indexFiles = append(indexFiles, o.index, o.imports)
+	}
+	indexFilesList := android.PathForModuleOut(ctx, "thinlto", "index_files.txt")
+	android.WriteFileRule(ctx, indexFilesList, strings.Join(indexFiles.Strings(), "\n"))
+
+	indexFlags := flags
+	indexFlags.libFlags = strings.Join(indexLibFlags, " ")
+	args, indexDeps := dynamicBinaryLinkArgs(ctx, sharedLibs, archiveStaticLibs, archiveLateStaticLibs,
+		archiveWholeStaticLibs, indexDeps, crtBegin, crtEnd, groupLate, indexFlags)
+	args["oldPrefix"] = soongOutDir + "/"
+	args["newPrefix"] = thinLTODir.String() + "/"
+	args["indexFiles"] = indexFilesList.String()
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:            thinLTOIndex,
+		Description:     "thinlto index " + outputFile.Base(),
+		Output:          android.PathForModuleOut(ctx, "thinlto", outputFile.Base()+".index"),
+		ImplicitOutputs: indexFiles,
+		Inputs:          indexObjFiles,
+		Implicits:       append(indexDeps, indexFilesList),
+		OrderOnly:       sharedLibs,
+		Args:            args,
+	})
+
+	// The backend compiles.
+	ccCmd := "${config.ClangBin}/clang"
+	if flags.sdclang {
+		ccCmd = "${config.SDClangBin}/clang"
+	}
+	cFlags, profiles := thinLTOBackendFlags(flags.toolchain.ClangTriple(),
+		strings.Fields(flags.globalLdFlags+" "+flags.localLdFlags))
+
+	for _, o := range objects {
+		rule := thinLTOBackend
+		args := map[string]string{
+			"ccCmd":  ccCmd,
+			"cFlags": strings.Join(cFlags, " "),
+			"index":  o.index.String(),
+		}
+		if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_THINLTO_BACKEND") {
+			rule = thinLTOBackendRE
+			args["imports"] = o.imports.String()
+			args["implicitInputs"] = strings.Join(append([]string{o.imports.String()}, profiles...), ",")
+		}
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        rule,
+			Description: "thinlto backend " + o.obj.Base(),
+			Output:      o.native,
+			Input:       o.obj,
+			Implicits:   android.Paths{o.index, o.imports},
+			Args:        args,
+		})
+	}
+
+	// The final link.
+	nativeFlags := flags
+	nativeFlags.distributedThinLTO = false
+	nativeFlags.libFlags = strings.Join(nativeLibFlags, " ")
+	transformObjToDynamicBinary(ctx, nativeObjFiles, sharedLibs, archiveStaticLibs,
+		archiveLateStaticLibs, archiveWholeStaticLibs, nativeDeps, nativeCrtBegin, nativeCrtEnd,
+		groupLate, nativeFlags, outputFile, implicitOutputs, validations)
+}
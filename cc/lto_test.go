@@ -239,3 +239,137 @@ func TestLtoDoesNotPropagateToRuntimeLibs(t *testing.T) {
 	android.AssertStringDoesNotContain(t, "got flag for LTO in runtime_lib",
 		libBar.Args["ldFlags"], "-flto=thin")
 }
+
+func TestDistributedThinLto(t *testing.T) {
+	t.Parallel()
+	bp := `
+	cc_binary {
+		name: "foo",
+		srcs: ["foo.cpp"],
+		static_libs: ["libbar"],
+		lto: {
+			thin: true,
+			distributed: true,
+		},
+	}
+
+	cc_library_static {
+		name: "libbar",
+		srcs: ["bar.cpp"],
+	}`
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+	).RunTestWithBp(t, bp)
+
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a")
+	thinLTODir := "out/soong/.intermediates/foo/android_arm64_armv8-a/thinlto/"
+	fooObj := "out/soong/.intermediates/foo/android_arm64_armv8-a/obj/foo.o"
+	barObj := "out/soong/.intermediates/libbar/android_arm64_armv8-a_static_lto-thin/obj/bar.o"
+	libbar := "out/soong/.intermediates/libbar/android_arm64_armv8-a_static_lto-thin/libbar.a"
+
+	// The index step links the object files of the static libraries lazily instead of the archive.
+	index := foo.Output("thinlto/foo.index")
+	android.AssertStringListContains(t, "index inputs", index.Inputs.Strings(), fooObj)
+	android.AssertStringDoesContain(t, "index libFlags", index.Args["libFlags"],
+		"-Wl,--start-lib "+barObj+" -Wl,--end-lib")
+	android.AssertStringListDoesNotContain(t, "index implicits", index.Implicits.Strings(), libbar)
+	android.AssertStringEquals(t, "index prefix", thinLTODir, index.Args["newPrefix"])
+	android.AssertStringListContains(t, "index outputs", index.ImplicitOutputs.Strings(),
+		thinLTODir+".intermediates/libbar/android_arm64_armv8-a_static_lto-thin/obj/bar.o.thinlto.bc")
+
+	// Each object file has its own backend compile.
+	barNative := thinLTODir + ".intermediates/libbar/android_arm64_armv8-a_static_lto-thin/obj/bar.native.o"
+	backend := foo.Output(barNative)
+	android.AssertPathRelativeToTopEquals(t, "backend input", barObj, backend.Input)
+	android.AssertStringEquals(t, "backend index",
+		thinLTODir+".intermediates/libbar/android_arm64_armv8-a_static_lto-thin/obj/bar.o.thinlto.bc",
+		backend.Args["index"])
+
+	// The final link only links native object files.
+	fooNative := thinLTODir + ".intermediates/foo/android_arm64_armv8-a/obj/foo.native.o"
+	link := foo.Rule("ld")
+	android.AssertStringListContains(t, "link inputs", link.Inputs.Strings(), fooNative)
+	android.AssertStringListDoesNotContain(t, "link inputs", link.Inputs.Strings(), fooObj)
+	android.AssertStringDoesContain(t, "link libFlags", link.Args["libFlags"],
+		"-Wl,--start-lib "+barNative+" -Wl,--end-lib")
+	android.AssertStringListDoesNotContain(t, "link implicits", link.Implicits.Strings(), libbar)
+}
+
+func TestDistributedThinLtoWholeStaticLibPrebuilts(t *testing.T) {
+	t.Parallel()
+	bp := `
+	cc_binary {
+		name: "foo",
+		srcs: ["foo.cpp"],
+		static_libs: ["libbar"],
+		lto: {
+			thin: true,
+			distributed: true,
+		},
+	}
+
+	cc_library_static {
+		name: "libbar",
+		srcs: ["bar.cpp"],
+		whole_static_libs: ["libprebuilt"],
+	}
+
+	cc_prebuilt_library_static {
+		name: "libprebuilt",
+		srcs: ["libprebuilt.a"],
+	}`
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddFile("libprebuilt.a", nil),
+	).RunTestWithBp(t, bp)
+
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a")
+	barObj := "out/soong/.intermediates/libbar/android_arm64_armv8-a_static_lto-thin/obj/bar.o"
+	libbar := "out/soong/.intermediates/libbar/android_arm64_armv8-a_static_lto-thin/libbar.a"
+
+	// The objects of libbar miss the members of libprebuilt, so the archive is linked instead.
+	index := foo.Output("thinlto/foo.index")
+	android.AssertStringDoesNotContain(t, "index libFlags", index.Args["libFlags"], barObj)
+	android.AssertStringListContains(t, "index implicits", index.Implicits.Strings(), libbar)
+	link := foo.Rule("ld")
+	android.AssertStringListContains(t, "link implicits", link.Implicits.Strings(), libbar)
+}
+
+func TestDistributedLtoRequiresThinLto(t *testing.T) {
+	t.Parallel()
+	bp := `
+	cc_binary {
+		name: "foo",
+		srcs: ["foo.cpp"],
+		lto: {
+			distributed: true,
+		},
+	}`
+
+	android.GroupFixturePreparers(
+		prepareForCcTest,
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`Distributed requires ThinLTO`)).
+		RunTestWithBp(t, bp)
+}
+
+func TestThinLtoBackendFlags(t *testing.T) {
+	t.Parallel()
+	cFlags, profiles := thinLTOBackendFlags("aarch64-linux-android", []string{
+		"-Wl,--build-id=md5",
+		"-Wl,-mllvm,-regalloc-enable-advisor=release",
+		"-Wl,-plugin-opt,-import-instr-limit=5",
+		"-Wl,--lto-O0",
+		"-fprofile-sample-use=toolchain/pgo-profiles/foo.afdo",
+	})
+	android.AssertArrayString(t, "cFlags", []string{
+		"-target", "aarch64-linux-android", "-O2", "-ffunction-sections", "-fdata-sections",
+		"-mllvm", "-regalloc-enable-advisor=release",
+		"-mllvm", "-import-instr-limit=5",
+		"-O0",
+		"-fprofile-sample-use=toolchain/pgo-profiles/foo.afdo",
+	}, cFlags)
+	android.AssertArrayString(t, "profiles", []string{"toolchain/pgo-profiles/foo.afdo"}, profiles)
+}
@@ -153,6 +153,10 @@ func (props *PgoProperties) addProfileUseFlags(ctx ModuleContext, flags Flags) F
 		// if profileFile gets updated
 		flags.CFlagsDeps = append(flags.CFlagsDeps, profileFilePath)
 		flags.LdFlagsDeps = append(flags.LdFlagsDeps, profileFilePath)
+
+		if profileStalenessReportEnabled(ctx) {
+			flags.ProfileStalenessCheck = profileFilePath.String()
+		}
 	}
 	return flags
 }
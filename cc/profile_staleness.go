@@ -0,0 +1,129 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+// This file contains the profile staleness report, which measures how much of the AFDO or PGO
+// profile of each module still matches its code. Profiles are only refreshed every so often and
+// silently stop applying to functions that changed since, losing the optimizations.
+//
+// It is enabled by building with PROFILE_STALENESS_REPORT=true. Each source of a module that is
+// compiled with a profile is then compiled again with the diagnostics that clang emits for the
+// profile: the profile records applied to each function for AFDO, and the functions with
+// mismatched or missing data for PGO. The profile-staleness-report target merges the diagnostics
+// of each module into out/soong/profile_staleness/profile_staleness_report.json and a text
+// summary, flagging the modules that match fewer than PROFILE_STALENESS_THRESHOLD percent (50 by
+// default) of their functions. The totals of each profile over the modules sharing it are
+// reported too.
+//
+// Rust modules are not checked, as rustc drops the sample profile diagnostics of LLVM.
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"android/soong/android"
+	"github.com/google/blueprint"
+)
+
+func init() {
+	pctx.HostBinToolVariable("profileStalenessCmd", "profile_staleness")
+
+	// Report the profile records applied to every function with an AFDO profile, not only to
+	// those below the threshold, and keep the diagnostics as warnings. -fprofile-use disables
+	// -Wbackend-plugin, so it is enabled again.
+	pctx.StaticVariable("profileCheckFlags", strings.Join([]string{
+		"-mllvm -sample-profile-check-record-coverage=101",
+		"-Wbackend-plugin",
+		"-Wprofile-instr-out-of-date",
+		"-Wprofile-instr-missing",
+		"-Wno-error=backend-plugin",
+		"-Wno-error=profile-instr-out-of-date",
+		"-Wno-error=profile-instr-missing",
+	}, " "))
+}
+
+var profileStalenessReport = pctx.AndroidStaticRule("profileStalenessReport",
+	blueprint.RuleParams{
+		Command:     "$profileStalenessCmd --manifest $manifest --threshold $threshold --json $out --text $text",
+		CommandDeps: []string{"$profileStalenessCmd"},
+	},
+	"manifest", "threshold", "text")
+
+// The default percentage of the functions of a module that must still match its profile for the
+// module not to be reported as stale.
+const profileStalenessDefaultThreshold = 50
+
+// profileStalenessReportEnabled returns true if the staleness of the profiles should be checked.
+func profileStalenessReportEnabled(ctx android.BaseModuleContext) bool {
+	return ctx.Device() && ctx.Config().IsEnvTrue("PROFILE_STALENESS_REPORT")
+}
+
+func profileStalenessSingletonFactory() android.Singleton {
+	return &profileStalenessSingleton{}
+}
+
+type profileStalenessSingleton struct{}
+
+func (s *profileStalenessSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !ctx.Config().IsEnvTrue("PROFILE_STALENESS_REPORT") {
+		return
+	}
+
+	threshold := profileStalenessDefaultThreshold
+	if value := ctx.Config().Getenv("PROFILE_STALENESS_THRESHOLD"); value != "" {
+		var err error
+		threshold, err = strconv.Atoi(value)
+		if err != nil || threshold < 0 || threshold > 100 {
+			ctx.Errorf("PROFILE_STALENESS_THRESHOLD must be a percentage, got %q", value)
+			return
+		}
+	}
+
+	// Each line lists a profile, a module using it, and the diagnostics of one of its sources.
+	var lines []string
+	var checkFiles android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		c, ok := module.(*Module)
+		if !ok || !c.Enabled() || len(c.profileCheckFiles) == 0 {
+			return
+		}
+		for _, file := range c.profileCheckFiles {
+			lines = append(lines, strings.Join([]string{
+				c.flags.ProfileStalenessCheck, ctx.ModuleName(module), file.String()}, "\t"))
+		}
+		checkFiles = append(checkFiles, c.profileCheckFiles...)
+	})
+	sort.Strings(lines)
+
+	manifest := android.PathForOutput(ctx, "profile_staleness", "profile_check_files.txt")
+	android.WriteFileRule(ctx, manifest, strings.Join(lines, "\n"))
+
+	report := android.PathForOutput(ctx, "profile_staleness", "profile_staleness_report.json")
+	text := android.PathForOutput(ctx, "profile_staleness", "profile_staleness_report.txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:           profileStalenessReport,
+		Description:    "profile staleness report",
+		Output:         report,
+		ImplicitOutput: text,
+		Implicits:      append(android.Paths{manifest}, checkFiles...),
+		Args: map[string]string{
+			"manifest":  manifest.String(),
+			"threshold": strconv.Itoa(threshold),
+			"text":      text.String(),
+		},
+	})
+	ctx.Phony("profile-staleness-report", report, text)
+}
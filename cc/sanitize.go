@@ -166,6 +166,14 @@ func (t SanitizerType) name() string {
 	}
 }
 
+// Name of the sanitize property for this sanitizer type
+func (t SanitizerType) propertyName() string {
+	if t == scs {
+		return "scs"
+	}
+	return t.name()
+}
+
 func (t SanitizerType) registerMutators(ctx android.RegisterMutatorsContext) {
 	switch t {
 	case cfi, Hwasan, Asan, tsan, Fuzzer, scs:
@@ -374,6 +382,9 @@ type SanitizeProperties struct {
 	InSanitizerDir    bool     `blueprint:"mutated"`
 	Sanitizers        []string `blueprint:"mutated"`
 	DiagSanitizers    []string `blueprint:"mutated"`
+
+	// The reason each sanitizer was last enabled or disabled, as "<sanitizer>: <reason>".
+	Reasons []string `blueprint:"mutated"`
 }
 
 type sanitize struct {
@@ -448,13 +459,48 @@ func (p *sanitizeMutatedProperties) copyUserPropertiesToMutated(userProps *Sanit
 	}
 }
 
+// namedSanitizerProp is the property of a sanitizer with the name it has in Android.bp files.
+type namedSanitizerProp struct {
+	name string
+	prop **bool
+}
+
+// sanitizerProps returns the properties of the sanitizers that are explained in the sanitizer
+// report.
+func (p *sanitizeMutatedProperties) sanitizerProps() []namedSanitizerProp {
+	return []namedSanitizerProp{
+		{"address", &p.Address},
+		{"hwaddress", &p.Hwaddress},
+		{"thread", &p.Thread},
+		{"all_undefined", &p.All_undefined},
+		{"undefined", &p.Undefined},
+		{"fuzzer", &p.Fuzzer},
+		{"safestack", &p.Safestack},
+		{"cfi", &p.Cfi},
+		{"integer_overflow", &p.Integer_overflow},
+		{"scudo", &p.Scudo},
+		{"scs", &p.Scs},
+		{"memtag_heap", &p.Memtag_heap},
+		{"memtag_stack", &p.Memtag_stack},
+		{"writeonly", &p.Writeonly},
+	}
+}
+
 func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 	s := &sanitize.Properties.SanitizeMutated
 	s.copyUserPropertiesToMutated(&sanitize.Properties.Sanitize)
+	for _, p := range s.sanitizerProps() {
+		if *p.prop != nil {
+			sanitize.explain(ctx, p.name, "sanitize."+p.name+" property")
+		}
+	}
 
 	// Don't apply sanitizers to NDK code.
 	if ctx.useSdk() {
 		s.Never = BoolPtr(true)
+		sanitize.explain(ctx, "never", "NDK module")
+	} else if Bool(s.Never) {
+		sanitize.explain(ctx, "never", "sanitize.never property")
 	}
 
 	// Never always wins.
@@ -465,7 +511,7 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 	// cc_test targets default to SYNC MemTag unless explicitly set to ASYNC (via diag: {memtag_heap: false}).
 	if ctx.testBinary() {
 		if s.Memtag_heap == nil {
-			s.Memtag_heap = proptools.BoolPtr(true)
+			sanitize.update(ctx, &s.Memtag_heap, proptools.BoolPtr(true), "memtag_heap", "default for tests")
 		}
 		if s.Diag.Memtag_heap == nil {
 			s.Diag.Memtag_heap = proptools.BoolPtr(true)
@@ -474,64 +520,73 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 
 	var globalSanitizers []string
 	var globalSanitizersDiag []string
+	var global string
 
 	if ctx.Host() {
 		if !ctx.Windows() {
 			globalSanitizers = ctx.Config().SanitizeHost()
+			global = "SANITIZE_HOST"
 		}
 	} else {
 		arches := ctx.Config().SanitizeDeviceArch()
 		if len(arches) == 0 || inList(ctx.Arch().ArchType.Name, arches) {
 			globalSanitizers = ctx.Config().SanitizeDevice()
 			globalSanitizersDiag = ctx.Config().SanitizeDeviceDiag()
+			global = "SANITIZE_TARGET"
 		}
 	}
 
 	if len(globalSanitizers) > 0 {
 		var found bool
 		if found, globalSanitizers = removeFromList("undefined", globalSanitizers); found && s.All_undefined == nil {
-			s.All_undefined = proptools.BoolPtr(true)
+			sanitize.update(ctx, &s.All_undefined, proptools.BoolPtr(true), "all_undefined", global)
 		}
 
 		if found, globalSanitizers = removeFromList("default-ub", globalSanitizers); found && s.Undefined == nil {
-			s.Undefined = proptools.BoolPtr(true)
+			sanitize.update(ctx, &s.Undefined, proptools.BoolPtr(true), "undefined", global)
 		}
 
 		if found, globalSanitizers = removeFromList("address", globalSanitizers); found && s.Address == nil {
-			s.Address = proptools.BoolPtr(true)
+			sanitize.update(ctx, &s.Address, proptools.BoolPtr(true), "address", global)
 		}
 
 		if found, globalSanitizers = removeFromList("thread", globalSanitizers); found && s.Thread == nil {
-			s.Thread = proptools.BoolPtr(true)
+			sanitize.update(ctx, &s.Thread, proptools.BoolPtr(true), "thread", global)
 		}
 
 		if found, globalSanitizers = removeFromList("fuzzer", globalSanitizers); found && s.Fuzzer == nil {
-			s.Fuzzer = proptools.BoolPtr(true)
+			sanitize.update(ctx, &s.Fuzzer, proptools.BoolPtr(true), "fuzzer", global)
 		}
 
 		if found, globalSanitizers = removeFromList("safe-stack", globalSanitizers); found && s.Safestack == nil {
-			s.Safestack = proptools.BoolPtr(true)
+			sanitize.update(ctx, &s.Safestack, proptools.BoolPtr(true), "safestack", global)
 		}
 
 		if found, globalSanitizers = removeFromList("cfi", globalSanitizers); found && s.Cfi == nil {
 			if !ctx.Config().CFIDisabledForPath(ctx.ModuleDir()) {
-				s.Cfi = proptools.BoolPtr(true)
+				sanitize.update(ctx, &s.Cfi, proptools.BoolPtr(true), "cfi", global)
+			} else {
+				sanitize.explain(ctx, "cfi", global+" excluded by CFIExcludePaths")
 			}
 		}
 
 		// Global integer_overflow builds do not support static libraries.
 		if found, globalSanitizers = removeFromList("integer_overflow", globalSanitizers); found && s.Integer_overflow == nil {
-			if !ctx.Config().IntegerOverflowDisabledForPath(ctx.ModuleDir()) && !ctx.static() {
-				s.Integer_overflow = proptools.BoolPtr(true)
+			if ctx.Config().IntegerOverflowDisabledForPath(ctx.ModuleDir()) {
+				sanitize.explain(ctx, "integer_overflow", global+" excluded by IntegerOverflowExcludePaths")
+			} else if ctx.static() {
+				sanitize.explain(ctx, "integer_overflow", global+" unsupported for static libraries")
+			} else {
+				sanitize.update(ctx, &s.Integer_overflow, proptools.BoolPtr(true), "integer_overflow", global)
 			}
 		}
 
 		if found, globalSanitizers = removeFromList("scudo", globalSanitizers); found && s.Scudo == nil {
-			s.Scudo = proptools.BoolPtr(true)
+			sanitize.update(ctx, &s.Scudo, proptools.BoolPtr(true), "scudo", global)
 		}
 
 		if found, globalSanitizers = removeFromList("hwaddress", globalSanitizers); found && s.Hwaddress == nil {
-			s.Hwaddress = proptools.BoolPtr(true)
+			sanitize.update(ctx, &s.Hwaddress, proptools.BoolPtr(true), "hwaddress", global)
 		}
 
 		if found, globalSanitizers = removeFromList("writeonly", globalSanitizers); found && s.Writeonly == nil {
@@ -540,16 +595,18 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 			if s.Address == nil && s.Hwaddress == nil {
 				ctx.ModuleErrorf("writeonly modifier cannot be used without 'address' or 'hwaddress'")
 			}
-			s.Writeonly = proptools.BoolPtr(true)
+			sanitize.update(ctx, &s.Writeonly, proptools.BoolPtr(true), "writeonly", global)
 		}
 		if found, globalSanitizers = removeFromList("memtag_heap", globalSanitizers); found && s.Memtag_heap == nil {
 			if !ctx.Config().MemtagHeapDisabledForPath(ctx.ModuleDir()) {
-				s.Memtag_heap = proptools.BoolPtr(true)
+				sanitize.update(ctx, &s.Memtag_heap, proptools.BoolPtr(true), "memtag_heap", global)
+			} else {
+				sanitize.explain(ctx, "memtag_heap", global+" excluded by MemtagHeapExcludePaths")
 			}
 		}
 
 		if found, globalSanitizers = removeFromList("memtag_stack", globalSanitizers); found && s.Memtag_stack == nil {
-			s.Memtag_stack = proptools.BoolPtr(true)
+			sanitize.update(ctx, &s.Memtag_stack, proptools.BoolPtr(true), "memtag_stack", global)
 		}
 
 		if len(globalSanitizers) > 0 {
@@ -581,14 +638,14 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 	if ctx.Arch().ArchType == android.Arm64 && ctx.toolchain().Bionic() {
 		if ctx.Config().MemtagHeapSyncEnabledForPath(ctx.ModuleDir()) {
 			if s.Memtag_heap == nil {
-				s.Memtag_heap = proptools.BoolPtr(true)
+				sanitize.update(ctx, &s.Memtag_heap, proptools.BoolPtr(true), "memtag_heap", "MemtagHeapSyncIncludePaths")
 			}
 			if s.Diag.Memtag_heap == nil {
 				s.Diag.Memtag_heap = proptools.BoolPtr(true)
 			}
 		} else if ctx.Config().MemtagHeapAsyncEnabledForPath(ctx.ModuleDir()) {
 			if s.Memtag_heap == nil {
-				s.Memtag_heap = proptools.BoolPtr(true)
+				sanitize.update(ctx, &s.Memtag_heap, proptools.BoolPtr(true), "memtag_heap", "MemtagHeapAsyncIncludePaths")
 			}
 		}
 	}
@@ -596,11 +653,11 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 	// Enable HWASan for all components in the include paths (for Aarch64 only)
 	if s.Hwaddress == nil && ctx.Config().HWASanEnabledForPath(ctx.ModuleDir()) &&
 		ctx.Arch().ArchType == android.Arm64 && ctx.toolchain().Bionic() {
-		s.Hwaddress = proptools.BoolPtr(true)
+		sanitize.update(ctx, &s.Hwaddress, proptools.BoolPtr(true), "hwaddress", "HWASanIncludePaths")
 	}
 
 	if s.Integer_overflow == nil && ctx.Config().IntegerOverflowEnabledForPath(ctx.ModuleDir()) && ctx.Arch().ArchType == android.Arm64 {
-		s.Integer_overflow = proptools.BoolPtr(true)
+		sanitize.update(ctx, &s.Integer_overflow, proptools.BoolPtr(true), "integer_overflow", "IntegerOverflowIncludePaths")
 	}
 
 	if ctx.Config().BoundSanitizerEnabledForPath(ctx.ModuleDir()) && ctx.Arch().ArchType == android.Arm64 {
@@ -625,19 +682,19 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 		if indexList("unsigned-integer-overflow", s.Misc_undefined) != -1 {
 			s.Misc_undefined = append(s.Misc_undefined[0:indx], s.Misc_undefined[indx+1:]...)
 		}
-		s.Integer_overflow = nil
+		sanitize.update(ctx, &s.Integer_overflow, nil, "integer_overflow", "IntegerOverflowExcludePaths")
 	}
 
 	// Enable CFI for non-host components in the include paths
 	if s.Cfi == nil && ctx.Config().CFIEnabledForPath(ctx.ModuleDir()) && !ctx.Host() {
-		s.Cfi = proptools.BoolPtr(true)
+		sanitize.update(ctx, &s.Cfi, proptools.BoolPtr(true), "cfi", "CFIIncludePaths")
 		if inList("cfi", ctx.Config().SanitizeDeviceDiag()) {
 			s.Diag.Cfi = proptools.BoolPtr(true)
 		}
 	}
 	// Disable CFI for all component in the exclude path (for Aarch64 only)
 	if ctx.Config().CFIDisabledForPath(ctx.ModuleDir()) && ctx.Arch().ArchType == android.Arm64 {
-		s.Cfi = nil
+		sanitize.update(ctx, &s.Cfi, nil, "cfi", "CFIExcludePaths")
 		if inList("cfi", ctx.Config().SanitizeDeviceDiag()) {
 			s.Diag.Cfi = nil
 		}
@@ -645,88 +702,90 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 
 	// Is CFI actually enabled?
 	if !ctx.Config().EnableCFI() {
-		s.Cfi = nil
+		sanitize.update(ctx, &s.Cfi, nil, "cfi", "EnableCFI is false")
 		s.Diag.Cfi = nil
 	}
 
+	unsupported := "unsupported on " + ctx.Os().Name + " " + ctx.Arch().ArchType.Name
+
 	// HWASan requires AArch64 hardware feature (top-byte-ignore).
 	if ctx.Arch().ArchType != android.Arm64 || !ctx.toolchain().Bionic() {
-		s.Hwaddress = nil
+		sanitize.update(ctx, &s.Hwaddress, nil, "hwaddress", unsupported)
 	}
 
 	// SCS is only implemented on AArch64/riscv64.
 	if (ctx.Arch().ArchType != android.Arm64 && ctx.Arch().ArchType != android.Riscv64) || !ctx.toolchain().Bionic() {
-		s.Scs = nil
+		sanitize.update(ctx, &s.Scs, nil, "scs", unsupported)
 	}
 	// ...but temporarily globally disabled on riscv64 (http://b/277909695).
 	if ctx.Arch().ArchType == android.Riscv64 {
-		s.Scs = nil
+		sanitize.update(ctx, &s.Scs, nil, "scs", unsupported)
 	}
 
 	// Memtag_heap is only implemented on AArch64.
 	// Memtag ABI is Android specific for now, so disable for host.
 	if ctx.Arch().ArchType != android.Arm64 || !ctx.toolchain().Bionic() || ctx.Host() {
-		s.Memtag_heap = nil
-		s.Memtag_stack = nil
+		sanitize.update(ctx, &s.Memtag_heap, nil, "memtag_heap", unsupported)
+		sanitize.update(ctx, &s.Memtag_stack, nil, "memtag_stack", unsupported)
 	}
 
 	// Also disable CFI if ASAN is enabled.
 	if Bool(s.Address) || Bool(s.Hwaddress) {
-		s.Cfi = nil
+		sanitize.update(ctx, &s.Cfi, nil, "cfi", "incompatible with address or hwaddress")
 		s.Diag.Cfi = nil
 		// HWASAN and ASAN win against MTE.
-		s.Memtag_heap = nil
-		s.Memtag_stack = nil
+		sanitize.update(ctx, &s.Memtag_heap, nil, "memtag_heap", "incompatible with address or hwaddress")
+		sanitize.update(ctx, &s.Memtag_stack, nil, "memtag_stack", "incompatible with address or hwaddress")
 	}
 
 	// Disable sanitizers that depend on the UBSan runtime for windows/darwin builds.
 	if !ctx.Os().Linux() {
-		s.Cfi = nil
+		sanitize.update(ctx, &s.Cfi, nil, "cfi", unsupported)
 		s.Diag.Cfi = nil
 		s.Misc_undefined = nil
-		s.Undefined = nil
-		s.All_undefined = nil
-		s.Integer_overflow = nil
+		sanitize.update(ctx, &s.Undefined, nil, "undefined", unsupported)
+		sanitize.update(ctx, &s.All_undefined, nil, "all_undefined", unsupported)
+		sanitize.update(ctx, &s.Integer_overflow, nil, "integer_overflow", unsupported)
 	}
 
 	// TODO(b/254713216): CFI doesn't work for riscv64 yet because LTO doesn't work.
 	if ctx.Arch().ArchType == android.Riscv64 {
-		s.Cfi = nil
+		sanitize.update(ctx, &s.Cfi, nil, "cfi", unsupported)
 		s.Diag.Cfi = nil
 	}
 
 	// Disable CFI for musl
 	if ctx.toolchain().Musl() {
-		s.Cfi = nil
+		sanitize.update(ctx, &s.Cfi, nil, "cfi", "unsupported with musl")
 		s.Diag.Cfi = nil
 	}
 
 	// Also disable CFI for VNDK variants of components
 	if ctx.isVndk() && ctx.useVndk() {
-		s.Cfi = nil
+		sanitize.update(ctx, &s.Cfi, nil, "cfi", "unsupported for VNDK variants")
 		s.Diag.Cfi = nil
 	}
 
 	// HWASan ramdisk (which is built from recovery) goes over some bootloader limit.
 	// Keep libc instrumented so that ramdisk / vendor_ramdisk / recovery can run hwasan-instrumented code if necessary.
 	if (ctx.inRamdisk() || ctx.inVendorRamdisk() || ctx.inRecovery()) && !strings.HasPrefix(ctx.ModuleDir(), "bionic/libc") {
-		s.Hwaddress = nil
+		sanitize.update(ctx, &s.Hwaddress, nil, "hwaddress", "unsupported for ramdisk and recovery variants")
 	}
 
 	if ctx.staticBinary() {
-		s.Address = nil
-		s.Fuzzer = nil
-		s.Thread = nil
+		sanitize.update(ctx, &s.Address, nil, "address", "unsupported for static binaries")
+		sanitize.update(ctx, &s.Fuzzer, nil, "fuzzer", "unsupported for static binaries")
+		sanitize.update(ctx, &s.Thread, nil, "thread", "unsupported for static binaries")
 	}
 
 	if Bool(s.All_undefined) {
-		s.Undefined = nil
+		sanitize.update(ctx, &s.Undefined, nil, "undefined", "included in all_undefined")
 	}
 
 	if !ctx.toolchain().Is64Bit() {
 		// TSAN and SafeStack are not supported on 32-bit architectures
-		s.Thread = nil
-		s.Safestack = nil
+		sanitize.update(ctx, &s.Thread, nil, "thread", unsupported)
+		sanitize.update(ctx, &s.Safestack, nil, "safestack", unsupported)
 		// TODO(ccross): error for compile_multilib = "32"?
 	}
 
@@ -737,20 +796,59 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 	}
 
 	// Disable Scudo if ASan or TSan is enabled, or if it's disabled globally.
-	if Bool(s.Address) || Bool(s.Thread) || Bool(s.Hwaddress) || ctx.Config().DisableScudo() {
-		s.Scudo = nil
+	if Bool(s.Address) || Bool(s.Thread) || Bool(s.Hwaddress) {
+		sanitize.update(ctx, &s.Scudo, nil, "scudo", "incompatible with address, hwaddress or thread")
+	} else if ctx.Config().DisableScudo() {
+		sanitize.update(ctx, &s.Scudo, nil, "scudo", "DisableScudo is true")
 	}
 
 	if Bool(s.Hwaddress) {
-		s.Address = nil
-		s.Thread = nil
+		sanitize.update(ctx, &s.Address, nil, "address", "hwaddress takes precedence")
+		sanitize.update(ctx, &s.Thread, nil, "thread", "hwaddress takes precedence")
 	}
 
 	// TODO(b/131771163): CFI transiently depends on LTO, and thus Fuzzer is
 	// mutually incompatible.
 	if Bool(s.Fuzzer) {
-		s.Cfi = nil
+		sanitize.update(ctx, &s.Cfi, nil, "cfi", "incompatible with fuzzer")
+	}
+}
+
+// update sets the property of a sanitizer in begin, and records the reason if that enables or
+// disables the sanitizer.
+func (sanitize *sanitize) update(ctx android.PathContext, prop **bool, value *bool, name, reason string) {
+	if Bool(*prop) != Bool(value) {
+		sanitize.explain(ctx, name, reason)
+	}
+	*prop = value
+}
+
+// explain records the reason a sanitizer was last enabled or disabled when the sanitizer report is
+// enabled.
+func (sanitize *sanitize) explain(ctx android.PathContext, name, reason string) {
+	if !sanitizerReportEnabled(ctx) {
+		return
+	}
+	prefix := name + ": "
+	// Reasons is shared with the variants created by the sanitizer mutators, always make a new copy.
+	reasons := []string{prefix + reason}
+	for _, r := range sanitize.Properties.Reasons {
+		if !strings.HasPrefix(r, prefix) {
+			reasons = append(reasons, r)
+		}
+	}
+	sanitize.Properties.Reasons = reasons
+}
+
+// reason returns the reason a sanitizer was last enabled or disabled, or "" if it was never set.
+func (sanitize *sanitize) reason(name string) string {
+	prefix := name + ": "
+	for _, r := range sanitize.Properties.Reasons {
+		if strings.HasPrefix(r, prefix) {
+			return strings.TrimPrefix(r, prefix)
+		}
 	}
+	return ""
 }
 
 func toDisableImplicitIntegerChange(flags []string) bool {
@@ -1361,11 +1459,17 @@ func (s *sanitizerSplitMutator) Mutate(mctx android.BottomUpMutatorContext, vari
 
 		if sanitizerVariation {
 			c.SetSanitizer(s.sanitizer, true)
+			if !sanitizerEnabled {
+				explainSanitizer(mctx, s.sanitizer, sanitizerPropagatedReason)
+			}
 
 			// CFI is incompatible with ASAN so disable it in ASAN variations
 			if s.sanitizer.incompatibleWithCfi() {
 				cfiSupported := mctx.Module().(PlatformSanitizeable).SanitizerSupported(cfi)
 				if mctx.Device() && cfiSupported {
+					if c.IsSanitizerEnabled(cfi) {
+						explainSanitizer(mctx, cfi, "incompatible with the "+s.sanitizer.variationName()+" variant")
+					}
 					c.SetSanitizer(cfi, false)
 				}
 			}
@@ -1385,6 +1489,7 @@ func (s *sanitizerSplitMutator) Mutate(mctx android.BottomUpMutatorContext, vari
 		} else if c.IsSanitizerEnabled(s.sanitizer) {
 			// Disable the sanitizer for the non-sanitized variation
 			c.SetSanitizer(s.sanitizer, false)
+			explainSanitizer(mctx, s.sanitizer, "non-"+s.sanitizer.variationName()+" variant")
 		}
 	} else if sanitizeable, ok := mctx.Module().(Sanitizeable); ok {
 		// If an APEX has sanitized dependencies, it gets a few more dependencies
@@ -1415,6 +1520,19 @@ func (s *sanitizerSplitMutator) Mutate(mctx android.BottomUpMutatorContext, vari
 	}
 }
 
+// sanitizerPropagatedReason is the reason of a sanitizer enabled in the variant of a module that is
+// linked into a module with that sanitizer.  The sanitizer report completes it with the modules
+// linking it.
+const sanitizerPropagatedReason = "propagated"
+
+// explainSanitizer records the reason a sanitizer mutator enabled or disabled a sanitizer for the
+// sanitizer report.
+func explainSanitizer(mctx android.BottomUpMutatorContext, t SanitizerType, reason string) {
+	if c, ok := mctx.Module().(*Module); ok && c.sanitize != nil {
+		c.sanitize.explain(mctx, t.propertyName(), reason)
+	}
+}
+
 func (c *Module) IsSnapshotSanitizer() bool {
 	if _, ok := c.linker.(SnapshotSanitizer); ok {
 		return true
@@ -15,7 +15,10 @@
 package cc
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
@@ -1249,3 +1252,120 @@ func TestCfi(t *testing.T) {
 		t.Errorf("non-CFI variant of baz not expected to contain CFI flags ")
 	}
 }
+
+func TestSanitizerReport(t *testing.T) {
+	t.Parallel()
+
+	bp := `
+	cc_library_shared {
+		name: "libshared",
+		static_libs: ["libstatic"],
+		sanitize: {
+			cfi: true,
+		},
+	}
+
+	cc_library_shared {
+		name: "libshared2",
+		static_libs: ["libstatic"],
+		sanitize: {
+			cfi: true,
+		},
+	}
+
+	cc_library_static {
+		name: "libstatic",
+	}
+
+	cc_library_shared {
+		name: "libnever",
+		sanitize: {
+			never: true,
+		},
+	}
+`
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddTextFile("included/Android.bp", `
+			cc_library_shared {
+				name: "libincluded",
+			}
+		`),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.CFIIncludePaths = []string{"included"}
+		}),
+		android.FixtureMergeEnv(map[string]string{
+			"SANITIZER_REPORT": "true",
+		}),
+	).RunTestWithBp(t, bp)
+
+	result.SingletonForTests("sanitizer_report").Output("sanitizer_report/sanitizer_report.json")
+	report, err := os.ReadFile(filepath.Join(result.Config.SoongOutDir(), "sanitizer_report", "sanitizer_report.json"))
+	if err != nil {
+		t.Fatalf("failed to read the sanitizer report: %s", err)
+	}
+	var entries []sanitizerReportEntry
+	if err := json.Unmarshal(report, &entries); err != nil {
+		t.Fatalf("failed to parse the sanitizer report: %s", err)
+	}
+	findEntry := func(module, variant string) sanitizerReportEntry {
+		t.Helper()
+		for _, entry := range entries {
+			if entry.Module == module && entry.Variant == variant {
+				return entry
+			}
+		}
+		t.Fatalf("missing sanitizer report entry for %s %s", module, variant)
+		return sanitizerReportEntry{}
+	}
+
+	checkCfi := func(module, variant string, enabled bool, reason string) {
+		t.Helper()
+		cfi, ok := findEntry(module, variant).Sanitizers["cfi"]
+		if !ok {
+			t.Errorf("missing cfi decision for %s %s", module, variant)
+			return
+		}
+		android.AssertBoolEquals(t, module+" "+variant+" cfi enabled", enabled, cfi.Enabled)
+		android.AssertStringEquals(t, module+" "+variant+" cfi reason", reason, cfi.Reason)
+	}
+
+	checkCfi("libshared", "android_arm64_armv8-a_shared_cfi", true, "sanitize.cfi property")
+	checkCfi("libstatic", "android_arm64_armv8-a_static_cfi", true, "propagated from libshared, libshared2")
+	checkCfi("libincluded", "android_arm64_armv8-a_shared_cfi", true, "CFIIncludePaths")
+
+	libstatic := findEntry("libstatic", "android_arm64_armv8-a_static")
+	if _, ok := libstatic.Sanitizers["cfi"]; ok {
+		t.Errorf("expected no cfi decision for the non-cfi variant of libstatic, got %v", libstatic.Sanitizers)
+	}
+
+	android.AssertStringEquals(t, "libnever never", "sanitize.never property",
+		findEntry("libnever", "android_arm64_armv8-a_shared").Never)
+}
+
+func TestSanitizerReasonsWithoutReport(t *testing.T) {
+	t.Parallel()
+
+	result := prepareForCcTest.RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libshared",
+			static_libs: ["libstatic"],
+			sanitize: {
+				cfi: true,
+			},
+		}
+
+		cc_library_static {
+			name: "libstatic",
+		}
+	`)
+
+	// The reasons are only recorded for the sanitizer report.
+	checkNoReasons := func(module, variant string) {
+		t.Helper()
+		c := result.ModuleForTests(module, variant).Module().(*Module)
+		android.AssertDeepEquals(t, module+" reasons", []string(nil), c.sanitize.Properties.Reasons)
+	}
+	checkNoReasons("libshared", "android_arm64_armv8-a_shared_cfi")
+	checkNoReasons("libstatic", "android_arm64_armv8-a_static_cfi")
+}
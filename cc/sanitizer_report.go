@@ -0,0 +1,156 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+// This file contains the sanitizer report, which explains the sanitizers of every variant of every
+// native module. It is enabled by building with SANITIZER_REPORT=true, and writes
+// out/soong/sanitizer_report/sanitizer_report.json when the sanitizer-report target is built. For
+// each sanitizer that was enabled or disabled, the report lists whether it ended up enabled and
+// the rule that decided it: a sanitize property, SANITIZE_TARGET or SANITIZE_HOST, a product
+// variable listing paths, an unsupported arch or module type, or the modules it was propagated
+// from.
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"android/soong/android"
+)
+
+// sanitizerReportEnabled returns true if the sanitizer report should be written.
+func sanitizerReportEnabled(ctx android.PathContext) bool {
+	return ctx.Config().IsEnvTrue("SANITIZER_REPORT")
+}
+
+type sanitizerDecision struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason"`
+}
+
+type sanitizerReportEntry struct {
+	Module  string `json:"module"`
+	Variant string `json:"variant"`
+	Dir     string `json:"dir"`
+
+	// The reason no sanitizer is enabled for the module, if sanitizers are disabled altogether.
+	Never string `json:"never,omitempty"`
+
+	Sanitizers     map[string]sanitizerDecision `json:"sanitizers,omitempty"`
+	Misc_undefined []string                     `json:"misc_undefined,omitempty"`
+}
+
+func sanitizerReportSingletonFactory() android.Singleton {
+	return &sanitizerReportSingleton{}
+}
+
+type sanitizerReportSingleton struct{}
+
+func (s *sanitizerReportSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !sanitizerReportEnabled(ctx) {
+		return
+	}
+
+	// The modules linking the variants that a sanitizer was propagated to, by sanitizer. It is
+	// computed here rather than in the sanitizer mutators as those cannot modify their dependencies.
+	// Dependencies through tags that sanitizers do not propagate through always use the variant
+	// without the sanitizer, so every dependency on a propagated variant is one it was propagated
+	// from.
+	propagatedFrom := make(map[*Module]map[string][]string)
+	ctx.VisitAllModules(func(module android.Module) {
+		c, ok := module.(*Module)
+		if !ok || !c.Enabled() || c.sanitize == nil {
+			return
+		}
+		ctx.VisitDirectDeps(module, func(dep android.Module) {
+			d, ok := dep.(*Module)
+			if !ok || d.sanitize == nil {
+				return
+			}
+			for _, t := range Sanitizers {
+				name := t.propertyName()
+				if c.sanitize.isSanitizerEnabled(t) && d.sanitize.isSanitizerEnabled(t) &&
+					d.sanitize.reason(name) == sanitizerPropagatedReason {
+					if propagatedFrom[d] == nil {
+						propagatedFrom[d] = make(map[string][]string)
+					}
+					propagatedFrom[d][name] = append(propagatedFrom[d][name], ctx.ModuleName(module))
+				}
+			}
+		})
+	})
+
+	var entries []sanitizerReportEntry
+	ctx.VisitAllModules(func(module android.Module) {
+		c, ok := module.(*Module)
+		if !ok || !c.Enabled() || c.sanitize == nil {
+			return
+		}
+		props := &c.sanitize.Properties.SanitizeMutated
+		entry := sanitizerReportEntry{
+			Module:  ctx.ModuleName(module),
+			Variant: ctx.ModuleSubDir(module),
+			Dir:     ctx.ModuleDir(module),
+		}
+		if Bool(props.Never) {
+			entry.Never = c.sanitize.reason("never")
+			entries = append(entries, entry)
+			return
+		}
+
+		entry.Sanitizers = make(map[string]sanitizerDecision)
+		for _, p := range props.sanitizerProps() {
+			reason := c.sanitize.reason(p.name)
+			if reason == "" && !Bool(*p.prop) {
+				continue
+			}
+			if from := propagatedFrom[c][p.name]; reason == sanitizerPropagatedReason && len(from) > 0 {
+				reason += " from " + strings.Join(android.SortedUniqueStrings(from), ", ")
+			}
+			entry.Sanitizers[p.name] = sanitizerDecision{
+				Enabled: Bool(*p.prop),
+				Reason:  reason,
+			}
+		}
+		entry.Misc_undefined = props.Misc_undefined
+		entries = append(entries, entry)
+	})
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Module != entries[j].Module {
+			return entries[i].Module < entries[j].Module
+		}
+		return entries[i].Variant < entries[j].Variant
+	})
+
+	// The report lists every variant of every native module, which is too large for the content
+	// of a rule in build.ninja, so it is written by Soong.
+	output := android.PathForOutput(ctx, "sanitizer_report", "sanitizer_report.json")
+	report, err := json.MarshalIndent(entries, "", "  ")
+	if err == nil {
+		err = android.WriteFileToOutputDir(output, report, 0666)
+	}
+	if err != nil {
+		ctx.Errorf("failed to write the sanitizer report: %s", err)
+		return
+	}
+
+	// This is necessary to satisfy the dangling rules check as this file is written by Soong rather than a rule.
+	ctx.Build(pctx, android.BuildParams{
+		Rule:   android.Touch,
+		Output: output,
+	})
+	ctx.Phony("sanitizer-report", output)
+}
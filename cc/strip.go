@@ -74,10 +74,18 @@ func (stripper *Stripper) strip(actx android.ModuleContext, in android.Path, out
 		} else if !Bool(stripper.StripProperties.Strip.All) {
 			flags.StripKeepMiniDebugInfo = true
 		}
+		if buildIdDebugInfoEnabled(actx) && !isStaticLib {
+			// The stripped file links to the separate debug info file with a .gnu_debuglink
+			// section instead of to the unstripped file.
+			debugFile := android.PathForModuleOut(actx, "debuginfo", out.Rel()+".debug")
+			transformStrip(actx, in, out, debugFile, flags)
+			addBuildIdDebugInfo(actx, debugFile)
+			return
+		}
 		if actx.Config().Debuggable() && !flags.StripKeepMiniDebugInfo && !isStaticLib {
 			flags.StripAddGnuDebuglink = true
 		}
-		transformStrip(actx, in, out, flags)
+		transformStrip(actx, in, out, nil, flags)
 	}
 }
 
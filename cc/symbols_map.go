@@ -0,0 +1,120 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+// This file contains the symbols lookup index of the native modules, which maps the elf build ID
+// or Mach-O UUID of every unstripped binary and shared library to the file and the module that
+// produced it. The identifiers are extracted by symbols_map, merged and indexed by symbols_lookup
+// into out/soong/symbols_map/symbols.index when the symbols-lookup-index target is built.
+
+import (
+	"android/soong/android"
+	"github.com/google/blueprint"
+)
+
+func init() {
+	pctx.HostBinToolVariable("symbolsMapCmd", "symbols_map")
+	pctx.HostBinToolVariable("symbolsLookupCmd", "symbols_lookup")
+}
+
+var (
+	symbolsMapping = pctx.AndroidStaticRule("symbolsMapping",
+		blueprint.RuleParams{
+			Command:     "$symbolsMapCmd -$format $in -module $module -write_if_changed $out",
+			CommandDeps: []string{"$symbolsMapCmd"},
+			Restat:      true,
+		},
+		"format", "module")
+
+	symbolsMappingMerge = pctx.AndroidStaticRule("symbolsMappingMerge",
+		blueprint.RuleParams{
+			Command:        "$symbolsMapCmd -merge $out -write_if_changed @$out.rsp",
+			CommandDeps:    []string{"$symbolsMapCmd"},
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$in",
+			Restat:         true,
+		})
+
+	symbolsLookupIndex = pctx.AndroidStaticRule("symbolsLookupIndex",
+		blueprint.RuleParams{
+			Command:     "$symbolsLookupCmd -build_index $out -write_if_changed $in",
+			CommandDeps: []string{"$symbolsLookupCmd"},
+			Restat:      true,
+		})
+)
+
+// symbolsMapFormat returns the symbols_map flag that extracts the identifier of the binaries of
+// the given OS, or an empty string if they have no identifier.
+func symbolsMapFormat(os android.OsType) string {
+	switch os {
+	case android.Darwin:
+		return "macho"
+	case android.Windows:
+		return ""
+	default:
+		return "elf"
+	}
+}
+
+func symbolsMapSingletonFactory() android.Singleton {
+	return &symbolsMapSingleton{}
+}
+
+type symbolsMapSingleton struct{}
+
+func (s *symbolsMapSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	var mappings android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		m, ok := module.(LinkableInterface)
+		if !ok || !module.Enabled() || m.IsStubs() || !(m.Binary() || m.Shared()) {
+			return
+		}
+		unstripped := m.UnstrippedOutputFile()
+		format := symbolsMapFormat(module.Target().Os)
+		if unstripped == nil || format == "" {
+			return
+		}
+		mapping := android.PathForOutput(ctx, "symbols_map", ctx.ModuleDir(module),
+			ctx.ModuleName(module), ctx.ModuleSubDir(module), unstripped.Base()+".textproto")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        symbolsMapping,
+			Description: "symbols map " + unstripped.Base(),
+			Output:      mapping,
+			Input:       unstripped,
+			Args: map[string]string{
+				"format": format,
+				"module": ctx.ModuleName(module),
+			},
+		})
+		mappings = append(mappings, mapping)
+	})
+
+	merged := android.PathForOutput(ctx, "symbols_map", "symbols.textproto")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        symbolsMappingMerge,
+		Description: "merge symbols maps",
+		Output:      merged,
+		Inputs:      mappings,
+	})
+
+	index := android.PathForOutput(ctx, "symbols_map", "symbols.index")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        symbolsLookupIndex,
+		Description: "symbols lookup index",
+		Output:      index,
+		Input:       merged,
+	})
+	ctx.Phony("symbols-lookup-index", index)
+}
@@ -0,0 +1,58 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestSymbolsMap(t *testing.T) {
+	t.Parallel()
+	bp := `
+	cc_binary {
+		name: "foo",
+		srcs: ["foo.c"],
+		shared_libs: ["libbar"],
+	}
+
+	cc_library {
+		name: "libbar",
+		srcs: ["bar.c"],
+	}
+	`
+
+	result := prepareForCcTest.RunTestWithBp(t, bp)
+	singleton := result.SingletonForTests("symbols_map")
+
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a").Module().(*Module)
+	fooMapping := singleton.Output("symbols_map/foo/android_arm64_armv8-a/foo.textproto")
+	android.AssertPathRelativeToTopEquals(t, "input",
+		android.PathRelativeToTop(foo.UnstrippedOutputFile()), fooMapping.Input)
+	android.AssertStringEquals(t, "format", "elf", fooMapping.Args["format"])
+	android.AssertStringEquals(t, "module", "foo", fooMapping.Args["module"])
+
+	singleton.Output("symbols_map/libbar/android_arm64_armv8-a_shared/libbar.so.textproto")
+	if rule := singleton.MaybeOutput("symbols_map/libbar/android_arm64_armv8-a_static/libbar.a.textproto"); rule.Rule != nil {
+		t.Errorf("Expected no symbols map of static libraries")
+	}
+
+	merge := singleton.Output("symbols_map/symbols.textproto")
+	android.AssertStringListContains(t, "merged mappings", android.PathsRelativeToTop(merge.Inputs),
+		"out/soong/symbols_map/foo/android_arm64_armv8-a/foo.textproto")
+	index := singleton.Output("symbols_map/symbols.index")
+	android.AssertPathRelativeToTopEquals(t, "index input", "out/soong/symbols_map/symbols.textproto", index.Input)
+}
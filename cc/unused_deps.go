@@ -0,0 +1,176 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+// This file contains the unused native dependency analysis, which finds the entries of the
+// shared_libs and static_libs properties of binaries and shared libraries that contribute no
+// symbols to the link. A static library is used if the linker extracted any of its members, which
+// the link records with lld's --why-extract, so static libraries are only analyzed for the modules
+// linked with lld from the archives themselves. It is enabled by building with
+// FIND_UNUSED_NATIVE_DEPS=true, and writes out/soong/unused_native_deps/unused_native_deps.json
+// when the unused-native-deps target is built. bpfix -remove-unused-deps consumes the report to
+// delete the unused entries.
+
+import (
+	"strings"
+
+	"android/soong/android"
+	"github.com/google/blueprint"
+)
+
+func init() {
+	pctx.HostBinToolVariable("unusedNativeDepsCmd", "unused_native_deps")
+}
+
+var unusedNativeDeps = pctx.AndroidStaticRule("unusedNativeDeps",
+	blueprint.RuleParams{
+		Command:     "CLANG_BIN=${config.ClangBin} $unusedNativeDepsCmd analyze --module $module --binary $in $libs $whyExtract --output $out",
+		CommandDeps: []string{"$unusedNativeDepsCmd"},
+	},
+	"module", "libs", "whyExtract")
+
+// unusedNativeDepsEnabled returns true if the unused native dependency analysis was requested.
+func unusedNativeDepsEnabled(ctx android.PathContext) bool {
+	return ctx.Config().IsEnvTrue("FIND_UNUSED_NATIVE_DEPS")
+}
+
+// addWhyExtractFlag makes the link of a binary or shared library record why it extracted each
+// archive member, from which the unused native dependency analysis finds the unused static
+// libraries, and returns the file to add to the outputs of the link. Only lld supports
+// --why-extract, and distributed ThinLTO links the objects of the static libraries instead of the
+// archives.
+func (linker *baseLinker) addWhyExtractFlag(ctx ModuleContext, flags Flags) (Flags, android.WritablePaths) {
+	if !unusedNativeDepsEnabled(ctx) || ctx.Darwin() || ctx.Windows() || !ctx.useClangLld(ctx) ||
+		flags.DistributedThinLTO {
+		return flags, nil
+	}
+	file := android.PathForModuleOut(ctx, "why_extract.txt")
+	flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--why-extract="+file.String())
+	linker.whyExtractFile = android.OptionalPathForPath(file)
+	return flags, android.WritablePaths{file}
+}
+
+// explicitLibs returns the shared and static libraries listed in the properties of the module,
+// which are the ones that can be removed.
+func (linker *baseLinker) explicitLibs() (sharedLibs, staticLibs []string) {
+	return linker.Properties.Shared_libs, linker.Properties.Static_libs
+}
+
+// unusedNativeDepsWhyExtractFile returns the file written by the link after addWhyExtractFlag.
+func (linker *baseLinker) unusedNativeDepsWhyExtractFile() android.OptionalPath {
+	return linker.whyExtractFile
+}
+
+// buildUnusedNativeDepsReport creates the rule that analyzes the linked output of the module
+// against its explicit shared and static library dependencies, and returns the report. The static
+// libraries are only analyzed if the link recorded why it extracted each archive member.
+func (c *Module) buildUnusedNativeDepsReport(ctx ModuleContext, binary android.Path) android.Path {
+	linker, ok := c.linker.(interface {
+		explicitLibs() ([]string, []string)
+		unusedNativeDepsWhyExtractFile() android.OptionalPath
+	})
+	if !ok {
+		return nil
+	}
+	sharedLibs, staticLibs := linker.explicitLibs()
+	whyExtract := linker.unusedNativeDepsWhyExtractFile()
+
+	// Entries may have a version, e.g. libfoo#30.
+	names := func(libs []string) map[string]bool {
+		m := make(map[string]bool)
+		for _, lib := range libs {
+			name, _ := StubsLibNameAndVersion(lib)
+			m[name] = true
+		}
+		return m
+	}
+	explicitShared, explicitStatic := names(sharedLibs), names(staticLibs)
+
+	var libs []string
+	var implicits android.Paths
+	ctx.VisitDirectDeps(func(dep android.Module) {
+		tag, ok := ctx.OtherModuleDependencyTag(dep).(libraryDependencyTag)
+		if !ok {
+			return
+		}
+		name := android.RemoveOptionalPrebuiltPrefix(ctx.OtherModuleName(dep))
+		if tag.shared() && explicitShared[name] && ctx.OtherModuleHasProvider(dep, SharedLibraryInfoProvider) {
+			info := ctx.OtherModuleProvider(dep, SharedLibraryInfoProvider).(SharedLibraryInfo)
+			libs = append(libs, "--shared-lib "+name+":"+info.SharedLibrary.String())
+			implicits = append(implicits, info.SharedLibrary)
+		} else if whyExtract.Valid() && tag.static() && !tag.wholeStatic && explicitStatic[name] && ctx.OtherModuleHasProvider(dep, StaticLibraryInfoProvider) {
+			info := ctx.OtherModuleProvider(dep, StaticLibraryInfoProvider).(StaticLibraryInfo)
+			libs = append(libs, "--static-lib "+name+":"+info.StaticLibrary.String())
+			implicits = append(implicits, info.StaticLibrary)
+		}
+	})
+	if len(libs) == 0 {
+		return nil
+	}
+
+	whyExtractArg := ""
+	if whyExtract.Valid() {
+		whyExtractArg = "--why-extract " + whyExtract.String()
+		implicits = append(implicits, whyExtract.Path())
+	}
+
+	report := android.PathForModuleOut(ctx, "unused_native_deps.json")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        unusedNativeDeps,
+		Description: "find unused native deps " + ctx.ModuleName(),
+		Output:      report,
+		Input:       binary,
+		Implicits:   implicits,
+		Args: map[string]string{
+			"module":     ctx.ModuleName(),
+			"libs":       strings.Join(libs, " "),
+			"whyExtract": whyExtractArg,
+		},
+	})
+	return report
+}
+
+func unusedNativeDepsSingletonFactory() android.Singleton {
+	return &unusedNativeDepsSingleton{}
+}
+
+type unusedNativeDepsSingleton struct{}
+
+// GenerateBuildActions merges the reports of all the variants of all the modules into the
+// dependencies that no variant uses.
+func (s *unusedNativeDepsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !unusedNativeDepsEnabled(ctx) {
+		return
+	}
+
+	var reports android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		if c, ok := module.(*Module); ok && c.Enabled() && c.unusedNativeDepsReport != nil {
+			reports = append(reports, c.unusedNativeDepsReport)
+		}
+	})
+
+	output := android.PathForOutput(ctx, "unused_native_deps", "unused_native_deps.json")
+	rspFile := android.PathForOutput(ctx, "unused_native_deps", "reports.rsp")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("unused_native_deps").
+		Text("merge").
+		FlagWithOutput("--output ", output).
+		FlagWithRspFileInputList("--reports ", rspFile, reports)
+	rule.Build("unused_native_deps", "merge unused native deps")
+
+	ctx.Phony("unused-native-deps", output)
+}
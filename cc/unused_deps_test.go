@@ -0,0 +1,80 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestUnusedNativeDeps(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeEnv(map[string]string{
+			"FIND_UNUSED_NATIVE_DEPS": "true",
+		}),
+	).RunTestWithBp(t, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.cpp"],
+			shared_libs: ["libbar"],
+			static_libs: ["libbaz"],
+		}
+
+		cc_library_shared {
+			name: "libbar",
+			srcs: ["bar.cpp"],
+		}
+
+		cc_library_static {
+			name: "libbaz",
+			srcs: ["baz.cpp"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a")
+	report := foo.Output("unused_native_deps.json")
+	android.AssertPathRelativeToTopEquals(t, "analyzed binary",
+		"out/soong/.intermediates/foo/android_arm64_armv8-a/unstripped/foo", report.Input)
+
+	// Only the explicit dependencies are analyzed, not the implicit ones like libc.
+	android.AssertStringEquals(t, "analyzed libs",
+		"--shared-lib libbar:out/soong/.intermediates/libbar/android_arm64_armv8-a_shared/libbar.so "+
+			"--static-lib libbaz:out/soong/.intermediates/libbaz/android_arm64_armv8-a_static/libbaz.a",
+		android.StringRelativeToTop(result.Config, report.Args["libs"]))
+
+	// The static libraries are analyzed from the archive members extracted by the link.
+	whyExtract := "out/soong/.intermediates/foo/android_arm64_armv8-a/why_extract.txt"
+	android.AssertStringEquals(t, "why extract arg", "--why-extract "+whyExtract,
+		android.StringRelativeToTop(result.Config, report.Args["whyExtract"]))
+	android.AssertStringListContains(t, "report implicits", report.Implicits.Strings(), whyExtract)
+	link := foo.Rule("ld")
+	android.AssertStringListContains(t, "link implicit outputs", link.ImplicitOutputs.Strings(), whyExtract)
+	android.AssertStringDoesContain(t, "link flags",
+		android.StringRelativeToTop(result.Config, link.Args["ldFlags"]), "-Wl,--why-extract="+whyExtract)
+
+	// Static libraries are not linked, so they are not analyzed.
+	libbaz := result.ModuleForTests("libbaz", "android_arm64_armv8-a_static")
+	if rule := libbaz.MaybeOutput("unused_native_deps.json"); rule.Rule != nil {
+		t.Errorf("expected no unused native deps analysis for libbaz")
+	}
+
+	merge := result.SingletonForTests("unused_native_deps").Output("unused_native_deps/unused_native_deps.json")
+	android.AssertStringListContains(t, "merged reports",
+		append(merge.Inputs.Strings(), merge.Implicits.Strings()...),
+		"out/soong/.intermediates/foo/android_arm64_armv8-a/unused_native_deps.json")
+}
@@ -70,6 +70,13 @@ func flagsToBuilderFlags(in Flags) builderFlags {
 		sAbiDump:      in.SAbiDump,
 		emitXrefs:     in.EmitXrefs,
 
+		includeLayeringCheck: in.IncludeLayeringCheck,
+
+		profileStalenessCheck: in.ProfileStalenessCheck != "",
+
+		distributedThinLTO: in.DistributedThinLTO,
+		thinLTOArchiveObjs: in.ThinLTOArchiveObjs,
+
 		systemIncludeFlags: strings.Join(in.SystemIncludeFlags, " "),
 
 		assemblerWithCpp: in.AssemblerWithCpp,
@@ -80,6 +87,8 @@ func flagsToBuilderFlags(in Flags) builderFlags {
 
 		yacc: in.Yacc,
 		lex:  in.Lex,
+
+		pch: in.Pch,
 	}
 }
 
@@ -0,0 +1,91 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// diffManifests writes the entries that were added, removed or changed between two manifests to
+// w, sorted by path.  Changed entries list each field that differs.  It returns true if the
+// manifests differ.
+func diffManifests(w io.Writer, oldManifest, newManifest *Manifest) bool {
+	oldEntries := make(map[string]Entry, len(oldManifest.Entries))
+	for _, e := range oldManifest.Entries {
+		oldEntries[e.Path] = e
+	}
+	newEntries := make(map[string]Entry, len(newManifest.Entries))
+	for _, e := range newManifest.Entries {
+		newEntries[e.Path] = e
+	}
+
+	var paths []string
+	for _, e := range oldManifest.Entries {
+		paths = append(paths, e.Path)
+	}
+	for _, e := range newManifest.Entries {
+		if _, ok := oldEntries[e.Path]; !ok {
+			paths = append(paths, e.Path)
+		}
+	}
+	sort.Strings(paths)
+
+	different := false
+	for _, path := range paths {
+		oldEntry, inOld := oldEntries[path]
+		newEntry, inNew := newEntries[path]
+		switch {
+		case !inOld:
+			fmt.Fprintf(w, "+ %s %s (%s)\n", newEntry.Type, path, newEntry.Module)
+			different = true
+		case !inNew:
+			fmt.Fprintf(w, "- %s %s (%s)\n", oldEntry.Type, path, oldEntry.Module)
+			different = true
+		default:
+			if changes := entryChanges(oldEntry, newEntry); len(changes) > 0 {
+				fmt.Fprintf(w, "~ %s %s (%s): %s\n", newEntry.Type, path, newEntry.Module,
+					strings.Join(changes, ", "))
+				different = true
+			}
+		}
+	}
+	return different
+}
+
+// entryChanges returns a description of every field that differs between two entries for the
+// same path.
+func entryChanges(oldEntry, newEntry Entry) []string {
+	var changes []string
+	oldValue := reflect.ValueOf(oldEntry)
+	newValue := reflect.ValueOf(newEntry)
+	typ := oldValue.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Name == "Path" {
+			continue
+		}
+		o := oldValue.Field(i).Interface()
+		n := newValue.Field(i).Interface()
+		if o != n {
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			changes = append(changes, fmt.Sprintf("%s %v -> %v", name, o, n))
+		}
+	}
+	return changes
+}
@@ -0,0 +1,54 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_diffManifests(t *testing.T) {
+	oldManifest := &Manifest{
+		Entries: []Entry{
+			{Path: "/system/bin/bar", Type: "file", Mode: "0755", Size: 10, Sha256: "aa", Module: "bar"},
+			{Path: "/system/bin/baz", Type: "file", Mode: "0755", Size: 10, Sha256: "bb", Module: "baz"},
+			{Path: "/system/bin/foo", Type: "file", Mode: "0755", Size: 10, Sha256: "cc", Module: "foo"},
+		},
+	}
+	newManifest := &Manifest{
+		Entries: []Entry{
+			{Path: "/system/bin/bar", Type: "file", Mode: "0750", Size: 12, Sha256: "dd", Module: "bar"},
+			{Path: "/system/bin/foo", Type: "file", Mode: "0755", Size: 10, Sha256: "cc", Module: "foo"},
+			{Path: "/system/bin/qux", Type: "file", Mode: "0755", Size: 10, Sha256: "ee", Module: "qux"},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if !diffManifests(buf, oldManifest, newManifest) {
+		t.Errorf("expected manifests to differ")
+	}
+
+	want := "~ file /system/bin/bar (bar): mode 0755 -> 0750, size 10 -> 12, sha256 aa -> dd\n" +
+		"- file /system/bin/baz (baz)\n" +
+		"+ file /system/bin/qux (qux)\n"
+	if buf.String() != want {
+		t.Errorf("incorrect diff, want:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	if diffManifests(buf, newManifest, newManifest) {
+		t.Errorf("expected identical manifests not to differ, got:\n%s", buf.String())
+	}
+}
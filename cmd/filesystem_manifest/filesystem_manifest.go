@@ -0,0 +1,122 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// This tool is used to generate a manifest of every path in a filesystem image, with its owner,
+// mode, SELinux label, size, SHA-256 and the module that installed it, from the staged root
+// directory of the image and the output of the fs_config host tool.  It can also diff two
+// manifests.
+
+func main() {
+	flags := flag.NewFlagSet("flags", flag.ExitOnError)
+
+	// Hide the flag package to prevent accidental references to flag instead of flags.
+	flag := struct{}{}
+	_ = flag
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of %s:\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "  %s -root <dir> -fs_config <file> [-modules <file>] [-default_module <name>] -o <output file>\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "  %s -diff <old manifest> <new manifest>\n", os.Args[0])
+		fmt.Fprintln(flags.Output())
+
+		flags.PrintDefaults()
+	}
+
+	root := flags.String("root", "", "staged root directory of the filesystem image")
+	fsConfigFile := flags.String("fs_config", "", "output of fs_config for every path in the root directory")
+	modulesFile := flags.String("modules", "", "file with a \"<path> <module>\" line for every path installed by a module")
+	defaultModule := flags.String("default_module", "", "module to attribute paths missing from -modules to")
+	output := flags.String("o", "", "output manifest")
+	diff := flags.Bool("diff", false, "diff two manifests, exiting with status 1 if they differ")
+
+	flags.Parse(os.Args[1:])
+
+	if *diff {
+		if flags.NArg() != 2 {
+			flags.Usage()
+			os.Exit(1)
+		}
+		oldManifest, err := readManifest(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		newManifest, err := readManifest(flags.Arg(1))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if diffManifests(os.Stdout, oldManifest, newManifest) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *root == "" || *fsConfigFile == "" || *output == "" || flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	err := generate(*root, *fsConfigFile, *modulesFile, *defaultModule, *output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate manifest: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func generate(root, fsConfigFile, modulesFile, defaultModule, output string) error {
+	f, err := os.Open(fsConfigFile)
+	if err != nil {
+		return err
+	}
+	fsConfig, err := parseFsConfig(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", fsConfigFile, err)
+	}
+
+	modules := make(map[string]string)
+	if modulesFile != "" {
+		f, err := os.Open(modulesFile)
+		if err != nil {
+			return err
+		}
+		modules, err = parseModules(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", modulesFile, err)
+		}
+	}
+
+	manifest, err := generateManifest(root, fsConfig, modules, defaultModule)
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeManifest(buf, manifest); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(output, buf.Bytes(), 0666)
+}
@@ -0,0 +1,248 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Entry describes a single path in a filesystem image.
+type Entry struct {
+	// Path is the absolute path of the entry on the device, relative to the root of the image.
+	Path string `json:"path"`
+
+	// Type is one of "file", "dir" or "symlink".
+	Type string `json:"type"`
+
+	Uid  int    `json:"uid"`
+	Gid  int    `json:"gid"`
+	Mode string `json:"mode"`
+
+	// SELinuxLabel is the label assigned by the file_contexts of the image, if it has any.
+	SELinuxLabel string `json:"selinux_label,omitempty"`
+
+	// Capabilities are the file capabilities assigned by fs_config, if they are not empty.
+	Capabilities string `json:"capabilities,omitempty"`
+
+	// Size and Sha256 are only set for files.
+	Size   int64  `json:"size,omitempty"`
+	Sha256 string `json:"sha256,omitempty"`
+
+	// SymlinkTarget is only set for symlinks.
+	SymlinkTarget string `json:"symlink_target,omitempty"`
+
+	// Module is the name of the module that installed the entry.
+	Module string `json:"module"`
+}
+
+// Manifest is a listing of every path in a filesystem image, sorted by path.
+type Manifest struct {
+	Entries []Entry `json:"entries"`
+}
+
+// fsConfigEntry is a line of the output of the fs_config host tool.
+type fsConfigEntry struct {
+	uid, gid     int
+	mode         string
+	selinuxLabel string
+	capabilities string
+}
+
+// parseFsConfig parses the output of `fs_config -C [-S <file_contexts>]`, which has one
+// "<path> <uid> <gid> <mode> [selabel=<label>] [capabilities=<caps>]" line per input path.
+// Directories are listed with a trailing slash, which is removed from the returned keys.
+func parseFsConfig(r io.Reader) (map[string]fsConfigEntry, error) {
+	ret := make(map[string]fsConfigEntry)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("malformed fs_config line %q", s.Text())
+		}
+		uid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed uid in fs_config line %q: %w", s.Text(), err)
+		}
+		gid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("malformed gid in fs_config line %q: %w", s.Text(), err)
+		}
+		mode, err := strconv.ParseUint(fields[3], 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("malformed mode in fs_config line %q: %w", s.Text(), err)
+		}
+		entry := fsConfigEntry{
+			uid:  uid,
+			gid:  gid,
+			mode: fmt.Sprintf("%04o", mode),
+		}
+		for _, field := range fields[4:] {
+			if label := strings.TrimPrefix(field, "selabel="); label != field {
+				entry.selinuxLabel = label
+			} else if caps := strings.TrimPrefix(field, "capabilities="); caps != field {
+				if caps != "0" && caps != "0x0" {
+					entry.capabilities = caps
+				}
+			}
+		}
+		ret[strings.TrimSuffix(fields[0], "/")] = entry
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// parseModules parses a file with a "<path> <module>" line for every path installed by a
+// module.
+func parseModules(r io.Reader) (map[string]string, error) {
+	ret := make(map[string]string)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("malformed modules line %q", s.Text())
+		}
+		ret[filepath.Clean(fields[0])] = fields[1]
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// generateManifest walks the staged root directory of a filesystem image and returns an entry for
+// every path in it.  Paths that are not listed in modules are attributed to defaultModule, which
+// is usually the filesystem module itself.
+func generateManifest(root string, fsConfig map[string]fsConfigEntry, modules map[string]string,
+	defaultModule string) (*Manifest, error) {
+
+	manifest := &Manifest{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		config, ok := fsConfig[rel]
+		if !ok {
+			return fmt.Errorf("%s is missing from the fs_config output", rel)
+		}
+
+		entry := Entry{
+			Path:         "/" + filepath.ToSlash(rel),
+			Uid:          config.uid,
+			Gid:          config.gid,
+			Mode:         config.mode,
+			SELinuxLabel: config.selinuxLabel,
+			Capabilities: config.capabilities,
+			Module:       defaultModule,
+		}
+		if module, ok := modules[rel]; ok {
+			entry.Module = module
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			entry.Type = "symlink"
+			entry.SymlinkTarget, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		case info.IsDir():
+			entry.Type = "dir"
+		case info.Mode().IsRegular():
+			entry.Type = "file"
+			entry.Size = info.Size()
+			entry.Sha256, err = sha256File(path)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s has unsupported file type %s", rel, info.Mode().Type())
+		}
+
+		manifest.Entries = append(manifest.Entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(manifest.Entries, func(i, j int) bool {
+		return manifest.Entries[i].Path < manifest.Entries[j].Path
+	})
+
+	return manifest, nil
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeManifest writes a manifest as indented JSON, one field per line, so that textual diffs of
+// manifests are readable too.
+func writeManifest(w io.Writer, manifest *Manifest) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(manifest)
+}
+
+// readManifest reads a manifest written by writeManifest.
+func readManifest(filename string) (*Manifest, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	manifest := &Manifest{}
+	if err := json.NewDecoder(f).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	return manifest, nil
+}
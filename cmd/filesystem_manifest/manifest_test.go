@@ -0,0 +1,118 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseFsConfig(t *testing.T) {
+	input := `system/ 0 0 755 selabel=u:object_r:system_file:s0 capabilities=0x0
+system/bin/foo 0 2000 755 selabel=u:object_r:foo_exec:s0 capabilities=0x1000000
+system/etc/foo.rc 0 0 644
+`
+	got, err := parseFsConfig(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := map[string]fsConfigEntry{
+		"system": {
+			uid: 0, gid: 0, mode: "0755", selinuxLabel: "u:object_r:system_file:s0",
+		},
+		"system/bin/foo": {
+			uid: 0, gid: 2000, mode: "0755", selinuxLabel: "u:object_r:foo_exec:s0",
+			capabilities: "0x1000000",
+		},
+		"system/etc/foo.rc": {
+			uid: 0, gid: 0, mode: "0644",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect fs_config, want %#v got %#v", want, got)
+	}
+
+	if _, err := parseFsConfig(strings.NewReader("system/bin/foo 0 root 755\n")); err == nil {
+		t.Errorf("expected error parsing malformed gid")
+	}
+}
+
+func Test_generateManifest(t *testing.T) {
+	root, err := os.MkdirTemp("", "test_generateManifest")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := os.MkdirAll(filepath.Join(root, "system", "bin"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "system", "bin", "foo"), []byte("foo"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/system/bin/foo", filepath.Join(root, "system", "bin", "bar")); err != nil {
+		t.Fatal(err)
+	}
+
+	fsConfig := map[string]fsConfigEntry{
+		"system":         {uid: 0, gid: 0, mode: "0755", selinuxLabel: "u:object_r:system_file:s0"},
+		"system/bin":     {uid: 0, gid: 2000, mode: "0751", selinuxLabel: "u:object_r:system_file:s0"},
+		"system/bin/foo": {uid: 0, gid: 2000, mode: "0755", selinuxLabel: "u:object_r:foo_exec:s0"},
+		"system/bin/bar": {uid: 0, gid: 2000, mode: "0755", selinuxLabel: "u:object_r:system_file:s0"},
+	}
+	modules := map[string]string{
+		"system/bin/foo": "foo",
+	}
+
+	manifest, err := generateManifest(root, fsConfig, modules, "myfilesystem")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []Entry{
+		{Path: "/system", Type: "dir", Uid: 0, Gid: 0, Mode: "0755",
+			SELinuxLabel: "u:object_r:system_file:s0", Module: "myfilesystem"},
+		{Path: "/system/bin", Type: "dir", Uid: 0, Gid: 2000, Mode: "0751",
+			SELinuxLabel: "u:object_r:system_file:s0", Module: "myfilesystem"},
+		{Path: "/system/bin/bar", Type: "symlink", Uid: 0, Gid: 2000, Mode: "0755",
+			SELinuxLabel: "u:object_r:system_file:s0", SymlinkTarget: "/system/bin/foo",
+			Module: "myfilesystem"},
+		{Path: "/system/bin/foo", Type: "file", Uid: 0, Gid: 2000, Mode: "0755",
+			SELinuxLabel: "u:object_r:foo_exec:s0", Size: 3,
+			Sha256: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+			Module: "foo"},
+	}
+	if !reflect.DeepEqual(manifest.Entries, want) {
+		t.Errorf("incorrect manifest, want %#v got %#v", want, manifest.Entries)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeManifest(buf, manifest); err != nil {
+		t.Fatalf("unexpected error writing manifest: %s", err)
+	}
+	if !strings.Contains(buf.String(), `"path": "/system/bin/foo"`) {
+		t.Errorf("expected one field per line in manifest, got %s", buf.String())
+	}
+
+	delete(fsConfig, "system/bin/bar")
+	if _, err := generateManifest(root, fsConfig, modules, "myfilesystem"); err == nil {
+		t.Errorf("expected error for path missing from fs_config")
+	}
+}
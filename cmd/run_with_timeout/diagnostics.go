@@ -0,0 +1,368 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// clockTicksPerSecond is the unit of the times in /proc/<pid>/stat.  USER_HZ is 100 on every
+// architecture Linux supports.
+const clockTicksPerSecond = 100
+
+// timeoutDiagnostics is the structured file written when the wrapped command times out.
+type timeoutDiagnostics struct {
+	Command   []string  `json:"command"`
+	Timeout   string    `json:"timeout"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// Processes is the tree of processes started by the command, parents before children, as
+	// it was when the timeout expired.
+	Processes []processInfo `json:"processes"`
+
+	// Errors lists anything that could not be collected.
+	Errors []string `json:"errors,omitempty"`
+}
+
+type processInfo struct {
+	Pid         int      `json:"pid"`
+	Ppid        int      `json:"ppid"`
+	State       string   `json:"state"`
+	CommandLine []string `json:"command_line"`
+	Executable  string   `json:"executable,omitempty"`
+
+	// Environment is the environment of the process as "<name>=<value>", sorted, with the values
+	// of variables that may hold credentials redacted.
+	Environment []string `json:"environment,omitempty"`
+
+	// CpuTime is the user and system CPU time used by the process.
+	CpuTime string `json:"cpu_time"`
+	// Elapsed is the wall time since the process started.
+	Elapsed string `json:"elapsed"`
+
+	// OpenFiles lists the open file descriptors of the process as "<fd> -> <target>".
+	OpenFiles []string `json:"open_files,omitempty"`
+
+	// Runtime is "java" or "go" if the process was recognized as one that dumps its stacks
+	// when it receives SIGQUIT.
+	Runtime string `json:"runtime,omitempty"`
+	// SentSigquit is true if SIGQUIT was sent to the process to make it dump its stacks.
+	SentSigquit bool `json:"sent_sigquit,omitempty"`
+}
+
+// procStat holds the fields of /proc/<pid>/stat that are used for the diagnostics.
+type procStat struct {
+	pid, ppid    int
+	comm, state  string
+	utime, stime uint64
+	starttime    uint64
+}
+
+// parseProcStat parses the contents of /proc/<pid>/stat.  The comm field is in parentheses and
+// may itself contain spaces and parentheses, so the fields are counted from the last ')'.
+func parseProcStat(data string) (procStat, error) {
+	open := strings.IndexByte(data, '(')
+	close := strings.LastIndexByte(data, ')')
+	if open < 0 || close < open {
+		return procStat{}, fmt.Errorf("malformed stat %q", data)
+	}
+
+	var stat procStat
+	var err error
+	stat.pid, err = strconv.Atoi(strings.TrimSpace(data[:open]))
+	if err != nil {
+		return procStat{}, fmt.Errorf("malformed pid in stat %q: %w", data, err)
+	}
+	stat.comm = data[open+1 : close]
+
+	// Fields after comm, starting from field 3 (state).
+	fields := strings.Fields(data[close+1:])
+	field := func(n int) string { return fields[n-3] }
+	if len(fields) < 22-2 {
+		return procStat{}, fmt.Errorf("short stat %q", data)
+	}
+	stat.state = field(3)
+	if stat.ppid, err = strconv.Atoi(field(4)); err != nil {
+		return procStat{}, fmt.Errorf("malformed ppid in stat %q: %w", data, err)
+	}
+	if stat.utime, err = strconv.ParseUint(field(14), 10, 64); err != nil {
+		return procStat{}, fmt.Errorf("malformed utime in stat %q: %w", data, err)
+	}
+	if stat.stime, err = strconv.ParseUint(field(15), 10, 64); err != nil {
+		return procStat{}, fmt.Errorf("malformed stime in stat %q: %w", data, err)
+	}
+	if stat.starttime, err = strconv.ParseUint(field(22), 10, 64); err != nil {
+		return procStat{}, fmt.Errorf("malformed starttime in stat %q: %w", data, err)
+	}
+	return stat, nil
+}
+
+// readProcStats reads /proc/<pid>/stat for every process on the system.
+func readProcStats(procDir string) (map[int]procStat, error) {
+	entries, err := ioutil.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[int]procStat)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(procDir, entry.Name(), "stat"))
+		if err != nil {
+			// The process may have exited since the directory was listed.
+			continue
+		}
+		stat, err := parseProcStat(string(data))
+		if err != nil {
+			return nil, err
+		}
+		stats[pid] = stat
+	}
+	return stats, nil
+}
+
+// processTree returns the pids of root and all of its descendants, parents before children.
+func processTree(root int, stats map[int]procStat) []int {
+	children := make(map[int][]int)
+	for pid, stat := range stats {
+		children[stat.ppid] = append(children[stat.ppid], pid)
+	}
+
+	var pids []int
+	queue := []int{root}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		if _, ok := stats[pid]; !ok {
+			continue
+		}
+		pids = append(pids, pid)
+		sort.Ints(children[pid])
+		queue = append(queue, children[pid]...)
+	}
+	return pids
+}
+
+// uptime returns the time since boot from /proc/uptime.
+func uptime(procDir string) (time.Duration, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procDir, "uptime"))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("malformed uptime %q", data)
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed uptime %q: %w", data, err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func ticksToDuration(ticks uint64) time.Duration {
+	return time.Duration(ticks) * time.Second / clockTicksPerSecond
+}
+
+// secretEnvironmentNames are the substrings of the names of environment variables whose values are
+// redacted from the diagnostics, as they commonly hold credentials.
+var secretEnvironmentNames = []string{
+	"AUTH", "COOKIE", "CREDENTIAL", "KEY", "PASSWD", "PASSWORD", "SECRET", "SESSION", "TOKEN",
+}
+
+// redactedValue replaces the values of the environment variables in secretEnvironmentNames.
+const redactedValue = "<redacted>"
+
+// redactEnvironment returns the environment with the values of the variables that may hold
+// credentials replaced with redactedValue.
+func redactEnvironment(environ []string) []string {
+	redacted := make([]string, 0, len(environ))
+	for _, e := range environ {
+		name := strings.SplitN(e, "=", 2)[0]
+		upper := strings.ToUpper(name)
+		for _, secret := range secretEnvironmentNames {
+			if strings.Contains(upper, secret) {
+				e = name + "=" + redactedValue
+				break
+			}
+		}
+		redacted = append(redacted, e)
+	}
+	return redacted
+}
+
+// describeProcess collects the command line, environment, CPU and elapsed time, open files and
+// runtime of a process.  Errors are recorded in the diagnostics rather than returned, as a process that exits
+// while it is being described should not prevent describing the others.
+func describeProcess(procDir string, stat procStat, sinceBoot time.Duration, diag *timeoutDiagnostics) processInfo {
+	pidDir := filepath.Join(procDir, strconv.Itoa(stat.pid))
+	info := processInfo{
+		Pid:     stat.pid,
+		Ppid:    stat.ppid,
+		State:   stat.state,
+		CpuTime: ticksToDuration(stat.utime + stat.stime).String(),
+	}
+	if sinceBoot > 0 {
+		info.Elapsed = (sinceBoot - ticksToDuration(stat.starttime)).Round(10 * time.Millisecond).String()
+	}
+
+	if cmdline, err := ioutil.ReadFile(filepath.Join(pidDir, "cmdline")); err == nil {
+		cmdline = bytes.TrimSuffix(cmdline, []byte{0})
+		if len(cmdline) > 0 {
+			info.CommandLine = strings.Split(string(cmdline), "\x00")
+		}
+	}
+	if len(info.CommandLine) == 0 {
+		// Kernel threads and zombies have an empty command line.
+		info.CommandLine = []string{"[" + stat.comm + "]"}
+	}
+
+	if exe, err := os.Readlink(filepath.Join(pidDir, "exe")); err == nil {
+		info.Executable = exe
+	}
+
+	if environ, err := ioutil.ReadFile(filepath.Join(pidDir, "environ")); err == nil {
+		environ = bytes.TrimSuffix(environ, []byte{0})
+		if len(environ) > 0 {
+			info.Environment = redactEnvironment(strings.Split(string(environ), "\x00"))
+			sort.Strings(info.Environment)
+		}
+	} else if !os.IsNotExist(err) {
+		diag.Errors = append(diag.Errors, fmt.Sprintf("pid %d: failed to read environment: %s", stat.pid, err))
+	}
+
+	fdDir := filepath.Join(pidDir, "fd")
+	if fds, err := ioutil.ReadDir(fdDir); err == nil {
+		var fdNums []int
+		for _, fd := range fds {
+			if n, err := strconv.Atoi(fd.Name()); err == nil {
+				fdNums = append(fdNums, n)
+			}
+		}
+		sort.Ints(fdNums)
+		for _, fd := range fdNums {
+			target, err := os.Readlink(filepath.Join(fdDir, strconv.Itoa(fd)))
+			if err != nil {
+				continue
+			}
+			info.OpenFiles = append(info.OpenFiles, fmt.Sprintf("%d -> %s", fd, target))
+		}
+	} else if !os.IsNotExist(err) {
+		diag.Errors = append(diag.Errors, fmt.Sprintf("pid %d: failed to list open files: %s", stat.pid, err))
+	}
+
+	info.Runtime = processRuntime(pidDir, info)
+	return info
+}
+
+// processRuntime returns "java" for Java processes and "go" for Go binaries, which both dump the
+// stacks of all of their threads when they receive SIGQUIT.
+func processRuntime(pidDir string, info processInfo) string {
+	for _, path := range []string{info.Executable, info.CommandLine[0]} {
+		if filepath.Base(path) == "java" {
+			return "java"
+		}
+	}
+	if _, err := buildinfo.ReadFile(filepath.Join(pidDir, "exe")); err == nil {
+		return "go"
+	}
+	return ""
+}
+
+// collectTimeoutDiagnostics snapshots the process tree rooted at pid.  If dumpStacks is true it then
+// sends SIGQUIT to every Java and Go process in it and waits for stackDumpWait so that they can dump
+// their stacks to their stdout or stderr.
+func collectTimeoutDiagnostics(procDir string, pid int, command []string, timeout time.Duration,
+	dumpStacks bool, stackDumpWait time.Duration) *timeoutDiagnostics {
+
+	diag := &timeoutDiagnostics{
+		Command:   command,
+		Timeout:   timeout.String(),
+		Timestamp: time.Now(),
+	}
+
+	stats, err := readProcStats(procDir)
+	if err != nil {
+		diag.Errors = append(diag.Errors, fmt.Sprintf("failed to read process list: %s", err))
+		return diag
+	}
+	sinceBoot, err := uptime(procDir)
+	if err != nil {
+		diag.Errors = append(diag.Errors, fmt.Sprintf("failed to read uptime: %s", err))
+	}
+
+	for _, p := range processTree(pid, stats) {
+		diag.Processes = append(diag.Processes, describeProcess(procDir, stats[p], sinceBoot, diag))
+	}
+
+	if !dumpStacks {
+		return diag
+	}
+
+	sentSigquit := false
+	for i := range diag.Processes {
+		p := &diag.Processes[i]
+		if p.Runtime == "" {
+			continue
+		}
+		if err := syscall.Kill(p.Pid, syscall.SIGQUIT); err != nil {
+			diag.Errors = append(diag.Errors, fmt.Sprintf("pid %d: failed to send SIGQUIT: %s", p.Pid, err))
+			continue
+		}
+		p.SentSigquit = true
+		sentSigquit = true
+	}
+	if sentSigquit {
+		time.Sleep(stackDumpWait)
+	}
+
+	return diag
+}
+
+// defaultTimeoutDiagnosticsFile returns the file next to the test output to write the diagnostics
+// to when stdout is redirected to a file, or an empty string otherwise.
+func defaultTimeoutDiagnosticsFile(stdout *os.File) string {
+	info, err := stdout.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	output, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", stdout.Fd()))
+	if err != nil || !filepath.IsAbs(output) {
+		return ""
+	}
+	return output + ".timeout_diagnostics.json"
+}
+
+// writeTimeoutDiagnostics writes the diagnostics to a file as JSON.
+func writeTimeoutDiagnostics(filename string, diag *timeoutDiagnostics) error {
+	data, err := json.MarshalIndent(diag, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0666)
+}
@@ -0,0 +1,187 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseProcStat(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    procStat
+		wantErr bool
+	}{
+		{
+			name: "simple",
+			data: "1234 (sleep) S 1000 1234 1000 0 -1 4194304 100 0 0 0 12 34 0 0 20 0 1 0 5678 0 0\n",
+			want: procStat{pid: 1234, ppid: 1000, comm: "sleep", state: "S", utime: 12, stime: 34, starttime: 5678},
+		},
+		{
+			name: "comm with spaces and parens",
+			data: "42 (a (b) c) R 1 42 1 0 -1 0 0 0 0 0 1 2 0 0 20 0 1 0 3 0 0\n",
+			want: procStat{pid: 42, ppid: 1, comm: "a (b) c", state: "R", utime: 1, stime: 2, starttime: 3},
+		},
+		{
+			name:    "truncated",
+			data:    "42 (sleep) S 1",
+			wantErr: true,
+		},
+		{
+			name:    "no comm",
+			data:    "42 sleep S 1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProcStat(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProcStat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProcStat() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_processTree(t *testing.T) {
+	stats := map[int]procStat{
+		1:  {pid: 1, ppid: 0},
+		10: {pid: 10, ppid: 1},
+		11: {pid: 11, ppid: 10},
+		12: {pid: 12, ppid: 10},
+		13: {pid: 13, ppid: 12},
+		20: {pid: 20, ppid: 1},
+	}
+	got := processTree(10, stats)
+	want := []int{10, 11, 12, 13}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("processTree() = %v, want %v", got, want)
+	}
+
+	if got := processTree(99, stats); len(got) != 0 {
+		t.Errorf("processTree() of missing pid = %v, want []", got)
+	}
+}
+
+func Test_runWithTimeout_diagnostics(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("/proc is not available")
+	}
+
+	t.Setenv("RUN_WITH_TIMEOUT_TEST", "foo")
+	t.Setenv("RUN_WITH_TIMEOUT_TEST_TOKEN", "secret")
+	diagnosticsFile := filepath.Join(t.TempDir(), "diagnostics.json")
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	err := runWithTimeout("sh", []string{"-c", "sleep 10 < /dev/null; echo foo"}, 500*time.Millisecond, "",
+		diagnosticsFile, true, 0, nil, stdout, stderr)
+	if err == nil {
+		t.Fatalf("runWithTimeout() expected timeout error")
+	}
+	if !strings.Contains(stderr.String(), "writing timeout diagnostics to "+diagnosticsFile) {
+		t.Errorf("runWithTimeout() stderr %q does not mention the diagnostics file", stderr.String())
+	}
+
+	data, err := ioutil.ReadFile(diagnosticsFile)
+	if err != nil {
+		t.Fatalf("failed to read diagnostics: %s", err)
+	}
+	var diag timeoutDiagnostics
+	if err := json.Unmarshal(data, &diag); err != nil {
+		t.Fatalf("failed to parse diagnostics: %s", err)
+	}
+
+	if len(diag.Processes) != 2 {
+		t.Fatalf("expected 2 processes, got %d: %s", len(diag.Processes), data)
+	}
+	sh, sleep := diag.Processes[0], diag.Processes[1]
+	if sh.CommandLine[0] != "sh" {
+		t.Errorf("expected first process to be sh, got %q", sh.CommandLine)
+	}
+	if sleep.Ppid != sh.Pid {
+		t.Errorf("expected sleep ppid %d to be sh pid %d", sleep.Ppid, sh.Pid)
+	}
+	if !reflect.DeepEqual(sleep.CommandLine, []string{"sleep", "10"}) {
+		t.Errorf("expected sleep command line, got %q", sleep.CommandLine)
+	}
+	for _, want := range []string{"RUN_WITH_TIMEOUT_TEST=foo", "RUN_WITH_TIMEOUT_TEST_TOKEN=<redacted>"} {
+		found := false
+		for _, e := range sleep.Environment {
+			if e == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected sleep environment to contain %s, got %q", want, sleep.Environment)
+		}
+	}
+	foundDevNull := false
+	for _, f := range sleep.OpenFiles {
+		if f == "0 -> /dev/null" {
+			foundDevNull = true
+		}
+	}
+	if !foundDevNull {
+		t.Errorf("expected sleep to have /dev/null open as stdin, got %q", sleep.OpenFiles)
+	}
+	if sleep.Runtime != "" || sleep.SentSigquit {
+		t.Errorf("expected no SIGQUIT to be sent to sleep, got runtime %q", sleep.Runtime)
+	}
+}
+
+func Test_redactEnvironment(t *testing.T) {
+	got := redactEnvironment([]string{"HOME=/home/foo", "GITHUB_TOKEN=abc", "aws_secret_access_key=def", "EMPTY"})
+	want := []string{"HOME=/home/foo", "GITHUB_TOKEN=<redacted>", "aws_secret_access_key=<redacted>", "EMPTY"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("redactEnvironment() = %q, want %q", got, want)
+	}
+}
+
+func Test_defaultTimeoutDiagnosticsFile(t *testing.T) {
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		t.Skip("/proc is not available")
+	}
+
+	output := filepath.Join(t.TempDir(), "test_output.txt")
+	f, err := os.Create(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got, want := defaultTimeoutDiagnosticsFile(f), output+".timeout_diagnostics.json"; got != want {
+		t.Errorf("defaultTimeoutDiagnosticsFile() of a file = %q, want %q", got, want)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if got := defaultTimeoutDiagnosticsFile(w); got != "" {
+		t.Errorf("defaultTimeoutDiagnosticsFile() of a pipe = %q, want \"\"", got)
+	}
+}
@@ -13,7 +13,10 @@
 // limitations under the License.
 
 // run_with_timeout is a utility that can kill a wrapped command after a configurable timeout,
-// optionally running a command to collect debugging information first.
+// optionally running a command to collect debugging information first.  It also records a snapshot
+// of the process tree of the wrapped command to the --timeout_diagnostics file, by default next to
+// the test output when stdout is redirected to a file, and with --dump_stacks asks any Java or Go
+// processes in it to dump their stacks before killing it.
 
 package main
 
@@ -31,10 +34,18 @@ import (
 var (
 	timeout      = flag.Duration("timeout", 0, "time after which to kill command (example: 60s)")
 	onTimeoutCmd = flag.String("on_timeout", "", "command to run with `PID=<pid> sh -c` after timeout.")
+
+	timeoutDiagnosticsFile = flag.String("timeout_diagnostics", "",
+		"file to write a JSON snapshot of the process tree to after timeout (default <stdout file>.timeout_diagnostics.json"+
+			" if stdout is redirected to a file).")
+	dumpStacks = flag.Bool("dump_stacks", false,
+		"send SIGQUIT to Java and Go processes after timeout to dump their stacks.")
+	stackDumpWait = flag.Duration("stack_dump_wait", 5*time.Second,
+		"time to wait after sending SIGQUIT to Java and Go processes for them to dump their stacks, with --dump_stacks.")
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: %s [--timeout N] [--on_timeout CMD] -- command [args...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "usage: %s [--timeout N] [--on_timeout CMD] [--timeout_diagnostics FILE] [--dump_stacks] -- command [args...]\n", os.Args[0])
 	flag.PrintDefaults()
 	fmt.Fprintln(os.Stderr, "run_with_timeout is a utility that can kill a wrapped command after a configurable timeout,")
 	fmt.Fprintln(os.Stderr, "optionally running a command to collect debugging information first.")
@@ -51,8 +62,13 @@ func main() {
 		usage()
 	}
 
+	diagnosticsFile := *timeoutDiagnosticsFile
+	if diagnosticsFile == "" {
+		diagnosticsFile = defaultTimeoutDiagnosticsFile(os.Stdout)
+	}
+
 	err := runWithTimeout(flag.Arg(0), flag.Args()[1:], *timeout, *onTimeoutCmd,
-		os.Stdin, os.Stdout, os.Stderr)
+		diagnosticsFile, *dumpStacks, *stackDumpWait, os.Stdin, os.Stdout, os.Stderr)
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			fmt.Fprintf(os.Stderr, "%s: process exited with error: %s\n", os.Args[0], exitErr.Error())
@@ -88,6 +104,7 @@ func (c *concurrentWriter) Close() {
 }
 
 func runWithTimeout(command string, args []string, timeout time.Duration, onTimeoutCmdStr string,
+	timeoutDiagnosticsFile string, dumpStacks bool, stackDumpWait time.Duration,
 	stdin io.Reader, stdout, stderr io.Writer) error {
 	cmd := exec.Command(command, args...)
 
@@ -131,6 +148,16 @@ func runWithTimeout(command string, args []string, timeout time.Duration, onTime
 	// Process timed out before exiting.
 	defer cmd.Process.Signal(syscall.SIGKILL)
 
+	if timeoutDiagnosticsFile != "" {
+		fmt.Fprintf(concurrentStderr, "%s: writing timeout diagnostics to %s\n", os.Args[0], timeoutDiagnosticsFile)
+		diag := collectTimeoutDiagnostics("/proc", cmd.Process.Pid, append([]string{command}, args...),
+			timeout, dumpStacks, stackDumpWait)
+		err := writeTimeoutDiagnostics(timeoutDiagnosticsFile, diag)
+		if err != nil {
+			fmt.Fprintf(concurrentStderr, "%s: failed to write timeout diagnostics: %s\n", os.Args[0], err)
+		}
+	}
+
 	if onTimeoutCmdStr != "" {
 		fmt.Fprintf(concurrentStderr, "%s: running on_timeout command `%s`\n", os.Args[0], onTimeoutCmdStr)
 		onTimeoutCmd := exec.Command("sh", "-c", onTimeoutCmdStr)
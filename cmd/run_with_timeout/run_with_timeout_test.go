@@ -81,7 +81,7 @@ func Test_runWithTimeout(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			stdout := &bytes.Buffer{}
 			stderr := &bytes.Buffer{}
-			err := runWithTimeout(tt.args.command, tt.args.args, tt.args.timeout, tt.args.onTimeoutCmd, tt.args.stdin, stdout, stderr)
+			err := runWithTimeout(tt.args.command, tt.args.args, tt.args.timeout, tt.args.onTimeoutCmd, "", false, 0, tt.args.stdin, stdout, stderr)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("runWithTimeout() error = %v, wantErr %v", err, tt.wantErr)
 				return
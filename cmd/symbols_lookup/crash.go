@@ -0,0 +1,78 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+var crashIdentifierRegexps = []*regexp.Regexp{
+	// Native frames in tombstones and ANR traces:
+	//   #00 pc 000000000004f0a4  /system/lib64/libc.so (abort+164) (BuildId: 5f0b1d0b...)
+	regexp.MustCompile(`\(BuildId: ([0-9a-fA-F]+)\)`),
+	// Java frames from code compiled by R8 with a map id embedded in the source file attribute:
+	//   at a.b.c(r8-map-id-3b4c6f1:12)
+	regexp.MustCompile(`r8-map-id-([0-9a-fA-F]+)`),
+	// Binary images in macOS crash reports:
+	//   0x100000000 - 0x100ffffff soong_ui (0) <A1B2C3D4-...> /path/to/soong_ui
+	regexp.MustCompile(`<([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})>`),
+}
+
+// crashIdentifiers reads a tombstone, ANR trace or crash report from a file and returns the
+// identifiers it references.
+func crashIdentifiers(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	defer f.Close()
+
+	identifiers, err := extractCrashIdentifiers(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	return identifiers, nil
+}
+
+// extractCrashIdentifiers returns the normalized identifiers referenced by a crash in the order
+// they first appear, without duplicates.
+func extractCrashIdentifiers(r io.Reader) ([]string, error) {
+	var identifiers []string
+	seen := make(map[string]bool)
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		for _, re := range crashIdentifierRegexps {
+			for _, match := range re.FindAllStringSubmatch(line, -1) {
+				identifier := normalizeIdentifier(match[1])
+				if !seen[identifier] {
+					seen[identifier] = true
+					identifiers = append(identifiers, identifier)
+				}
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return identifiers, nil
+}
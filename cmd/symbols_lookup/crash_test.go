@@ -0,0 +1,73 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_extractCrashIdentifiers(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     []string
+	}{
+		{
+			name:     "empty",
+			contents: "",
+		},
+		{
+			name: "tombstone",
+			contents: `
+backtrace:
+      #00 pc 000000000004f0a4  /apex/com.android.runtime/lib64/bionic/libc.so (abort+164) (BuildId: 5F0B1D0B6D1D3A1E)
+      #01 pc 0000000000001234  /system/bin/foo (main+20) (BuildId: 0123456789abcdef)
+      #02 pc 000000000004f0b0  /apex/com.android.runtime/lib64/bionic/libc.so (abort+176) (BuildId: 5f0b1d0b6d1d3a1e)
+`,
+			want: []string{"5f0b1d0b6d1d3a1e", "0123456789abcdef"},
+		},
+		{
+			name: "anr",
+			contents: `
+"main" prio=5 tid=1 Blocked
+  at com.example.a.b(r8-map-id-3b4c6f1:12)
+  native: #00 pc 000000000009b0ac  /system/lib64/libart.so (art::Monitor::Lock+28) (BuildId: abcdef0123456789)
+`,
+			want: []string{"3b4c6f1", "abcdef0123456789"},
+		},
+		{
+			name: "macos crash report",
+			contents: `
+Binary Images:
+       0x100000000 -        0x100ffffff +soong_ui (0) <CAAF44D2-8278-68FE-C090-A34385366CC7> /out/soong_ui
+`,
+			want: []string{"caaf44d2827868fec090a34385366cc7"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractCrashIdentifiers(strings.NewReader(tt.contents))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("incorrect identifiers, want %q got %q", tt.want, got)
+			}
+		})
+	}
+}
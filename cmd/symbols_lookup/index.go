@@ -0,0 +1,141 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"android/soong/cmd/symbols_map/symbols_map_proto"
+
+	"github.com/google/blueprint/pathtools"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// minPrefixLength is the shortest identifier prefix that will be looked up.  R8 only embeds the
+// first 7 characters of the dictionary hash into stack traces by default.
+const minPrefixLength = 7
+
+// index is a list of mappings sorted by identifier that supports lookups by identifier prefix.
+type index struct {
+	mappings []*symbols_map_proto.Mapping
+}
+
+// normalizeIdentifier converts an identifier to the form stored in the index.  Build IDs and
+// R8 hashes are already lowercase hex, but Mach-O UUIDs are usually printed in uppercase with
+// dashes.
+func normalizeIdentifier(identifier string) string {
+	return strings.ToLower(strings.ReplaceAll(identifier, "-", ""))
+}
+
+// buildIndex reads a list of textproto files containing Mappings messages, as produced by
+// symbols_map -merge, and writes them to output as a binary Mappings proto sorted by identifier.
+// Mappings without an identifier are dropped as they can never be looked up.
+func buildIndex(output string, inputs []string, writeIfChanged bool) error {
+	var mappings []*symbols_map_proto.Mapping
+	for _, input := range inputs {
+		data, err := ioutil.ReadFile(input)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", input, err)
+		}
+		var merged symbols_map_proto.Mappings
+		err = prototext.Unmarshal(data, &merged)
+		if err != nil {
+			return fmt.Errorf("failed to parse textproto %s: %w", input, err)
+		}
+		for _, mapping := range merged.Mappings {
+			if mapping.GetIdentifier() == "" {
+				continue
+			}
+			mapping.Identifier = proto.String(normalizeIdentifier(mapping.GetIdentifier()))
+			mappings = append(mappings, mapping)
+		}
+	}
+
+	sortMappings(mappings)
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(&symbols_map_proto.Mappings{
+		Mappings: mappings,
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling index: %w", err)
+	}
+
+	if writeIfChanged {
+		err = pathtools.WriteFileIfChanged(output, data, 0666)
+	} else {
+		err = ioutil.WriteFile(output, data, 0666)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing to %s: %w", output, err)
+	}
+
+	return nil
+}
+
+// sortMappings sorts mappings by identifier, then by type and location so that the index is
+// reproducible.
+func sortMappings(mappings []*symbols_map_proto.Mapping) {
+	sort.SliceStable(mappings, func(i, j int) bool {
+		a, b := mappings[i], mappings[j]
+		if a.GetIdentifier() != b.GetIdentifier() {
+			return a.GetIdentifier() < b.GetIdentifier()
+		}
+		if a.GetType() != b.GetType() {
+			return a.GetType() < b.GetType()
+		}
+		return a.GetLocation() < b.GetLocation()
+	})
+}
+
+// loadIndex reads an index written by buildIndex.
+func loadIndex(filename string) (*index, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	var mappings symbols_map_proto.Mappings
+	err = proto.Unmarshal(data, &mappings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse index %s: %w", filename, err)
+	}
+	return &index{mappings: mappings.Mappings}, nil
+}
+
+// lookup returns all mappings whose identifier starts with the given identifier, which may be
+// a full identifier or a prefix of at least minPrefixLength characters.
+func (idx *index) lookup(identifier string) ([]*symbols_map_proto.Mapping, error) {
+	identifier = normalizeIdentifier(identifier)
+	if len(identifier) < minPrefixLength {
+		return nil, fmt.Errorf("identifier %q is too short, at least %d characters are required",
+			identifier, minPrefixLength)
+	}
+
+	start := sort.Search(len(idx.mappings), func(i int) bool {
+		return idx.mappings[i].GetIdentifier() >= identifier
+	})
+
+	var ret []*symbols_map_proto.Mapping
+	for i := start; i < len(idx.mappings); i++ {
+		if !strings.HasPrefix(idx.mappings[i].GetIdentifier(), identifier) {
+			break
+		}
+		ret = append(ret, idx.mappings[i])
+	}
+	return ret, nil
+}
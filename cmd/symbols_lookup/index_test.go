@@ -0,0 +1,120 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"android/soong/cmd/symbols_map/symbols_map_proto"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_index(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test_index")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	merged := &symbols_map_proto.Mappings{
+		Mappings: []*symbols_map_proto.Mapping{
+			{
+				Identifier: proto.String("fedcba9876543210"),
+				Location:   proto.String("symbols/system/lib64/libbar.so"),
+				Type:       symbols_map_proto.Mapping_ELF.Enum(),
+				Module:     proto.String("libbar"),
+			},
+			{
+				Identifier: proto.String("0123456789abcdef"),
+				Location:   proto.String("symbols/system/lib64/libfoo.so"),
+				Type:       symbols_map_proto.Mapping_ELF.Enum(),
+				Module:     proto.String("libfoo"),
+			},
+			{
+				Identifier: proto.String("3b4c6f1aaaaaaaaaaaaaaaaaaaaaaaaa"),
+				Location:   proto.String("proguard/Foo/proguard_dictionary"),
+				Type:       symbols_map_proto.Mapping_R8.Enum(),
+			},
+			{
+				Identifier: proto.String("CAAF44D2-8278-68FE-C090-A34385366CC7"),
+				Location:   proto.String("symbols/host/darwin-x86/bin/soong_ui"),
+				Type:       symbols_map_proto.Mapping_MACHO.Enum(),
+				Module:     proto.String("soong_ui"),
+			},
+			{
+				Identifier: proto.String(""),
+				Location:   proto.String("symbols/system/lib64/libnobuildid.so"),
+				Type:       symbols_map_proto.Mapping_ELF.Enum(),
+			},
+		},
+	}
+
+	input := filepath.Join(dir, "merged.textproto")
+	data, err := prototext.Marshal(merged)
+	if err != nil {
+		t.Fatalf("failed to marshal input: %s", err)
+	}
+	if err := os.WriteFile(input, data, 0666); err != nil {
+		t.Fatalf("failed to write input: %s", err)
+	}
+
+	output := filepath.Join(dir, "index.pb")
+	if err := buildIndex(output, []string{input}, false); err != nil {
+		t.Fatalf("unexpected error building index: %s", err)
+	}
+
+	idx, err := loadIndex(output)
+	if err != nil {
+		t.Fatalf("unexpected error loading index: %s", err)
+	}
+
+	if len(idx.mappings) != 4 {
+		t.Errorf("expected mappings without identifiers to be dropped, got %d mappings", len(idx.mappings))
+	}
+
+	buf := &bytes.Buffer{}
+	missing, err := lookupAndPrint(buf, idx, []string{
+		"0123456789abcdef",
+		"3b4c6f1",
+		"caaf44d2-8278-68fe-c090-a34385366cc7",
+		"1111111111111111",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error looking up identifiers: %s", err)
+	}
+	if !missing {
+		t.Errorf("expected missing identifier to be reported")
+	}
+
+	want := strings.Join([]string{
+		"0123456789abcdef ELF libfoo symbols/system/lib64/libfoo.so",
+		"3b4c6f1aaaaaaaaaaaaaaaaaaaaaaaaa R8 <unknown> proguard/Foo/proguard_dictionary",
+		"caaf44d2827868fec090a34385366cc7 MACHO soong_ui symbols/host/darwin-x86/bin/soong_ui",
+		"1111111111111111 not found",
+	}, "\n") + "\n"
+	if buf.String() != want {
+		t.Errorf("incorrect output, want:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	if _, err := idx.lookup("3b4c"); err == nil {
+		t.Errorf("expected error looking up a short prefix")
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"android/soong/cmd/symbols_map/symbols_map_proto"
+)
+
+// This tool is used to build a compact index from the textprotos merged by symbols_map, and to
+// look up the unstripped file and module for elf build IDs, Mach-O UUIDs and R8 dictionary hashes,
+// either passed directly or extracted from tombstones, ANR traces and crash reports.
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func main() {
+	flags := flag.NewFlagSet("flags", flag.ExitOnError)
+
+	// Hide the flag package to prevent accidental references to flag instead of flags.
+	flag := struct{}{}
+	_ = flag
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of %s:\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "  %s -build_index <index file> [-write_if_changed] <merged textproto>...\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "  %s -index <index file> [-crash <file>]... [<identifier>...]\n", os.Args[0])
+		fmt.Fprintln(flags.Output())
+
+		flags.PrintDefaults()
+	}
+
+	buildIndexFile := flags.String("build_index", "", "build an index from merged symbols_map textprotos")
+	writeIfChanged := flags.Bool("write_if_changed", false, "only write output file if it is modified")
+	indexFile := flags.String("index", "", "index to look up identifiers in")
+	var crashFiles stringList
+	flags.Var(&crashFiles, "crash", "tombstone, ANR trace or crash report to extract identifiers from")
+
+	flags.Parse(os.Args[1:])
+
+	if *buildIndexFile != "" {
+		if *indexFile != "" || len(crashFiles) > 0 {
+			fmt.Fprintf(os.Stderr, "-index and -crash are not allowed with -build_index\n")
+			flags.Usage()
+			os.Exit(1)
+		}
+		err := buildIndex(*buildIndexFile, flags.Args(), *writeIfChanged)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to build index: %s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *indexFile == "" {
+		fmt.Fprintf(os.Stderr, "-build_index or -index argument is required\n")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	identifiers := flags.Args()
+	for _, crashFile := range crashFiles {
+		crashIds, err := crashIdentifiers(crashFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		identifiers = append(identifiers, crashIds...)
+	}
+
+	if len(identifiers) == 0 {
+		fmt.Fprintf(os.Stderr, "no identifiers to look up\n")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	idx, err := loadIndex(*indexFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	missing, err := lookupAndPrint(os.Stdout, idx, identifiers)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	if missing {
+		os.Exit(2)
+	}
+}
+
+// lookupAndPrint writes one line per matching mapping for each identifier to w, in the form
+// "<identifier> <type> <module> <location>", or "<identifier> not found" if there was no match.
+// It returns true if any identifier was not found.
+func lookupAndPrint(w io.Writer, idx *index, identifiers []string) (bool, error) {
+	missing := false
+	for _, identifier := range identifiers {
+		mappings, err := idx.lookup(identifier)
+		if err != nil {
+			return false, err
+		}
+		if len(mappings) == 0 {
+			fmt.Fprintf(w, "%s not found\n", normalizeIdentifier(identifier))
+			missing = true
+			continue
+		}
+		for _, mapping := range mappings {
+			fmt.Fprintf(w, "%s %s %s %s\n", mapping.GetIdentifier(), mapping.GetType(),
+				moduleOrUnknown(mapping), mapping.GetLocation())
+		}
+	}
+	return missing, nil
+}
+
+func moduleOrUnknown(mapping *symbols_map_proto.Mapping) string {
+	if mapping.GetModule() == "" {
+		return "<unknown>"
+	}
+	return mapping.GetModule()
+}
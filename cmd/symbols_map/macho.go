@@ -0,0 +1,75 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"debug/macho"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// loadCmdUUID is the LC_UUID load command, which is not defined by the debug/macho package.
+const loadCmdUUID macho.LoadCmd = 0x1b
+
+// machoIdentifier extracts the LC_UUID from a Mach-O file.  If allowMissing is true it returns
+// an empty identifier if the file exists but the LC_UUID load command does not.
+func machoIdentifier(filename string, allowMissing bool) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	defer f.Close()
+
+	return machoIdentifierFromReaderAt(f, filename, allowMissing)
+}
+
+// machoIdentifierFromReaderAt extracts the LC_UUID from a ReaderAt.  If allowMissing is true it
+// returns an empty identifier if the file exists but the LC_UUID load command does not.  Universal
+// (fat) binaries are not supported, as a single mapping cannot hold the UUID of each architecture.
+func machoIdentifierFromReaderAt(r io.ReaderAt, filename string, allowMissing bool) (string, error) {
+	f, err := macho.NewFile(r)
+	if err != nil {
+		if allowMissing {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return "", nil
+			}
+			if _, ok := err.(*macho.FormatError); ok {
+				// The file was not a Mach-O file.
+				return "", nil
+			}
+		}
+		return "", fmt.Errorf("failed to parse Mach-O file %s: %w", filename, err)
+	}
+	defer f.Close()
+
+	for _, load := range f.Loads {
+		raw := load.Raw()
+		if len(raw) < 8 || macho.LoadCmd(f.ByteOrder.Uint32(raw[0:4])) != loadCmdUUID {
+			continue
+		}
+		if len(raw) < 24 {
+			return "", fmt.Errorf("short LC_UUID load command in %s", filename)
+		}
+		return hex.EncodeToString(raw[8:24]), nil
+	}
+
+	if allowMissing {
+		return "", nil
+	}
+	return "", fmt.Errorf("failed to find LC_UUID in %s", filename)
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"testing"
+)
+
+func Test_machoIdentifierFromReaderAt_BadMachoFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{
+			name:     "empty",
+			contents: "",
+		},
+		{
+			name:     "text",
+			contents: "#!/bin/bash\necho foobar",
+		},
+		{
+			name:     "empty macho",
+			contents: machoFile(nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := bytes.NewReader([]byte(tt.contents))
+			_, err := machoIdentifierFromReaderAt(buf, "<>", false)
+			if err == nil {
+				t.Errorf("expected error reading bad Mach-O file without allowMissing")
+			}
+			_, err = machoIdentifierFromReaderAt(buf, "<>", true)
+			if err != nil {
+				t.Errorf("expected no error reading bad Mach-O file with allowMissing, got %q", err.Error())
+			}
+		})
+	}
+}
+
+func Test_machoIdentifierFromReaderAt(t *testing.T) {
+	uuid := []byte{0xca, 0xaf, 0x44, 0xd2, 0x82, 0x78, 0x68, 0xfe, 0xc0, 0x90, 0xa3, 0x43, 0x85, 0x36, 0x6c, 0xc7}
+
+	buf := bytes.NewReader([]byte(machoFile(uuid)))
+	identifier, err := machoIdentifierFromReaderAt(buf, "<>", false)
+	if err != nil {
+		t.Fatalf("unexpected error reading Mach-O file: %s", err)
+	}
+
+	expected := "caaf44d2827868fec090a34385366cc7"
+	if identifier != expected {
+		t.Errorf("incorrect identifier, want %q got %q", expected, identifier)
+	}
+}
+
+// machoFile returns a 64-bit Mach-O file header, followed by an LC_UUID load command if uuid is
+// not nil.
+func machoFile(uuid []byte) string {
+	var loads []byte
+	ncmds := uint32(0)
+	if uuid != nil {
+		cmd := &bytes.Buffer{}
+		binary.Write(cmd, binary.LittleEndian, uint32(loadCmdUUID))
+		binary.Write(cmd, binary.LittleEndian, uint32(8+len(uuid)))
+		cmd.Write(uuid)
+		loads = cmd.Bytes()
+		ncmds++
+	}
+
+	header := macho.FileHeader{
+		Magic:  macho.Magic64,
+		Cpu:    macho.CpuAmd64,
+		SubCpu: 3,
+		Type:   macho.TypeExec,
+		Ncmd:   ncmds,
+		Cmdsz:  uint32(len(loads)),
+	}
+
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, header)
+	// 64-bit Mach-O headers have a reserved word after the flags.
+	binary.Write(buf, binary.LittleEndian, uint32(0))
+	buf.Write(loads)
+	return buf.String()
+}
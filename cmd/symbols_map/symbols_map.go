@@ -29,8 +29,8 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-// This tool is used to extract a hash from an elf file or an r8 dictionary and store it as a
-// textproto, or to merge multiple textprotos together.
+// This tool is used to extract a hash from an elf file, a Mach-O file or an r8 dictionary and
+// store it as a textproto, or to merge multiple textprotos together.
 
 func main() {
 	var expandedArgs []string
@@ -62,7 +62,7 @@ func main() {
 
 	flags.Usage = func() {
 		fmt.Fprintf(flags.Output(), "Usage of %s:\n", os.Args[0])
-		fmt.Fprintf(flags.Output(), "  %s -elf|-r8 <input file> [-write_if_changed] <output file>\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "  %s -elf|-macho|-r8 <input file> [-module <name>] [-write_if_changed] <output file>\n", os.Args[0])
 		fmt.Fprintf(flags.Output(), "  %s -merge <output file> [-write_if_changed] [-ignore_missing_files] [-strip_prefix <prefix>] [<input file>...]\n", os.Args[0])
 		fmt.Fprintln(flags.Output())
 
@@ -70,12 +70,14 @@ func main() {
 	}
 
 	elfFile := flags.String("elf", "", "extract identifier from an elf file")
+	machoFile := flags.String("macho", "", "extract identifier from a Mach-O file")
 	r8File := flags.String("r8", "", "extract identifier from an r8 dictionary")
 	merge := flags.String("merge", "", "merge multiple identifier protos")
 
 	writeIfChanged := flags.Bool("write_if_changed", false, "only write output file if it is modified")
 	ignoreMissingFiles := flags.Bool("ignore_missing_files", false, "ignore missing input files in merge mode")
 	stripPrefix := flags.String("strip_prefix", "", "prefix to strip off of the location field in merge mode")
+	module := flags.String("module", "", "name of the module that produced the input file")
 
 	flags.Parse(expandedArgs)
 
@@ -89,14 +91,21 @@ func main() {
 		os.Exit(0)
 	}
 
-	if *elfFile == "" && *r8File == "" {
-		fmt.Fprintf(os.Stderr, "-elf or -r8 argument is required\n")
+	inputModes := 0
+	for _, input := range []string{*elfFile, *machoFile, *r8File} {
+		if input != "" {
+			inputModes++
+		}
+	}
+
+	if inputModes == 0 {
+		fmt.Fprintf(os.Stderr, "-elf, -macho or -r8 argument is required\n")
 		flags.Usage()
 		os.Exit(1)
 	}
 
-	if *elfFile != "" && *r8File != "" {
-		fmt.Fprintf(os.Stderr, "only one of -elf or -r8 argument is allowed\n")
+	if inputModes > 1 {
+		fmt.Fprintf(os.Stderr, "only one of -elf, -macho or -r8 argument is allowed\n")
 		flags.Usage()
 		os.Exit(1)
 	}
@@ -121,6 +130,14 @@ func main() {
 			fmt.Fprintf(os.Stderr, "error reading elf identifier: %s\n", err)
 			os.Exit(1)
 		}
+	} else if *machoFile != "" {
+		typ = symbols_map_proto.Mapping_MACHO
+		location = *machoFile
+		identifier, err = machoIdentifier(*machoFile, true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading Mach-O identifier: %s\n", err)
+			os.Exit(1)
+		}
 	} else if *r8File != "" {
 		typ = symbols_map_proto.Mapping_R8
 		identifier, err = r8Identifier(*r8File)
@@ -138,6 +155,9 @@ func main() {
 		Location:   proto.String(location),
 		Type:       typ.Enum(),
 	}
+	if *module != "" {
+		mapping.Module = proto.String(*module)
+	}
 
 	err = writeTextProto(output, &mapping, *writeIfChanged)
 	if err != nil {
@@ -37,6 +37,7 @@ var (
 	sortGlobs = flag.Bool("s", false, "sort matches from each glob (defaults to the order from the input zip file)")
 	sortJava  = flag.Bool("j", false, "sort using jar ordering within each glob (META-INF/MANIFEST.MF first)")
 	setTime   = flag.Bool("t", false, "set timestamps to 2009-01-01 00:00:00")
+	align     = flag.Uint("a", 0, "align the data of uncompressed files to a multiple of this many bytes (e.g. 4096)")
 
 	staticTime = time.Date(2009, 1, 1, 0, 0, 0, 0, time.UTC)
 
@@ -75,6 +76,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := zip.ValidateStoredAlignment(*align); err != nil {
+		fmt.Fprintf(os.Stderr, "-a: %s\n", err)
+		os.Exit(1)
+	}
+
 	log.SetFlags(log.Lshortfile)
 
 	reader, err := zip.OpenReader(*input)
@@ -90,6 +96,9 @@ func main() {
 	defer output.Close()
 
 	writer := zip.NewWriter(output)
+	if err := writer.SetStoredAlignment(uint16(*align)); err != nil {
+		log.Fatal(err)
+	}
 	defer func() {
 		err := writer.Close()
 		if err != nil {
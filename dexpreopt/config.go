@@ -97,6 +97,20 @@ type GlobalConfig struct {
 	// measure, as it masks real errors and affects performance.
 	RelaxUsesLibraryCheck bool
 
+	// If true, fail the build when the class loader context check finds <uses-library>
+	// dependencies of apps or system server jars that are not installed on the device, or build-time
+	// class loader contexts that differ from the ones PackageManager will compute at runtime.  The
+	// check always writes a report, this only controls whether problems are fatal and whether the
+	// check runs as part of droidcore.  Like the rest of the global config it is read from
+	// dexpreopt.config, so it is false unless the product config sets it; otherwise the check only
+	// runs when the check-class-loader-context phony target is built.
+	EnforceClassLoaderContextCheck bool
+
+	// Modules listed in PRODUCT_PACKAGES.  The class loader context check considers them and the
+	// modules they require installed.  If the list is empty, e.g. because it is not written to
+	// dexpreopt.config, it considers every module that Soong installs installed.
+	ProductPackages []string
+
 	EnableUffdGc bool // preopt with the assumption that userfaultfd GC will be used on device.
 }
 
@@ -54,6 +54,13 @@ type filesystem struct {
 	output     android.OutputPath
 	installDir android.InstallPath
 
+	// Listing of every path in the image with its owner, mode, SELinux label, size, hash and the
+	// module that installed it.
+	manifest android.OutputPath
+
+	// Compiled file_contexts, shared by the image and the manifest.
+	fileContexts android.OutputPath
+
 	// For testing. Keeps the result of CopyDepsToZip()
 	entries []string
 }
@@ -248,7 +255,8 @@ func (f *filesystem) buildRootZip(ctx android.ModuleContext) android.OutputPath
 
 func (f *filesystem) buildImageUsingBuildImage(ctx android.ModuleContext) android.OutputPath {
 	depsZipFile := android.PathForModuleOut(ctx, "deps.zip").OutputPath
-	f.entries = f.CopyDepsToZip(ctx, f.gatherFilteredPackagingSpecs(ctx), depsZipFile)
+	specs := f.gatherFilteredPackagingSpecs(ctx)
+	f.entries = f.CopyDepsToZip(ctx, specs, depsZipFile)
 
 	builder := android.NewRuleBuilder(pctx, ctx)
 	depsBase := proptools.StringDefault(f.properties.Base_dir, ".")
@@ -284,6 +292,8 @@ func (f *filesystem) buildImageUsingBuildImage(ctx android.ModuleContext) androi
 		Output(output).
 		Text(rootDir.String()) // directory where to find fs_config_files|dirs
 
+	f.manifest = f.buildManifest(ctx, builder, rootDir, specs)
+
 	// rootDir is not deleted. Might be useful for quick inspection.
 	builder.Build("build_filesystem_image", fmt.Sprintf("Creating filesystem %s", f.BaseModuleName()))
 
@@ -291,13 +301,74 @@ func (f *filesystem) buildImageUsingBuildImage(ctx android.ModuleContext) androi
 }
 
 func (f *filesystem) buildFileContexts(ctx android.ModuleContext) android.OutputPath {
+	if f.fileContexts.String() != "" {
+		return f.fileContexts
+	}
 	builder := android.NewRuleBuilder(pctx, ctx)
 	fcBin := android.PathForModuleOut(ctx, "file_contexts.bin")
 	builder.Command().BuiltTool("sefcontext_compile").
 		FlagWithOutput("-o ", fcBin).
 		Input(android.PathForModuleSrc(ctx, proptools.String(f.properties.File_contexts)))
 	builder.Build("build_filesystem_file_contexts", fmt.Sprintf("Creating filesystem file contexts for %s", f.BaseModuleName()))
-	return fcBin.OutputPath
+	f.fileContexts = fcBin.OutputPath
+	return f.fileContexts
+}
+
+// buildManifest adds commands to builder that write a JSON listing of every path under rootDir
+// with its owner and mode from fs_config, its SELinux label from file_contexts (if any), its size
+// and SHA-256, and the module that installed it. Paths that no module installed, like the ones
+// from the dirs and symlinks properties, are attributed to this module. Two manifests can be
+// compared with `filesystem_manifest -diff`.
+func (f *filesystem) buildManifest(ctx android.ModuleContext, builder *android.RuleBuilder,
+	rootDir android.OutputPath, specs map[string]android.PackagingSpec) android.OutputPath {
+
+	depsBase := proptools.StringDefault(f.properties.Base_dir, ".")
+	owners := make(map[string]string)
+	ctx.WalkDeps(func(child, parent android.Module) bool {
+		for _, ps := range child.PackagingSpecs() {
+			if _, ok := specs[ps.RelPathInPackage()]; ok {
+				path := filepath.Join(depsBase, ps.RelPathInPackage())
+				if _, exists := owners[path]; !exists {
+					owners[path] = ctx.OtherModuleName(child)
+				}
+			}
+		}
+		return true
+	})
+	var ownerLines []string
+	for _, path := range android.SortedKeys(owners) {
+		ownerLines = append(ownerLines, path+" "+owners[path])
+	}
+	modulesFile := android.PathForModuleOut(ctx, "manifest", "modules.txt").OutputPath
+	android.WriteFileRule(ctx, modulesFile, strings.Join(ownerLines, "\n"))
+
+	// fs_config expects directories to have a trailing slash.
+	pathsFile := android.PathForModuleOut(ctx, "manifest", "paths.txt").OutputPath
+	builder.Command().
+		Textf("(cd %s && find . -mindepth 1 \\( -type d -printf '%%P/\\n' -o -printf '%%P\\n' \\) | sort) >", rootDir).
+		Output(pathsFile)
+
+	fsConfigFile := android.PathForModuleOut(ctx, "manifest", "fs_config.txt").OutputPath
+	fsConfigCmd := builder.Command().
+		BuiltTool("fs_config").
+		Flag("-C").
+		FlagWithArg("-D ", rootDir.String())
+	if proptools.String(f.properties.File_contexts) != "" {
+		fsConfigCmd.FlagWithInput("-S ", f.buildFileContexts(ctx))
+	}
+	fsConfigCmd.Text("<").Input(pathsFile).
+		Text(">").Output(fsConfigFile)
+
+	manifest := android.PathForModuleOut(ctx, f.BaseModuleName()+"-manifest.json").OutputPath
+	builder.Command().
+		BuiltTool("filesystem_manifest").
+		FlagWithArg("-root ", rootDir.String()).
+		FlagWithInput("-fs_config ", fsConfigFile).
+		FlagWithInput("-modules ", modulesFile).
+		FlagWithArg("-default_module ", ctx.ModuleName()).
+		FlagWithOutput("-o ", manifest)
+
+	return manifest
 }
 
 // Calculates avb_salt from entry list (sorted) for deterministic output.
@@ -391,7 +462,8 @@ func (f *filesystem) buildCpioImage(ctx android.ModuleContext, compressed bool)
 	}
 
 	depsZipFile := android.PathForModuleOut(ctx, "deps.zip").OutputPath
-	f.entries = f.CopyDepsToZip(ctx, f.gatherFilteredPackagingSpecs(ctx), depsZipFile)
+	specs := f.gatherFilteredPackagingSpecs(ctx)
+	f.entries = f.CopyDepsToZip(ctx, specs, depsZipFile)
 
 	builder := android.NewRuleBuilder(pctx, ctx)
 	depsBase := proptools.StringDefault(f.properties.Base_dir, ".")
@@ -425,6 +497,8 @@ func (f *filesystem) buildCpioImage(ctx android.ModuleContext, compressed bool)
 		cmd.Text(">").Output(output)
 	}
 
+	f.manifest = f.buildManifest(ctx, builder, rootDir, specs)
+
 	// rootDir is not deleted. Might be useful for quick inspection.
 	builder.Build("build_cpio_image", fmt.Sprintf("Creating filesystem %s", f.BaseModuleName()))
 
@@ -451,8 +525,11 @@ var _ android.OutputFileProducer = (*filesystem)(nil)
 
 // Implements android.OutputFileProducer
 func (f *filesystem) OutputFiles(tag string) (android.Paths, error) {
-	if tag == "" {
+	switch tag {
+	case "":
 		return []android.Path{f.output}, nil
+	case ".manifest":
+		return []android.Path{f.manifest}, nil
 	}
 	return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 }
@@ -47,6 +47,37 @@ func TestFileSystemDeps(t *testing.T) {
 	result.ModuleForTests("myfilesystem", "android_common").Output("myfilesystem.img")
 }
 
+func TestFileSystemManifest(t *testing.T) {
+	result := fixture.RunTestWithBp(t, `
+		android_filesystem {
+			name: "myfilesystem",
+			deps: ["libfoo"],
+			file_contexts: "file_contexts",
+		}
+
+		cc_library {
+			name: "libfoo",
+		}
+	`)
+
+	module := result.ModuleForTests("myfilesystem", "android_common")
+
+	modules := android.ContentFromFileRuleForTests(t, module.Output("manifest/modules.txt"))
+	android.AssertStringDoesContain(t, "modules.txt should attribute libfoo.so to libfoo",
+		modules, "lib64/libfoo.so libfoo")
+
+	manifest := module.Output("myfilesystem-manifest.json")
+	android.AssertStringDoesContain(t, "manifest should use labels from file_contexts",
+		manifest.RuleParams.Command, "-S out/soong/.intermediates/myfilesystem/android_common/file_contexts.bin")
+	android.AssertStringDoesContain(t, "manifest should attribute unowned paths to the filesystem",
+		manifest.RuleParams.Command, "-default_module myfilesystem")
+
+	outputFiles, err := module.Module().(*filesystem).OutputFiles(".manifest")
+	android.AssertSame(t, "error", nil, err)
+	android.AssertPathsRelativeToTopEquals(t, ".manifest output",
+		[]string{"out/soong/.intermediates/myfilesystem/android_common/myfilesystem-manifest.json"}, outputFiles)
+}
+
 func TestFileSystemFillsLinkerConfigWithStubLibs(t *testing.T) {
 	result := fixture.RunTestWithBp(t, `
 		android_system_image {
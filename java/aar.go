@@ -113,6 +113,7 @@ type aapt struct {
 	hasNoCode               bool
 	LoggingParent           string
 	resourceFiles           android.Paths
+	resourceSources         []resourceSource
 
 	splitNames []string
 	splits     []split
@@ -158,6 +159,10 @@ func (a *aapt) SetRROEnforcedForDependent(enforce bool) {
 	a.aaptProperties.RROEnforcedForDependent = enforce
 }
 
+func (a *aapt) transitiveResourceSources() []resourceSource {
+	return a.resourceSources
+}
+
 func (a *aapt) IsRROEnforced(ctx android.BaseModuleContext) bool {
 	// True if RRO is enforced for this module or...
 	return ctx.Config().EnforceRROForModule(ctx.ModuleName()) ||
@@ -389,6 +394,21 @@ func (a *aapt) buildActions(ctx android.ModuleContext, sdkContext android.SdkCon
 		compiledOverlay = append(compiledOverlay, aapt2Compile(ctx, dir.dir, dir.files, compileFlags).Paths()...)
 	}
 
+	// Record where the merged resources come from in the same priority order, lowest first.
+	resourceSources := staticLibResourceSources(ctx)
+	for _, dir := range resDirs {
+		resourceSources = append(resourceSources, resourceSource{
+			kind: "res", module: ctx.ModuleName(), path: dir.dir, files: dir.files})
+	}
+	for _, zip := range resZips {
+		resourceSources = append(resourceSources, resourceSource{
+			kind: "res", module: ctx.ModuleName(), path: zip, files: android.Paths{zip}})
+	}
+	for _, dir := range overlayDirs {
+		resourceSources = append(resourceSources, resourceSource{
+			kind: "overlay", module: ctx.ModuleName(), path: dir.dir, files: dir.files})
+	}
+
 	var splitPackages android.WritablePaths
 	var splits []split
 
@@ -425,6 +445,7 @@ func (a *aapt) buildActions(ctx android.ModuleContext, sdkContext android.SdkCon
 	a.manifestPath = manifestPath
 	a.proguardOptionsFile = proguardOptionsFile
 	a.rroDirs = rroDirs
+	a.resourceSources = resourceSources
 	a.extraAaptPackagesFile = extraPackages
 	a.rTxt = rTxt
 	a.splits = splits
@@ -688,6 +709,8 @@ type AARImport struct {
 	aarPath     android.Path
 	jniPackages android.Paths
 
+	resourceSources []resourceSource
+
 	sdkVersion    android.SdkSpec
 	minSdkVersion android.ApiLevel
 }
@@ -734,11 +757,16 @@ func (a *AARImport) javaVersion() string {
 }
 
 var _ AndroidLibraryDependency = (*AARImport)(nil)
+var _ resourceSourcesProvider = (*AARImport)(nil)
 
 func (a *AARImport) ExportPackage() android.Path {
 	return a.exportPackage
 }
 
+func (a *AARImport) transitiveResourceSources() []resourceSource {
+	return a.resourceSources
+}
+
 func (a *AARImport) ExportedProguardFlagFiles() android.Paths {
 	return android.Paths{a.proguardFlags}
 }
@@ -902,6 +930,11 @@ func (a *AARImport) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 	overlayRes := append(android.Paths{flata}, transitiveStaticLibs...)
 
+	// The resources of the static libraries are overlaid on the resources of the aar.
+	a.resourceSources = append([]resourceSource{{
+		kind: "res", module: ctx.ModuleName(), path: a.aarPath, zipPrefix: "res",
+		files: android.Paths{a.aarPath}}}, staticLibResourceSources(ctx)...)
+
 	aapt2Link(ctx, a.exportPackage, srcJar, proguardOptionsFile, rTxt, a.extraAaptPackagesFile,
 		linkFlags, linkDeps, nil, overlayRes, transitiveAssets, nil)
 
@@ -151,3 +151,74 @@ func (overlaySingleton) GenerateBuildActions(ctx android.SingletonContext) {
 		return overlayData
 	})
 }
+
+// resourceSource is a resource directory or zip whose resources are merged into the resources of a
+// module, used to report where each resource of an app comes from.
+type resourceSource struct {
+	// One of "res" for the resources of a module, "overlay" for a product or device overlay merged
+	// at build time, or "rro" for an overlay that is turned into a runtime resource overlay.
+	kind string
+
+	// The module whose resources the source contains.
+	module string
+
+	// The resource directory, or a zip containing the resources below zipPrefix.
+	path      android.Path
+	zipPrefix string
+
+	// The files that the resources are read from.
+	files android.Paths
+}
+
+// resourceSourcesProvider is implemented by modules whose resources are merged into the modules that
+// depend on them statically.
+type resourceSourcesProvider interface {
+	// transitiveResourceSources returns the resource sources merged into the module, including
+	// those of its static libraries, from the lowest to the highest priority.
+	transitiveResourceSources() []resourceSource
+}
+
+// staticLibResourceSources returns the resource sources of the static libraries of a module in the
+// order in which aaptLibs merges their compiled resources.
+func staticLibResourceSources(ctx android.ModuleContext) []resourceSource {
+	var sources []resourceSource
+	type sourceKey struct{ kind, module, path string }
+	seen := make(map[sourceKey]bool)
+	ctx.VisitDirectDepsWithTag(staticLibTag, func(module android.Module) {
+		if dep, ok := module.(resourceSourcesProvider); ok {
+			for _, source := range dep.transitiveResourceSources() {
+				key := sourceKey{source.kind, source.module, source.path.String()}
+				if !seen[key] {
+					seen[key] = true
+					sources = append(sources, source)
+				}
+			}
+		}
+	})
+	return sources
+}
+
+// buildResourceProvenanceReport creates a rule to write a report listing, for each resource of an
+// app, every definition from the given sources in priority order along with the one that wins.
+func buildResourceProvenanceReport(ctx android.ModuleContext, sources []resourceSource,
+	report android.WritablePath) {
+
+	var lines []string
+	var deps android.Paths
+	for _, source := range sources {
+		lines = append(lines, strings.Join([]string{source.kind, source.module, source.path.String(),
+			source.zipPrefix}, "\t"))
+		deps = append(deps, source.files...)
+	}
+	sourcesFile := android.PathForModuleOut(ctx, "resource_provenance", "sources.txt")
+	android.WriteFileRule(ctx, sourcesFile, strings.Join(lines, "\n"))
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("resource_provenance").
+		FlagWithArg("--module ", ctx.ModuleName()).
+		FlagWithInput("--sources ", sourcesFile).
+		FlagWithOutput("--output ", report).
+		Implicits(deps)
+	rule.Build("resource_provenance", "resource provenance report")
+}
@@ -681,6 +681,10 @@ func (a *AndroidAppImport) AndroidMkEntries() []android.AndroidMkEntries {
 					entries.SetString("LOCAL_SOONG_BUILT_INSTALLED", a.dexpreopter.builtInstalled)
 				}
 				entries.AddStrings("LOCAL_INSTALLED_MODULE_STEM", a.installPath.Rel())
+				if a.v4SignatureFile != nil {
+					install := a.onDeviceDir + "/" + a.v4SignatureFile.Base()
+					entries.AddStrings("LOCAL_SOONG_BUILT_INSTALLED", a.v4SignatureFile.String()+":"+install)
+				}
 				if Bool(a.properties.Export_package_resources) {
 					entries.SetPath("LOCAL_SOONG_RESOURCE_EXPORT_PACKAGE", a.outputFile)
 				}
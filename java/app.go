@@ -65,6 +65,17 @@ type appProperties struct {
 	// list of resource labels to generate individual resource packages
 	Package_splits []string
 
+	// If set, write a report listing, for each resource of the app, every definition from the
+	// app, its static libraries and the product and device overlays in priority order, and which
+	// one wins. Overrides that are likely unexpected, such as one static library overriding the
+	// value of another, are flagged as warnings. The report is available with the
+	// ".resource_provenance.txt" output tag.
+	//
+	// The enforced runtime resource overlays generated from the product and device overlays are
+	// included, but runtime_resource_overlay modules are not: they select the app by its package
+	// name at runtime, so the build does not know which of them apply to the app.
+	Resource_provenance_report *bool
+
 	// list of native libraries that will be provided in or alongside the resulting jar
 	Jni_libs []string `android:"arch_variant"`
 
@@ -167,6 +178,9 @@ type AndroidApp struct {
 
 	bundleFile android.Path
 
+	// The report of where each resource comes from, if requested with resource_provenance_report.
+	resourceProvenanceReport android.WritablePath
+
 	// the install APK name is normally the same as the module name, but can be overridden with PRODUCT_PACKAGE_NAME_OVERRIDES.
 	installApkName string
 
@@ -212,6 +226,11 @@ var _ AndroidLibraryDependency = (*AndroidApp)(nil)
 type Certificate struct {
 	Pem, Key  android.Path
 	presigned bool
+
+	// The signing certificate lineage and --rotation-min-sdk-version used by default when signing
+	// with this certificate, if it is the main certificate of an app.
+	Lineage               android.Path
+	RotationMinSdkVersion string
 }
 
 var PresignedCertificate = Certificate{presigned: true}
@@ -448,6 +467,18 @@ func (a *AndroidApp) aaptBuildActions(ctx android.ModuleContext) {
 	a.aapt.buildActions(ctx, android.SdkContext(a), a.classLoaderContexts,
 		a.usesLibraryProperties.Exclude_uses_libs, a.enforceDefaultTargetSdkVersion(), aaptLinkFlags...)
 
+	if Bool(a.appProperties.Resource_provenance_report) {
+		// Enforced runtime resource overlays are not merged into the app, but override its
+		// resources on the device.
+		sources := append([]resourceSource(nil), a.aapt.transitiveResourceSources()...)
+		for _, dir := range a.aapt.rroDirs {
+			sources = append(sources, resourceSource{
+				kind: "rro", module: ctx.ModuleName(), path: dir.path, files: androidResourceGlob(ctx, dir.path)})
+		}
+		a.resourceProvenanceReport = android.PathForModuleOut(ctx, "resource_provenance", "report.txt")
+		buildResourceProvenanceReport(ctx, sources, a.resourceProvenanceReport)
+	}
+
 	// apps manifests are handled by aapt, don't let Module see them
 	a.properties.Manifest = nil
 }
@@ -597,6 +628,18 @@ func processMainCert(m android.ModuleBase, certPropValue string, certificates []
 	return mainCertificate, certificates
 }
 
+// signingLineage returns the signing certificate lineage file and the --rotation-min-sdk-version to
+// sign with. The lineage and rotationMinSdkVersion properties of the module take precedence over
+// the rotation metadata of its main certificate.
+func signingLineage(ctx android.ModuleContext, lineage, rotationMinSdkVersion *string,
+	mainCertificate Certificate) (android.Path, string) {
+	lineageFile := mainCertificate.Lineage
+	if lineage := String(lineage); lineage != "" {
+		lineageFile = android.PathForModuleSrc(ctx, lineage)
+	}
+	return lineageFile, proptools.StringDefault(rotationMinSdkVersion, mainCertificate.RotationMinSdkVersion)
+}
+
 func (a *AndroidApp) InstallApkName() string {
 	return a.installApkName
 }
@@ -689,11 +732,8 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 	if v4SigningRequested {
 		v4SignatureFile = android.PathForModuleOut(ctx, a.installApkName+".apk.idsig")
 	}
-	var lineageFile android.Path
-	if lineage := String(a.overridableAppProperties.Lineage); lineage != "" {
-		lineageFile = android.PathForModuleSrc(ctx, lineage)
-	}
-	rotationMinSdkVersion := String(a.overridableAppProperties.RotationMinSdkVersion)
+	lineageFile, rotationMinSdkVersion := signingLineage(ctx, a.overridableAppProperties.Lineage,
+		a.overridableAppProperties.RotationMinSdkVersion, a.certificate)
 
 	CreateAndSignAppPackage(ctx, packageFile, a.exportPackage, jniJarFile, dexJarFile, certificates, apkDeps, v4SignatureFile, lineageFile, rotationMinSdkVersion, Bool(a.dexProperties.Optimize.Shrink_resources))
 	a.outputFile = packageFile
@@ -984,6 +1024,11 @@ func (a *AndroidApp) OutputFiles(tag string) (android.Paths, error) {
 		return []android.Path{a.aaptSrcJar}, nil
 	case ".export-package.apk":
 		return []android.Path{a.exportPackage}, nil
+	case ".resource_provenance.txt":
+		if a.resourceProvenanceReport == nil {
+			return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
+		}
+		return []android.Path{a.resourceProvenanceReport}, nil
 	}
 	return a.Library.OutputFiles(tag)
 }
@@ -1240,6 +1285,15 @@ type AndroidAppCertificate struct {
 type AndroidAppCertificateProperties struct {
 	// Name of the certificate files.  Extensions .x509.pem and .pk8 will be added to the name.
 	Certificate *string
+
+	// Name of the signing certificate lineage file or filegroup module, for a certificate that
+	// replaces older ones through key rotation. Used to sign the apps whose main certificate is this
+	// one, unless they set their own lineage.
+	Lineage *string `android:"path"`
+
+	// The --rotation-min-sdk-version of apksig used to sign the apps whose main certificate is this
+	// one, unless they set their own.
+	RotationMinSdkVersion *string
 }
 
 // android_app_certificate modules can be referenced by the certificates property of android_app modules to select
@@ -1257,6 +1311,11 @@ func (c *AndroidAppCertificate) GenerateAndroidBuildActions(ctx android.ModuleCo
 	c.Certificate = Certificate{
 		Pem: android.PathForModuleSrc(ctx, cert+".x509.pem"),
 		Key: android.PathForModuleSrc(ctx, cert+".pk8"),
+
+		RotationMinSdkVersion: String(c.properties.RotationMinSdkVersion),
+	}
+	if lineage := String(c.properties.Lineage); lineage != "" {
+		c.Certificate.Lineage = android.PathForModuleSrc(ctx, lineage)
 	}
 }
 
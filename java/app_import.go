@@ -77,6 +77,10 @@ type AndroidAppImport struct {
 
 	installPath android.InstallPath
 
+	// The APK Signature Scheme V4 signature of the apk, if requested with v4_signature.
+	v4SignatureFile android.WritablePath
+	onDeviceDir     string
+
 	hideApexVariantFromMake bool
 
 	provenanceMetaDataFile android.OutputPath
@@ -103,6 +107,10 @@ type AndroidAppImportProperties struct {
 	// For overriding the --rotation-min-sdk-version property of apksig
 	RotationMinSdkVersion *string
 
+	// If true, generate the signature file of APK Signing Scheme V4, along side the signed APK file.
+	// Not supported for presigned apks. Defaults to false.
+	V4_signature *bool
+
 	// Sign with the default system dev certificate. Must be used judiciously. Most imported apps
 	// need to either specify a specific certificate or be presigned.
 	Default_dev_cert *bool
@@ -262,6 +270,9 @@ func (a *AndroidAppImport) generateAndroidBuildActions(ctx android.ModuleContext
 	if numCertPropsSet != 1 {
 		ctx.ModuleErrorf("One and only one of certficate, presigned, and default_dev_cert properties must be set")
 	}
+	if Bool(a.properties.Presigned) && Bool(a.properties.V4_signature) {
+		ctx.PropertyErrorf("v4_signature", "cannot be set for presigned apks")
+	}
 
 	_, _, certificates := collectAppDeps(ctx, a, false, false)
 
@@ -325,14 +336,12 @@ func (a *AndroidAppImport) generateAndroidBuildActions(ctx android.ModuleContext
 		// Which makes processMainCert's behavior for the empty cert string WAI.
 		a.certificate, certificates = processMainCert(a.ModuleBase, String(a.properties.Certificate), certificates, ctx)
 		signed := android.PathForModuleOut(ctx, "signed", apkFilename)
-		var lineageFile android.Path
-		if lineage := String(a.properties.Lineage); lineage != "" {
-			lineageFile = android.PathForModuleSrc(ctx, lineage)
+		if Bool(a.properties.V4_signature) {
+			a.v4SignatureFile = android.PathForModuleOut(ctx, "signed", apkFilename+".idsig")
 		}
-
-		rotationMinSdkVersion := String(a.properties.RotationMinSdkVersion)
-
-		SignAppPackage(ctx, signed, jnisUncompressed, certificates, nil, lineageFile, rotationMinSdkVersion)
+		lineageFile, rotationMinSdkVersion := signingLineage(ctx, a.properties.Lineage,
+			a.properties.RotationMinSdkVersion, a.certificate)
+		SignAppPackage(ctx, signed, jnisUncompressed, certificates, a.v4SignatureFile, lineageFile, rotationMinSdkVersion)
 		a.outputFile = signed
 	} else {
 		alignedApk := android.PathForModuleOut(ctx, "zip-aligned", apkFilename)
@@ -344,7 +353,13 @@ func (a *AndroidAppImport) generateAndroidBuildActions(ctx android.ModuleContext
 	// TODO: Optionally compress the output apk.
 
 	if apexInfo.IsForPlatform() {
-		a.installPath = ctx.InstallFile(installDir, apkFilename, a.outputFile)
+		var extraInstalledPaths android.Paths
+		if a.v4SignatureFile != nil {
+			a.onDeviceDir = android.InstallPathToOnDevicePath(ctx, installDir)
+			extraInstalledPaths = append(extraInstalledPaths,
+				ctx.InstallFile(installDir, a.v4SignatureFile.Base(), a.v4SignatureFile))
+		}
+		a.installPath = ctx.InstallFile(installDir, apkFilename, a.outputFile, extraInstalledPaths...)
 		artifactPath := android.PathForModuleSrc(ctx, *a.properties.Apk)
 		a.provenanceMetaDataFile = provenance.GenerateArtifactProvenanceMetaData(ctx, artifactPath, a.installPath)
 	}
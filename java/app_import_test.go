@@ -195,6 +195,39 @@ func TestAndroidAppImport_SigningLineageFilegroup(t *testing.T) {
 	android.AssertStringEquals(t, "Invalid args", "/system/app/foo/foo.apk", rule.Args["install_path"])
 }
 
+func TestAndroidAppImport_SigningSchemes(t *testing.T) {
+	ctx, config := testJava(t, `
+		android_app_import {
+			name: "foo",
+			apk: "prebuilts/apk/app.apk",
+			certificate: ":rotated_certificate",
+			v4_signature: true,
+		}
+
+		android_app_certificate {
+			name: "rotated_certificate",
+			certificate: "cert/rotated_cert",
+			lineage: "lineage.bin",
+			rotationMinSdkVersion: "33",
+		}
+	`)
+
+	variant := ctx.ModuleForTests("foo", "android_common")
+
+	signedApk := variant.Output("signed/foo.apk")
+	expected := "--enable-v4 --lineage lineage.bin --rotation-min-sdk-version 33"
+	android.AssertStringEquals(t, "signing flags", expected, signedApk.Args["flags"])
+	android.AssertPathsRelativeToTopEquals(t, "signing outputs", []string{
+		"out/soong/.intermediates/foo/android_common/signed/foo.apk",
+		"out/soong/.intermediates/foo/android_common/signed/foo.apk.idsig",
+	}, signedApk.Outputs.Paths())
+
+	entries := android.AndroidMkEntriesForTest(t, ctx, variant.Module())[0]
+	builtInstalled := android.StringRelativeToTop(config, strings.Join(entries.EntryMap["LOCAL_SOONG_BUILT_INSTALLED"], " "))
+	android.AssertStringDoesContain(t, "v4 signature install", builtInstalled,
+		"out/soong/.intermediates/foo/android_common/signed/foo.apk.idsig:/system/app/foo/foo.apk.idsig")
+}
+
 func TestAndroidAppImport_DefaultDevCert(t *testing.T) {
 	ctx, _ := testJava(t, `
 		android_app_import {
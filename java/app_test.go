@@ -979,6 +979,87 @@ func TestAndroidResources(t *testing.T) {
 	}
 }
 
+func TestResourceProvenanceReport(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		PrepareForTestWithOverlayBuildComponents,
+		android.FixtureMergeMockFs(android.MockFS{
+			"foo/res/values/strings.xml":                     nil,
+			"lib/res/values/strings.xml":                     nil,
+			"lib2/res/values/strings.xml":                    nil,
+			"device/overlay/foo/res/values/strings.xml":      nil,
+			"device/overlay/lib/res/values/strings.xml":      nil,
+			"device/static_overlay/foo/res/values/bools.xml": nil,
+		}),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.DeviceResourceOverlays = []string{"device/overlay", "device/static_overlay"}
+			variables.EnforceRROTargets = []string{"foo"}
+			variables.EnforceRROExcludedOverlays = []string{"device/static_overlay"}
+		}),
+	).RunTestWithBp(t, `
+		android_app {
+			name: "foo",
+			sdk_version: "current",
+			resource_dirs: ["foo/res"],
+			static_libs: ["lib"],
+			resource_provenance_report: true,
+		}
+
+		android_library {
+			name: "lib",
+			sdk_version: "current",
+			resource_dirs: ["lib/res"],
+			static_libs: ["lib2"],
+		}
+
+		android_library {
+			name: "lib2",
+			sdk_version: "current",
+			resource_dirs: ["lib2/res"],
+		}
+
+		android_app {
+			name: "bar",
+			sdk_version: "current",
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+
+	sources := android.ContentFromFileRuleForTests(t, foo.Output("resource_provenance/sources.txt"))
+	android.AssertStringEquals(t, "resource sources", strings.Join([]string{
+		"res\tlib2\tlib2/res\t",
+		"res\tlib\tlib/res\t",
+		"res\tfoo\tfoo/res\t",
+		"overlay\tfoo\tdevice/static_overlay/foo/res\t",
+		"rro\tfoo\tdevice/overlay/foo/res\t",
+		"rro\tfoo\tdevice/overlay/lib/res\t",
+	}, "\n")+"\n", sources)
+
+	report := foo.Output("resource_provenance/report.txt")
+	android.AssertStringDoesContain(t, "report command", report.RuleParams.Command,
+		"--module foo --sources out/soong/.intermediates/foo/android_common/resource_provenance/sources.txt")
+	android.AssertPathsRelativeToTopEquals(t, "report inputs", []string{
+		"lib2/res/values/strings.xml",
+		"lib/res/values/strings.xml",
+		"foo/res/values/strings.xml",
+		"device/static_overlay/foo/res/values/bools.xml",
+		"device/overlay/foo/res/values/strings.xml",
+		"device/overlay/lib/res/values/strings.xml",
+	}, report.Implicits)
+
+	outputs, err := foo.Module().(*AndroidApp).OutputFiles(".resource_provenance.txt")
+	android.AssertSame(t, "output files error", nil, err)
+	android.AssertPathsRelativeToTopEquals(t, "output files",
+		[]string{"out/soong/.intermediates/foo/android_common/resource_provenance/report.txt"}, outputs)
+
+	// Without resource_provenance_report there is no report to refer to.
+	bar := result.ModuleForTests("bar", "android_common")
+	_, err = bar.Module().(*AndroidApp).OutputFiles(".resource_provenance.txt")
+	android.AssertStringDoesContain(t, "output files error without report", fmt.Sprint(err),
+		`".resource_provenance.txt" was requested, but no output file was found.`)
+}
+
 func checkSdkVersion(t *testing.T, result *android.TestResult, expectedSdkVersion string) {
 	foo := result.ModuleForTests("foo", "android_common")
 	link := foo.Output("package-res.apk")
@@ -1621,6 +1702,49 @@ func TestCertificates(t *testing.T) {
 			expectedCertSigningFlags: "--lineage lineage.bin --rotation-min-sdk-version 32",
 			expectedCertificate:      "cert/new_cert",
 		},
+		{
+			name: "cert signing flags from certificate module",
+			bp: `
+				android_app {
+					name: "foo",
+					srcs: ["a.java"],
+					certificate: ":new_certificate",
+					sdk_version: "current",
+				}
+
+				android_app_certificate {
+					name: "new_certificate",
+					certificate: "cert/new_cert",
+					lineage: "lineage.bin",
+					rotationMinSdkVersion: "32",
+				}
+			`,
+			certificateOverride:      "",
+			expectedCertSigningFlags: "--lineage lineage.bin --rotation-min-sdk-version 32",
+			expectedCertificate:      "cert/new_cert",
+		},
+		{
+			name: "app cert signing flags override certificate module",
+			bp: `
+				android_app {
+					name: "foo",
+					srcs: ["a.java"],
+					certificate: ":new_certificate",
+					rotationMinSdkVersion: "33",
+					sdk_version: "current",
+				}
+
+				android_app_certificate {
+					name: "new_certificate",
+					certificate: "cert/new_cert",
+					lineage: "lineage.bin",
+					rotationMinSdkVersion: "32",
+				}
+			`,
+			certificateOverride:      "",
+			expectedCertSigningFlags: "--lineage lineage.bin --rotation-min-sdk-version 33",
+			expectedCertificate:      "cert/new_cert",
+		},
 		{
 			name: "missing with AllowMissingDependencies",
 			bp: `
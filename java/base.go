@@ -99,7 +99,7 @@ type CommonProperties struct {
 	// This restriction is checked after applying jarjar rules and including static libs.
 	Permitted_packages []string
 
-	// List of modules to use as annotation processors
+	// List of modules to use as annotation processors or Kotlin Symbol Processing processors
 	Plugins []string
 
 	// List of modules to export to libraries that directly depend on this library as annotation
@@ -599,6 +599,21 @@ func (j *Module) OutputFiles(tag string) (android.Paths, error) {
 			return android.Paths{j.dexer.proguardDictionary.Path()}, nil
 		}
 		return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
+	case ".proguard_usage":
+		if j.dexer.proguardUsageZip.Valid() {
+			return android.Paths{j.dexer.proguardUsageZip.Path()}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
+	case ".proguard_seeds":
+		if j.dexer.proguardSeeds.Valid() {
+			return android.Paths{j.dexer.proguardSeeds.Path()}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
+	case ".proguard_config":
+		if j.dexer.proguardConfiguration.Valid() {
+			return android.Paths{j.dexer.proguardConfiguration.Path()}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
@@ -926,6 +941,8 @@ func (j *Module) collectBuilderFlags(ctx android.ModuleContext, deps deps) javaB
 	flags.processors = append(flags.processors, deps.processorClasses...)
 	flags.processors = android.FirstUniqueStrings(flags.processors)
 
+	flags.kspProcessorPath = append(flags.kspProcessorPath, deps.kspProcessorPath...)
+
 	if len(flags.bootClasspath) == 0 && ctx.Host() && !flags.javaVersion.usesJavaModules() &&
 		decodeSdkDep(ctx, android.SdkContext(j)).hasStandardLibs() {
 		// Give host-side tools a version of OpenJDK's standard libraries
@@ -1118,6 +1135,10 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 	var kotlinJars android.Paths
 	var kotlinHeaderJars android.Paths
 
+	if len(flags.kspProcessorPath) > 0 && !srcFiles.HasExt(".kt") {
+		ctx.PropertyErrorf("plugins", "ksp plugins can only be used in modules with kotlin sources")
+	}
+
 	if srcFiles.HasExt(".kt") {
 		// When using kotlin sources turbine is used to generate annotation processor sources,
 		// including for annotation processors that generate API, so we can use turbine for
@@ -1159,6 +1180,16 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		flags.kotlincClasspath = append(flags.kotlincClasspath, flags.bootClasspath...)
 		flags.kotlincClasspath = append(flags.kotlincClasspath, flags.classpath...)
 
+		if len(flags.kspProcessorPath) > 0 {
+			// Run KSP processors first so that the sources they generate are visible to kapt,
+			// kotlinc and javac.
+			kspSrcJar := android.PathForModuleOut(ctx, "ksp", "ksp-sources.jar")
+			kspResJar := android.PathForModuleOut(ctx, "ksp", "ksp-res.jar")
+			kotlinKsp(ctx, kspSrcJar, kspResJar, uniqueSrcFiles, kotlinCommonSrcFiles, srcJars, flags)
+			srcJars = append(srcJars, kspSrcJar)
+			kotlinJars = append(kotlinJars, kspResJar)
+		}
+
 		if len(flags.processorPath) > 0 {
 			// Use kapt for annotation processing
 			kaptSrcJar := android.PathForModuleOut(ctx, "kapt", "kapt-sources.jar")
@@ -2070,7 +2101,9 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 				// optimization.
 				deps.disableTurbine = deps.disableTurbine || dep.ExportedPluginDisableTurbine
 			case pluginTag:
-				if plugin, ok := module.(*Plugin); ok {
+				if plugin, ok := module.(*Plugin); ok && plugin.isKsp() {
+					deps.kspProcessorPath = append(deps.kspProcessorPath, dep.ImplementationAndResourcesJars...)
+				} else if ok {
 					if plugin.pluginProperties.Processor_class != nil {
 						addPlugins(&deps, dep.ImplementationAndResourcesJars, *plugin.pluginProperties.Processor_class)
 					} else {
@@ -2090,7 +2123,9 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 					ctx.PropertyErrorf("plugins", "%q is not a java_plugin module", otherName)
 				}
 			case exportedPluginTag:
-				if plugin, ok := module.(*Plugin); ok {
+				if plugin, ok := module.(*Plugin); ok && plugin.isKsp() {
+					ctx.PropertyErrorf("exported_plugins", "ksp plugin %q cannot be exported", otherName)
+				} else if ok {
 					j.exportedPluginJars = append(j.exportedPluginJars, dep.ImplementationAndResourcesJars...)
 					if plugin.pluginProperties.Processor_class != nil {
 						j.exportedPluginClasses = append(j.exportedPluginClasses, *plugin.pluginProperties.Processor_class)
@@ -282,6 +282,8 @@ type javaBuilderFlags struct {
 	aidlDeps      android.Paths
 	javaVersion   javaVersion
 
+	kspProcessorPath classpath
+
 	errorProneExtraJavacFlags string
 	errorProneProcessorPath   classpath
 
@@ -0,0 +1,379 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"android/soong/android"
+	"android/soong/dexpreopt"
+)
+
+// The class loader context check compares the class loader context (CLC) of every installed app and
+// system server jar with the set of <uses-library> libraries installed on the device.  A mismatch
+// between the build-time CLC used by dexpreopt and the CLC that PackageManager computes at runtime
+// causes the dexpreopted code to be rejected on the device, which only shows up as a boot-time or
+// app startup regression.  See dexpreopt/class_loader_context.go for a description of CLC.
+//
+// The installed modules are the ones in PRODUCT_PACKAGES, the modules they require, and the
+// contents of the installed APEXes.  When the product packages are not passed in the dexpreopt
+// config, every module that Soong installs is considered installed.  Both the unconditional CLC and
+// the compatibility libraries that PackageManager adds for apps targeting older SDK versions are
+// checked.
+//
+// The check runs when the check-class-loader-context phony target is built, and as part of
+// droidcore if EnforceClassLoaderContextCheck is set in the dexpreopt config.
+
+func init() {
+	registerClassLoaderContextCheckBuildComponents(android.InitRegistrationContext)
+}
+
+func registerClassLoaderContextCheckBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterSingletonType("class_loader_context_check", classLoaderContextCheckSingletonFactory)
+}
+
+var PrepareForTestWithClassLoaderContextCheck = android.FixtureRegisterWithContext(registerClassLoaderContextCheckBuildComponents)
+
+func classLoaderContextCheckSingletonFactory() android.Singleton {
+	return &classLoaderContextCheckSingleton{}
+}
+
+type classLoaderContextCheckSingleton struct{}
+
+// getDexpreopter gives the class loader context check access to the dexpreopt state of a module.
+func (d *dexpreopter) getDexpreopter() *dexpreopter {
+	return d
+}
+
+type dexpreopterModule interface {
+	getDexpreopter() *dexpreopter
+}
+
+// clcCheckUser is an app or system server jar whose class loader context is checked.
+type clcCheckUser struct {
+	name string
+	kind string
+	clc  dexpreopt.ClassLoaderContextMap
+}
+
+// clcCheckLibrary is a <uses-library> that is installed on the device.
+type clcCheckLibrary struct {
+	module string
+
+	// runtimeDepsKnown is true if the <uses-library> dependencies that PackageManager sees for
+	// this library are known to the build system, in which case they are listed in runtimeDeps.
+	runtimeDepsKnown bool
+	runtimeDeps      []string
+}
+
+// clcCheckProblem is a mismatch between the build-time and the runtime class loader context of a
+// user.
+type clcCheckProblem struct {
+	user    string
+	message string
+}
+
+func (p clcCheckProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.user, p.message)
+}
+
+func (s *classLoaderContextCheckSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	global := dexpreopt.GetGlobalConfig(ctx)
+	if global.DisablePreopt {
+		return
+	}
+
+	// Without the product packages every module that Soong installs, and the contents of every
+	// APEX, are considered installed.
+	var productModules map[string]bool
+	if len(global.ProductPackages) > 0 {
+		productModules = classLoaderContextCheckProductModules(ctx, global.ProductPackages)
+	}
+
+	installed := make(map[string]*clcCheckLibrary)
+	var users []clcCheckUser
+	seenUsers := make(map[string]bool)
+
+	systemServerJars := global.AllSystemServerJars(ctx)
+
+	ctx.VisitAllModules(func(module android.Module) {
+		if !isActiveModule(module) || module.Target().Os != android.Android {
+			return
+		}
+		name := android.RemoveOptionalPrebuiltPrefix(ctx.ModuleName(module))
+
+		apexInfo := ctx.ModuleProvider(module, android.ApexInfoProvider).(android.ApexInfo)
+		var isInstalled bool
+		if productModules == nil {
+			isInstalled = !apexInfo.IsForPlatform() || len(module.FilesToInstall()) > 0
+		} else if apexInfo.IsForPlatform() {
+			isInstalled = productModules[name] && !module.IsSkipInstall()
+		} else {
+			for _, apex := range apexInfo.InApexVariants {
+				isInstalled = isInstalled || productModules[apex]
+			}
+		}
+		if !isInstalled {
+			return
+		}
+
+		if lib := clcCheckLibraryForModule(module, name); lib != nil {
+			installed[lib.name] = &lib.clcCheckLibrary
+		}
+
+		if seenUsers[name] {
+			return
+		}
+
+		if d, ok := module.(dexpreopterModule); ok && d.getDexpreopter().isApp && !d.getDexpreopter().isTest {
+			seenUsers[name] = true
+			users = append(users, clcCheckUser{
+				name: name,
+				kind: "app",
+				clc:  d.getDexpreopter().classLoaderContexts,
+			})
+		} else if dep, ok := module.(UsesLibraryDependency); ok && systemServerJars.ContainsJar(name) {
+			seenUsers[name] = true
+			users = append(users, clcCheckUser{
+				name: name,
+				kind: "system server jar",
+				clc:  dep.ClassLoaderContexts(),
+			})
+		}
+	})
+
+	problems := checkClassLoaderContexts(users, installed)
+
+	report := android.PathForOutput(ctx, "class_loader_context_check", "report.txt")
+	android.WriteFileRule(ctx, report, classLoaderContextCheckReport(users, installed, problems))
+
+	timestamp := android.PathForOutput(ctx, "class_loader_context_check", "check.timestamp")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	if len(problems) > 0 && global.EnforceClassLoaderContextCheck {
+		rule.Command().Text("cat").Input(report).Text(">&2 && exit 1 &&").
+			Text("touch").Output(timestamp)
+	} else {
+		rule.Command().Text("touch").Output(timestamp).Implicit(report)
+	}
+	rule.Build("class_loader_context_check", "check class loader contexts")
+
+	// The check-class-loader-context phony target depends on the timestamp created if the check
+	// succeeds.
+	ctx.Phony("check-class-loader-context", timestamp)
+	if global.EnforceClassLoaderContextCheck {
+		ctx.Phony("droidcore", timestamp)
+	}
+}
+
+// classLoaderContextCheckProductModules returns the names of the product packages and of the
+// modules they transitively require.
+func classLoaderContextCheckProductModules(ctx android.SingletonContext, productPackages []string) map[string]bool {
+	required := make(map[string][]string)
+	ctx.VisitAllModules(func(module android.Module) {
+		if !isActiveModule(module) || module.Target().Os != android.Android {
+			return
+		}
+		name := android.RemoveOptionalPrebuiltPrefix(ctx.ModuleName(module))
+		required[name] = append(required[name], module.RequiredModuleNames()...)
+		required[name] = append(required[name], module.TargetRequiredModuleNames()...)
+	})
+
+	modules := make(map[string]bool)
+	queue := append([]string(nil), productPackages...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if modules[name] {
+			continue
+		}
+		modules[name] = true
+		queue = append(queue, required[name]...)
+	}
+	return modules
+}
+
+type namedClcCheckLibrary struct {
+	clcCheckLibrary
+	name string
+}
+
+// clcCheckLibraryForModule returns the <uses-library> provided by a module, or nil if the module
+// does not provide one.
+func clcCheckLibraryForModule(module android.Module, name string) *namedClcCheckLibrary {
+	if lib, ok := module.(SdkLibraryDependency); ok && lib.sharedLibrary() {
+		// The permissions file that PackageManager reads for a java_sdk_library or its prebuilt
+		// does not list any dependencies, so PackageManager never puts anything below it in the
+		// CLC.
+		return &namedClcCheckLibrary{
+			clcCheckLibrary: clcCheckLibrary{module: name, runtimeDepsKnown: true},
+			name:            name,
+		}
+	}
+	if ulib, ok := module.(ProvidesUsesLib); ok && ulib.ProvidesUsesLib() != nil {
+		lib := &namedClcCheckLibrary{
+			clcCheckLibrary: clcCheckLibrary{module: name},
+			name:            *ulib.ProvidesUsesLib(),
+		}
+		if d, ok := module.(dexpreopterModule); ok && d.getDexpreopter().isApp {
+			// PackageManager puts the <uses-library> tags of the manifest of an APK library below
+			// it, and the manifest lists the unconditional CLC of the app.  The permissions files
+			// of other libraries are written by hand, so their dependencies are unknown.
+			lib.runtimeDepsKnown = true
+			for _, clc := range d.getDexpreopter().classLoaderContexts[dexpreopt.AnySdkVersion] {
+				lib.runtimeDeps = append(lib.runtimeDeps, clc.Name)
+			}
+		}
+		return lib
+	}
+	return nil
+}
+
+// clcCheckSdkVersions returns the SDK versions of a CLC map, the unconditional CLC first and then
+// the compatibility libraries in increasing SDK version.
+func clcCheckSdkVersions(clcMap dexpreopt.ClassLoaderContextMap) []int {
+	var versions []int
+	if _, ok := clcMap[dexpreopt.AnySdkVersion]; ok {
+		versions = append(versions, dexpreopt.AnySdkVersion)
+	}
+	for _, ver := range android.SortedKeys(clcMap) {
+		if ver != dexpreopt.AnySdkVersion {
+			versions = append(versions, ver)
+		}
+	}
+	return versions
+}
+
+// checkClassLoaderContexts returns the problems with the class loader contexts of the users given
+// the set of installed <uses-library> libraries, sorted by user.
+func checkClassLoaderContexts(users []clcCheckUser, installed map[string]*clcCheckLibrary) []clcCheckProblem {
+	var problems []clcCheckProblem
+	for _, user := range users {
+		for _, ver := range clcCheckSdkVersions(user.clc) {
+			// PackageManager adds the compatibility libraries for apps that target an SDK version
+			// below ver, so they must be installed just like the unconditional ones.
+			kind := "required"
+			if ver != dexpreopt.AnySdkVersion {
+				kind = fmt.Sprintf("compatibility (target SDK version < %d)", ver)
+			}
+			for _, clc := range user.clc[ver] {
+				if _, ok := installed[clc.Name]; !ok {
+					if clc.Optional {
+						problems = append(problems, clcCheckProblem{user.name,
+							fmt.Sprintf("optional <uses-library> %q is in the build-time class loader context "+
+								"but is not installed, so PackageManager will leave it out at runtime", clc.Name)})
+					} else {
+						problems = append(problems, clcCheckProblem{user.name,
+							fmt.Sprintf("%s <uses-library> %q is not installed", kind, clc.Name)})
+					}
+					continue
+				}
+				problems = append(problems, checkClassLoaderContextRec(user.name, clc, installed)...)
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].user < problems[j].user
+	})
+	return problems
+}
+
+// checkClassLoaderContextRec compares the build-time CLC of a <uses-library> with the one
+// PackageManager will compute from the installed libraries.
+func checkClassLoaderContextRec(user string, clc *dexpreopt.ClassLoaderContext,
+	installed map[string]*clcCheckLibrary) []clcCheckProblem {
+
+	var problems []clcCheckProblem
+
+	if clc.Device == dexpreopt.UnknownInstallLibraryPath {
+		problems = append(problems, clcCheckProblem{user,
+			fmt.Sprintf("the install path of <uses-library> %q is unknown, so it cannot be "+
+				"dexpreopted against it", clc.Name)})
+	}
+
+	var buildDeps []string
+	for _, sub := range clc.Subcontexts {
+		buildDeps = append(buildDeps, sub.Name)
+	}
+
+	if lib := installed[clc.Name]; lib != nil && lib.runtimeDepsKnown {
+		if differ, _, _ := android.ListSetDifference(buildDeps, lib.runtimeDeps); differ {
+			problems = append(problems, clcCheckProblem{user,
+				fmt.Sprintf("build-time class loader context of <uses-library> %q has dependencies [%s], "+
+					"but PackageManager will use [%s] at runtime", clc.Name,
+					strings.Join(buildDeps, ", "), strings.Join(lib.runtimeDeps, ", "))})
+		}
+	}
+
+	for _, sub := range clc.Subcontexts {
+		if _, ok := installed[sub.Name]; !ok {
+			problems = append(problems, clcCheckProblem{user,
+				fmt.Sprintf("<uses-library> %q, a dependency of %q, is not installed", sub.Name, clc.Name)})
+			continue
+		}
+		problems = append(problems, checkClassLoaderContextRec(user, sub, installed)...)
+	}
+
+	return problems
+}
+
+// classLoaderContextCheckReport formats the report written by the class loader context check.
+func classLoaderContextCheckReport(users []clcCheckUser, installed map[string]*clcCheckLibrary,
+	problems []clcCheckProblem) string {
+
+	sb := &strings.Builder{}
+
+	fmt.Fprintf(sb, "Class loader context check: %d problem(s)\n", len(problems))
+	for _, p := range problems {
+		fmt.Fprintln(sb, p.String())
+	}
+
+	fmt.Fprintln(sb)
+	fmt.Fprintln(sb, "Installed <uses-library> libraries:")
+	for _, name := range android.SortedKeys(installed) {
+		fmt.Fprintf(sb, "  %s (%s)\n", name, installed[name].module)
+	}
+
+	fmt.Fprintln(sb)
+	fmt.Fprintln(sb, "Build-time class loader contexts:")
+	sortedUsers := append([]clcCheckUser(nil), users...)
+	sort.Slice(sortedUsers, func(i, j int) bool { return sortedUsers[i].name < sortedUsers[j].name })
+	for _, user := range sortedUsers {
+		fmt.Fprintf(sb, "  %s (%s)\n", user.name, user.kind)
+		for _, ver := range clcCheckSdkVersions(user.clc) {
+			if ver == dexpreopt.AnySdkVersion {
+				writeClassLoaderContextTree(sb, user.clc[ver], "    ")
+			} else {
+				fmt.Fprintf(sb, "    target SDK version < %d:\n", ver)
+				writeClassLoaderContextTree(sb, user.clc[ver], "      ")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+func writeClassLoaderContextTree(sb *strings.Builder, clcs []*dexpreopt.ClassLoaderContext, indent string) {
+	for _, clc := range clcs {
+		optional := ""
+		if clc.Optional {
+			optional = " (optional)"
+		}
+		fmt.Fprintf(sb, "%s%s%s %s\n", indent, clc.Name, optional, clc.Device)
+		writeClassLoaderContextTree(sb, clc.Subcontexts, indent+"  ")
+	}
+}
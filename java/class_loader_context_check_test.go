@@ -0,0 +1,242 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+	"android/soong/dexpreopt"
+)
+
+func TestCheckClassLoaderContexts(t *testing.T) {
+	installed := map[string]*clcCheckLibrary{
+		"sdk-lib":   {module: "sdk-lib", runtimeDepsKnown: true},
+		"other-lib": {module: "other-lib"},
+		"dep-lib":   {module: "dep-lib"},
+	}
+
+	clc := func(name string, optional bool, subs ...*dexpreopt.ClassLoaderContext) *dexpreopt.ClassLoaderContext {
+		return &dexpreopt.ClassLoaderContext{
+			Name:        name,
+			Optional:    optional,
+			Device:      "/system/framework/" + name + ".jar",
+			Subcontexts: subs,
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		clc      []*dexpreopt.ClassLoaderContext
+		compat   []*dexpreopt.ClassLoaderContext
+		expected []string
+	}{
+		{
+			name: "consistent",
+			clc: []*dexpreopt.ClassLoaderContext{
+				clc("sdk-lib", false),
+				clc("other-lib", false, clc("dep-lib", false)),
+			},
+		},
+		{
+			name: "required missing",
+			clc:  []*dexpreopt.ClassLoaderContext{clc("missing", false)},
+			expected: []string{
+				`app: required <uses-library> "missing" is not installed`,
+			},
+		},
+		{
+			name: "optional missing",
+			clc:  []*dexpreopt.ClassLoaderContext{clc("missing", true)},
+			expected: []string{
+				`app: optional <uses-library> "missing" is in the build-time class loader context but is not installed, so PackageManager will leave it out at runtime`,
+			},
+		},
+		{
+			name: "dependency missing",
+			clc:  []*dexpreopt.ClassLoaderContext{clc("other-lib", false, clc("missing", false))},
+			expected: []string{
+				`app: <uses-library> "missing", a dependency of "other-lib", is not installed`,
+			},
+		},
+		{
+			name: "unexpected dependency of sdk library",
+			clc:  []*dexpreopt.ClassLoaderContext{clc("sdk-lib", false, clc("dep-lib", false))},
+			expected: []string{
+				`app: build-time class loader context of <uses-library> "sdk-lib" has dependencies [dep-lib], but PackageManager will use [] at runtime`,
+			},
+		},
+		{
+			name:   "compatibility library missing",
+			compat: []*dexpreopt.ClassLoaderContext{clc("missing", false)},
+			expected: []string{
+				`app: compatibility (target SDK version < 29) <uses-library> "missing" is not installed`,
+			},
+		},
+		{
+			name: "unknown install path",
+			clc: []*dexpreopt.ClassLoaderContext{
+				{Name: "other-lib", Device: dexpreopt.UnknownInstallLibraryPath},
+			},
+			expected: []string{
+				`app: the install path of <uses-library> "other-lib" is unknown, so it cannot be dexpreopted against it`,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			clcMap := dexpreopt.ClassLoaderContextMap{dexpreopt.AnySdkVersion: test.clc}
+			if test.compat != nil {
+				clcMap[29] = test.compat
+			}
+			users := []clcCheckUser{{name: "app", kind: "app", clc: clcMap}}
+			var problems []string
+			for _, p := range checkClassLoaderContexts(users, installed) {
+				problems = append(problems, p.String())
+			}
+			android.AssertDeepEquals(t, "problems", test.expected, problems)
+		})
+	}
+}
+
+func TestClassLoaderContextCheckSingleton(t *testing.T) {
+	bp := `
+		java_sdk_library {
+			name: "foo",
+			srcs: ["a.java"],
+			api_packages: ["foo"],
+			sdk_version: "current",
+		}
+
+		java_sdk_library {
+			name: "bar",
+			srcs: ["a.java"],
+			api_packages: ["bar"],
+			sdk_version: "current",
+		}
+
+		android_app {
+			name: "app",
+			srcs: ["a.java"],
+			uses_libs: ["foo"],
+			sdk_version: "current",
+		}
+
+		android_app {
+			name: "apk-lib",
+			srcs: ["a.java"],
+			uses_libs: ["foo"],
+			provides_uses_lib: "com.android.apklib",
+			sdk_version: "current",
+		}
+
+		android_app {
+			name: "uninstalled-app",
+			srcs: ["a.java"],
+			uses_libs: ["bar"],
+			sdk_version: "current",
+		}
+	`
+
+	for _, enforce := range []bool{false, true} {
+		result := android.GroupFixturePreparers(
+			prepareForJavaTest,
+			PrepareForTestWithJavaSdkLibraryFiles,
+			PrepareForTestWithClassLoaderContextCheck,
+			FixtureWithLastReleaseApis("foo", "bar"),
+			dexpreopt.FixtureModifyGlobalConfig(func(_ android.PathContext, config *dexpreopt.GlobalConfig) {
+				config.EnforceClassLoaderContextCheck = enforce
+				config.ProductPackages = []string{"app", "apk-lib", "foo"}
+			}),
+		).RunTestWithBp(t, bp)
+
+		singleton := result.SingletonForTests("class_loader_context_check")
+
+		report := android.ContentFromFileRuleForTests(t, singleton.Output("class_loader_context_check/report.txt"))
+		android.AssertStringDoesContain(t, "report problems", report, "Class loader context check: 0 problem(s)\n")
+		android.AssertStringDoesContain(t, "report installed", report,
+			"  com.android.apklib (apk-lib)\n  foo (foo)\n\n")
+		android.AssertStringDoesContain(t, "report users", report,
+			"  app (app)\n    foo /system/framework/foo.jar\n")
+		// bar and the app using it are not in the product packages.
+		android.AssertStringDoesNotContain(t, "report uninstalled", report, "uninstalled-app")
+
+		// There are no problems, so the check never fails the build.
+		check := singleton.Rule("class_loader_context_check")
+		android.AssertStringDoesNotContain(t, "check command", check.RuleParams.Command, "exit 1")
+	}
+}
+
+func TestClassLoaderContextCheckApkLibrary(t *testing.T) {
+	installed := map[string]*clcCheckLibrary{
+		"foo":                {module: "foo", runtimeDepsKnown: true},
+		"com.android.apklib": {module: "apk-lib", runtimeDepsKnown: true, runtimeDeps: []string{"foo"}},
+	}
+	clc := []*dexpreopt.ClassLoaderContext{{
+		Name:   "com.android.apklib",
+		Device: "/system/app/apk-lib/apk-lib.apk",
+	}}
+	users := []clcCheckUser{{name: "app", kind: "app",
+		clc: dexpreopt.ClassLoaderContextMap{dexpreopt.AnySdkVersion: clc}}}
+
+	var problems []string
+	for _, p := range checkClassLoaderContexts(users, installed) {
+		problems = append(problems, p.String())
+	}
+	android.AssertDeepEquals(t, "problems", []string{
+		`app: build-time class loader context of <uses-library> "com.android.apklib" has dependencies [], but PackageManager will use [foo] at runtime`,
+	}, problems)
+}
+
+func TestClassLoaderContextCheckNoProductPackages(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		PrepareForTestWithJavaSdkLibraryFiles,
+		PrepareForTestWithClassLoaderContextCheck,
+		FixtureWithLastReleaseApis("foo"),
+	).RunTestWithBp(t, `
+		java_sdk_library {
+			name: "foo",
+			srcs: ["a.java"],
+			api_packages: ["foo"],
+			sdk_version: "current",
+		}
+
+		android_app {
+			name: "app",
+			srcs: ["a.java"],
+			uses_libs: ["foo"],
+			sdk_version: "current",
+		}
+
+		android_app {
+			name: "uninstallable-app",
+			srcs: ["a.java"],
+			uses_libs: ["foo"],
+			sdk_version: "current",
+			installable: false,
+		}
+	`)
+
+	// Without the product packages every module that Soong installs is considered installed.
+	singleton := result.SingletonForTests("class_loader_context_check")
+	report := android.ContentFromFileRuleForTests(t, singleton.Output("class_loader_context_check/report.txt"))
+	android.AssertStringDoesContain(t, "report problems", report, "Class loader context check: 0 problem(s)\n")
+	android.AssertStringDoesContain(t, "report installed", report, "  foo (foo)\n")
+	android.AssertStringDoesContain(t, "report users", report,
+		"  app (app)\n    foo /system/framework/foo.jar\n")
+	android.AssertStringDoesNotContain(t, "report uninstallable", report, "uninstallable-app")
+}
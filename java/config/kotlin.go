@@ -35,6 +35,8 @@ func init() {
 	pctx.SourcePathVariable("KotlinAnnotationJar", "external/kotlinc/lib/annotations-13.0.jar")
 	pctx.SourcePathVariable("KotlinStdlibJar", KotlinStdlibJar)
 	pctx.SourcePathVariable("KotlinAbiGenPluginJar", "external/kotlinc/lib/jvm-abi-gen.jar")
+	pctx.SourcePathVariable("KotlinKspApiJar", "external/kotlinc/lib/symbol-processing-api.jar")
+	pctx.SourcePathVariable("KotlinKspCmdlineJar", "external/kotlinc/lib/symbol-processing-cmdline.jar")
 
 	// These flags silence "Illegal reflective access" warnings when running kapt in OpenJDK9+
 	pctx.StaticVariable("KaptSuppressJDK9Warnings", strings.Join([]string{
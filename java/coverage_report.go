@@ -0,0 +1,246 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+// Rules for generating jacoco coverage reports from the execution data of test runs.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+func init() {
+	registerCoverageReportBuildComponents(android.InitRegistrationContext)
+}
+
+func registerCoverageReportBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterModuleType("java_coverage_report", CoverageReportFactory)
+}
+
+var PrepareForTestWithJavaCoverageReport = android.FixtureRegisterWithContext(registerCoverageReportBuildComponents)
+
+var (
+	jacocoMerge = pctx.AndroidStaticRule("jacocoMerge", blueprint.RuleParams{
+		Command: `${config.JavaCmd} ${config.JavaVmFlags} -jar ${config.JacocoCLIJar} ` +
+			`merge --quiet --destfile $out $in`,
+		CommandDeps: []string{
+			"${config.JavaCmd}",
+			"${config.JacocoCLIJar}",
+		},
+	})
+
+	// jacoco only reads sources from a directory, so the sources are extracted first.
+	jacocoReport = pctx.AndroidStaticRule("jacocoReport", blueprint.RuleParams{
+		Command: `rm -rf $srcDir $htmlDir && mkdir -p $srcDir && ` +
+			`${config.ZipSyncCmd} -d $srcDir -l $srcDir/list -f "*.java" -f "*.kt" $srcJar && ` +
+			`${config.JavaCmd} ${config.JavaVmFlags} -jar ${config.JacocoCLIJar} ` +
+			`report $in $classFiles --sourcefiles $srcDir --name $name --html $htmlDir --xml $xml && ` +
+			`${config.SoongZipCmd} -o $out -C $htmlDir -D $htmlDir && ` +
+			`rm -rf $srcDir $htmlDir`,
+		CommandDeps: []string{
+			"${config.ZipSyncCmd}",
+			"${config.JavaCmd}",
+			"${config.JacocoCLIJar}",
+			"${config.SoongZipCmd}",
+		},
+	},
+		"srcDir", "srcJar", "htmlDir", "classFiles", "name", "xml")
+)
+
+var (
+	coverageReportTestTag         = dependencyTag{name: "coverage-report-test"}
+	coverageReportInstrumentedTag = dependencyTag{name: "coverage-report-instrumented-lib"}
+)
+
+// The default location of the execution data files, relative to the directory of the module.
+const coverageReportDefaultExecFiles = "coverage/**/*.ec"
+
+type coverageReportProperties struct {
+	// The test modules whose coverage is reported. The classes of any test module that is
+	// instrumented are included in the report.
+	Tests []string
+
+	// The libraries under test. They must be instrumented, i.e. built with EMMA_INSTRUMENT=true,
+	// and are included in the report along with their sources.
+	Instrumented_libs []string
+
+	// The jacoco execution data (.ec) files produced by running the tests. The files from all test
+	// runs are merged before generating the report. Defaults to "coverage/**/*.ec", so the .ec files
+	// pulled from each test run can simply be dropped into a coverage directory next to the
+	// Android.bp file.
+	Exec_files []string `android:"path"`
+}
+
+type coverageReport struct {
+	android.ModuleBase
+
+	properties coverageReportProperties
+
+	// The merged execution data from all the test runs.
+	mergedExecFile android.Path
+
+	// The zip containing the HTML report.
+	htmlReport android.Path
+
+	// The XML report.
+	xmlReport android.Path
+}
+
+// java_coverage_report generates HTML and XML jacoco coverage reports for a set of instrumented
+// libraries from the execution data files produced by running their tests.
+//
+// The classes of the libraries are taken from their jacoco-report-classes.jar, i.e. the
+// uninstrumented classes that were passed to jacoco for instrumentation, so the report matches the
+// code that actually ran on the device.
+func CoverageReportFactory() android.Module {
+	module := &coverageReport{}
+	module.AddProperties(&module.properties)
+	android.InitAndroidArchModule(module, android.DeviceSupported, android.MultilibCommon)
+	return module
+}
+
+func (r *coverageReport) DepsMutator(ctx android.BottomUpMutatorContext) {
+	ctx.AddVariationDependencies(nil, coverageReportTestTag, r.properties.Tests...)
+	ctx.AddVariationDependencies(nil, coverageReportInstrumentedTag, r.properties.Instrumented_libs...)
+}
+
+func (r *coverageReport) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	mergedExecFile := android.PathForModuleOut(ctx, ctx.ModuleName()+".ec")
+	htmlReport := android.PathForModuleOut(ctx, ctx.ModuleName()+"-html.zip")
+	xmlReport := android.PathForModuleOut(ctx, ctx.ModuleName()+".xml")
+	r.mergedExecFile = mergedExecFile
+	r.htmlReport = htmlReport
+	r.xmlReport = xmlReport
+
+	execFilesProperty := r.properties.Exec_files
+	if execFilesProperty == nil {
+		execFilesProperty = []string{coverageReportDefaultExecFiles}
+	}
+	execFiles := android.PathsForModuleSrc(ctx, execFilesProperty)
+
+	var classesJars android.Paths
+	var srcJarArgs []string
+	var srcJarDeps android.Paths
+	var uninstrumented []string
+
+	ctx.VisitDirectDeps(func(m android.Module) {
+		tag := ctx.OtherModuleDependencyTag(m)
+		if tag != coverageReportTestTag && tag != coverageReportInstrumentedTag {
+			return
+		}
+		if !ctx.OtherModuleHasProvider(m, JavaInfoProvider) {
+			ctx.PropertyErrorf(coverageReportPropertyName(tag), "%q is not a java module", ctx.OtherModuleName(m))
+			return
+		}
+		dep := ctx.OtherModuleProvider(m, JavaInfoProvider).(JavaInfo)
+		if dep.JacocoReportClassesFile == nil {
+			// Tests are not usually instrumented themselves, but the libraries under test must be.
+			if tag == coverageReportInstrumentedTag {
+				uninstrumented = append(uninstrumented, ctx.OtherModuleName(m))
+			}
+			return
+		}
+		classesJars = append(classesJars, dep.JacocoReportClassesFile)
+		srcJarArgs = append(srcJarArgs, dep.SrcJarArgs...)
+		srcJarDeps = append(srcJarDeps, dep.SrcJarDeps...)
+	})
+
+	if ctx.Failed() {
+		return
+	}
+
+	// Whether a library is instrumented depends on the environment of the build, so report problems
+	// when the report is built rather than failing the whole build.
+	var errorMessage string
+	if len(uninstrumented) > 0 {
+		errorMessage = fmt.Sprintf("%s: instrumented_libs %q are not instrumented, build with EMMA_INSTRUMENT=true",
+			ctx.ModuleName(), uninstrumented)
+	} else if len(execFiles) == 0 {
+		errorMessage = fmt.Sprintf("%s: no jacoco execution data files found in %q",
+			ctx.ModuleName(), execFilesProperty)
+	}
+	if errorMessage != "" {
+		ctx.Build(pctx, android.BuildParams{
+			Rule:    android.ErrorRule,
+			Outputs: android.WritablePaths{mergedExecFile, htmlReport, xmlReport},
+			Args: map[string]string{
+				"error": errorMessage,
+			},
+		})
+		return
+	}
+
+	// Merge the execution data from all the test runs.
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        jacocoMerge,
+		Description: "merge coverage data",
+		Output:      mergedExecFile,
+		Inputs:      execFiles,
+	})
+
+	// Package the sources of all the instrumented modules into a single jar.
+	srcJar := android.PathForModuleOut(ctx, "sources.srcjar")
+	TransformResourcesToJar(ctx, srcJar, srcJarArgs, srcJarDeps)
+
+	var classFiles []string
+	for _, jar := range classesJars {
+		classFiles = append(classFiles, "--classfiles "+jar.String())
+	}
+	ctx.Build(pctx, android.BuildParams{
+		Rule:           jacocoReport,
+		Description:    "coverage report",
+		Output:         htmlReport,
+		ImplicitOutput: xmlReport,
+		Input:          mergedExecFile,
+		Implicits:      append(android.Paths{srcJar}, classesJars...),
+		Args: map[string]string{
+			"srcDir":     android.PathForModuleOut(ctx, "sources").String(),
+			"srcJar":     srcJar.String(),
+			"htmlDir":    android.PathForModuleOut(ctx, "html").String(),
+			"classFiles": strings.Join(classFiles, " "),
+			"name":       ctx.ModuleName(),
+			"xml":        xmlReport.String(),
+		},
+	})
+}
+
+func coverageReportPropertyName(tag interface{}) string {
+	if tag == coverageReportTestTag {
+		return "tests"
+	}
+	return "instrumented_libs"
+}
+
+// OutputFiles returns the reports, or the merged execution data for the ".ec" tag.
+func (r *coverageReport) OutputFiles(tag string) (android.Paths, error) {
+	switch tag {
+	case "":
+		return android.Paths{r.htmlReport, r.xmlReport}, nil
+	case ".html":
+		return android.Paths{r.htmlReport}, nil
+	case ".xml":
+		return android.Paths{r.xmlReport}, nil
+	case ".ec":
+		return android.Paths{r.mergedExecFile}, nil
+	default:
+		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
+	}
+}
+
+var _ android.OutputFileProducer = (*coverageReport)(nil)
@@ -0,0 +1,95 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+const coverageReportBp = `
+	android_app {
+		name: "app",
+		srcs: ["a.java"],
+		sdk_version: "current",
+	}
+
+	android_test {
+		name: "app_test",
+		srcs: ["b.java"],
+		instrumentation_for: "app",
+		sdk_version: "current",
+	}
+
+	java_coverage_report {
+		name: "app_coverage",
+		tests: ["app_test"],
+		instrumented_libs: ["app"],
+	}
+`
+
+func TestCoverageReport(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		PrepareForTestWithJavaCoverageReport,
+		android.FixtureMergeEnv(map[string]string{
+			"EMMA_INSTRUMENT": "true",
+		}),
+		android.FixtureMergeMockFs(android.MockFS{
+			"coverage/run1/app.ec": nil,
+			"coverage/run2/app.ec": nil,
+		}),
+	).RunTestWithBp(t, coverageReportBp)
+
+	report := result.ModuleForTests("app_coverage", "android_common")
+
+	merge := report.Output("app_coverage.ec")
+	android.AssertStringEquals(t, "merge rule", jacocoMerge.String(), merge.Rule.String())
+	android.AssertPathsRelativeToTopEquals(t, "merge inputs",
+		[]string{"coverage/run1/app.ec", "coverage/run2/app.ec"}, merge.Inputs)
+
+	xml := report.Output("app_coverage.xml")
+	android.AssertStringEquals(t, "report rule", jacocoReport.String(), xml.Rule.String())
+	android.AssertPathRelativeToTopEquals(t, "report input",
+		"out/soong/.intermediates/app_coverage/android_common/app_coverage.ec", xml.Input)
+	android.AssertStringEquals(t, "report classes",
+		"--classfiles out/soong/.intermediates/app/android_common/jacoco-report-classes/app.jar",
+		xml.Args["classFiles"])
+	android.AssertStringEquals(t, "report sources",
+		"out/soong/.intermediates/app_coverage/android_common/sources", xml.Args["srcDir"])
+	android.AssertPathRelativeToTopEquals(t, "html report",
+		"out/soong/.intermediates/app_coverage/android_common/app_coverage-html.zip", xml.Output)
+
+	outputs, err := report.Module().(android.OutputFileProducer).OutputFiles(".ec")
+	android.AssertBoolEquals(t, "OutputFiles error", false, err != nil)
+	android.AssertPathsRelativeToTopEquals(t, "merged execution data",
+		[]string{"out/soong/.intermediates/app_coverage/android_common/app_coverage.ec"}, outputs)
+}
+
+func TestCoverageReportNotInstrumented(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		PrepareForTestWithJavaCoverageReport,
+		android.FixtureMergeMockFs(android.MockFS{
+			"coverage/app.ec": nil,
+		}),
+	).RunTestWithBp(t, coverageReportBp)
+
+	// Instrumentation depends on the environment, so the failure is deferred to build time.
+	xml := result.ModuleForTests("app_coverage", "android_common").Output("app_coverage.xml")
+	android.AssertStringEquals(t, "rule", android.ErrorRule.String(), xml.Rule.String())
+	android.AssertStringDoesContain(t, "error", xml.Args["error"], `instrumented_libs ["app"] are not instrumented`)
+}
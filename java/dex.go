@@ -15,6 +15,7 @@
 package java
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -71,6 +72,14 @@ type DexProperties struct {
 
 		// Specifies the locations of files containing proguard flags.
 		Proguard_flags_files []string `android:"path"`
+
+		// Path to a checked-in golden copy of the merged proguard configuration, including the
+		// flags inherited from all dependencies.  If set, the build fails and prints a diff
+		// whenever the configuration R8 uses differs from the golden file, so that changes that
+		// add broad keep rules are visible in code review.  The comments R8 adds to mark where
+		// each section came from are removed before diffing, and paths are relative to the
+		// source tree with the output directory replaced by <OUT_DIR>.
+		Print_merged_config_diff *string `android:"path"`
 	}
 
 	// Keep the data uncompressed. We always need uncompressed dex for execution,
@@ -91,6 +100,7 @@ type dexer struct {
 	proguardDictionary     android.OptionalPath
 	proguardConfiguration  android.OptionalPath
 	proguardUsageZip       android.OptionalPath
+	proguardSeeds          android.OptionalPath
 
 	providesTransitiveHeaderJars
 }
@@ -133,7 +143,7 @@ var d8, d8RE = pctx.MultiCommandRemoteStaticRules("d8",
 var r8, r8RE = pctx.MultiCommandRemoteStaticRules("r8",
 	blueprint.RuleParams{
 		Command: `rm -rf "$outDir" && mkdir -p "$outDir" && ` +
-			`rm -f "$outDict" && rm -f "$outConfig" && rm -rf "${outUsageDir}" && ` +
+			`rm -f "$outDict" && rm -f "$outConfig" && rm -f "$outSeeds" && rm -rf "${outUsageDir}" && ` +
 			`mkdir -p $$(dirname ${outUsage}) && ` +
 			`mkdir -p $$(dirname $tmpJar) && ` +
 			`${config.Zip2ZipCmd} -i $in -o $tmpJar -x '**/*.dex' && ` +
@@ -142,9 +152,10 @@ var r8, r8RE = pctx.MultiCommandRemoteStaticRules("r8",
 			`-printmapping ${outDict} ` +
 			`--pg-conf-output ${outConfig} ` +
 			`-printusage ${outUsage} ` +
+			`-printseeds ${outSeeds} ` +
 			`--deps-file ${out}.d ` +
 			`$r8Flags && ` +
-			`touch "${outDict}" "${outConfig}" "${outUsage}" && ` +
+			`touch "${outDict}" "${outConfig}" "${outUsage}" "${outSeeds}" && ` +
 			`${config.SoongZipCmd} -o ${outUsageZip} -C ${outUsageDir} -f ${outUsage} && ` +
 			`rm -rf ${outUsageDir} && ` +
 			`$zipTemplate${config.SoongZipCmd} $zipFlags -o $outDir/classes.dex.jar -C $outDir -f "$outDir/classes*.dex" && ` +
@@ -161,7 +172,7 @@ var r8, r8RE = pctx.MultiCommandRemoteStaticRules("r8",
 		"$r8Template": &remoteexec.REParams{
 			Labels:          map[string]string{"type": "compile", "compiler": "r8"},
 			Inputs:          []string{"$implicits", "${config.R8Jar}"},
-			OutputFiles:     []string{"${outUsage}"},
+			OutputFiles:     []string{"${outUsage}", "${outSeeds}"},
 			ExecStrategy:    "${config.RER8ExecStrategy}",
 			ToolchainInputs: []string{"${config.JavaCmd}"},
 			Platform:        map[string]string{remoteexec.PoolKey: "${config.REJavaPool}"},
@@ -180,7 +191,7 @@ var r8, r8RE = pctx.MultiCommandRemoteStaticRules("r8",
 			ExecStrategy: "${config.RER8ExecStrategy}",
 			Platform:     map[string]string{remoteexec.PoolKey: "${config.REJavaPool}"},
 		},
-	}, []string{"outDir", "outDict", "outConfig", "outUsage", "outUsageZip", "outUsageDir",
+	}, []string{"outDir", "outDict", "outConfig", "outUsage", "outUsageZip", "outUsageDir", "outSeeds",
 		"r8Flags", "zipFlags", "tmpJar", "mergeZipsFlags"}, []string{"implicits"})
 
 func (d *dexer) dexCommonFlags(ctx android.ModuleContext,
@@ -340,6 +351,47 @@ func (d *dexer) r8Flags(ctx android.ModuleContext, flags javaBuilderFlags) (r8Fl
 	return r8Flags, r8Deps
 }
 
+// checkMergedProguardConfiguration creates a rule that fails with a diff if the merged proguard configuration
+// written by R8 differs from the checked-in golden file, and returns the timestamp file it produces.
+//
+// R8 annotates each section of the merged configuration with comments naming the file it came from, and
+// writes the paths of the files it references as they were passed to it.  Neither depends on the module
+// itself, so before diffing the comments are dropped and the paths are rewritten relative to the source
+// tree, with the output directory replaced by <OUT_DIR>, so that the golden file does not change with
+// OUT_DIR or the location of the source tree.
+func checkMergedProguardConfiguration(ctx android.ModuleContext, golden android.Path,
+	configuration android.Path) android.Path {
+
+	normalized := android.PathForModuleOut(ctx, "proguard_configuration.normalized")
+	timestamp := android.PathForModuleOut(ctx, "proguard_configuration_check.timestamp")
+
+	msg := fmt.Sprintf(`\n******************************\n`+
+		`The merged proguard configuration of %[1]s differs from %[2]s.\n\n`+
+		`If the change is intended, update the golden file by running:\n`+
+		`   cp %[3]s %[2]s\n`+
+		`******************************\n`, ctx.ModuleName(), golden.String(), normalized.String())
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		Text("sed").
+		Flag(`-e '/^# The proguard configuration file for the following section is /d'`).
+		Flag(`-e '/^# End of content from /d'`).
+		Flag(`-e "s|$PWD/||g"`).
+		Flag(`-e "s|` + ctx.Config().OutDir() + `/|<OUT_DIR>/|g"`).
+		Input(configuration).
+		Text(">").Output(normalized)
+	rule.Command().
+		Text("( diff -u").Input(golden).Input(normalized).
+		Text("&& touch").Output(timestamp).
+		Text(") || (").
+		Text("echo").Flag("-e").Flag(`"` + msg + `"`).
+		Text("; exit 1").
+		Text(")")
+	rule.Build("proguard_configuration_check", "check merged proguard configuration")
+
+	return timestamp
+}
+
 type compileDexParams struct {
 	flags         javaBuilderFlags
 	sdkVersion    android.SdkSpec
@@ -379,6 +431,8 @@ func (d *dexer) compileDex(ctx android.ModuleContext, dexParams *compileDexParam
 			android.ModuleNameWithPossibleOverride(ctx), "unused.txt")
 		proguardUsageZip := android.PathForModuleOut(ctx, "proguard_usage.zip")
 		d.proguardUsageZip = android.OptionalPathForPath(proguardUsageZip)
+		proguardSeeds := android.PathForModuleOut(ctx, "proguard_seeds")
+		d.proguardSeeds = android.OptionalPathForPath(proguardSeeds)
 		r8Flags, r8Deps := d.r8Flags(ctx, dexParams.flags)
 		r8Deps = append(r8Deps, commonDeps...)
 		rule := r8
@@ -390,6 +444,7 @@ func (d *dexer) compileDex(ctx android.ModuleContext, dexParams *compileDexParam
 			"outUsageDir":    proguardUsageDir.String(),
 			"outUsage":       proguardUsage.String(),
 			"outUsageZip":    proguardUsageZip.String(),
+			"outSeeds":       proguardSeeds.String(),
 			"outDir":         outDir.String(),
 			"tmpJar":         tmpJar.String(),
 			"mergeZipsFlags": mergeZipsFlags,
@@ -398,14 +453,21 @@ func (d *dexer) compileDex(ctx android.ModuleContext, dexParams *compileDexParam
 			rule = r8RE
 			args["implicits"] = strings.Join(r8Deps.Strings(), ",")
 		}
+		var validations android.Paths
+		if golden := d.dexProperties.Optimize.Print_merged_config_diff; golden != nil {
+			validations = append(validations, checkMergedProguardConfiguration(ctx,
+				android.PathForModuleSrc(ctx, *golden), proguardConfiguration))
+		}
 		ctx.Build(pctx, android.BuildParams{
-			Rule:            rule,
-			Description:     "r8",
-			Output:          javalibJar,
-			ImplicitOutputs: android.WritablePaths{proguardDictionary, proguardUsageZip},
-			Input:           dexParams.classesJar,
-			Implicits:       r8Deps,
-			Args:            args,
+			Rule:        rule,
+			Description: "r8",
+			Output:      javalibJar,
+			ImplicitOutputs: android.WritablePaths{proguardDictionary, proguardConfiguration,
+				proguardUsageZip, proguardSeeds},
+			Input:       dexParams.classesJar,
+			Implicits:   r8Deps,
+			Args:        args,
+			Validations: validations,
 		})
 	} else {
 		d8Flags, d8Deps := d8Flags(dexParams.flags)
@@ -286,6 +286,53 @@ func TestR8Flags(t *testing.T) {
 		appR8.Args["r8Flags"], "--android-platform-build")
 }
 
+func TestR8OutputFiles(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureAddTextFile("proguard_configuration.golden", ""),
+	).RunTestWithBp(t, `
+		android_app {
+			name: "app",
+			srcs: ["foo.java"],
+			platform_apis: true,
+			optimize: {
+				print_merged_config_diff: "proguard_configuration.golden",
+			},
+		}
+	`)
+
+	app := result.ModuleForTests("app", "android_common")
+	appR8 := app.Rule("r8")
+
+	producer := app.Module().(android.OutputFileProducer)
+	for tag, output := range map[string]string{
+		".proguard_map":    "proguard_dictionary",
+		".proguard_usage":  "proguard_usage.zip",
+		".proguard_seeds":  "proguard_seeds",
+		".proguard_config": "proguard_configuration",
+	} {
+		paths, err := producer.OutputFiles(tag)
+		android.AssertSame(t, tag+" error", nil, err)
+		android.AssertPathsRelativeToTopEquals(t, tag+" output files",
+			[]string{"out/soong/.intermediates/app/android_common/" + output}, paths)
+		android.AssertStringListContains(t, "r8 outputs", appR8.AllOutputs(), paths[0].String())
+	}
+	android.AssertStringDoesContain(t, "expected -printseeds in app r8 command",
+		appR8.Args["outSeeds"], "proguard_seeds")
+
+	check := app.Rule("proguard_configuration_check")
+	android.AssertStringListContains(t, "r8 validations", appR8.Validations.Strings(),
+		check.Output.String())
+	android.AssertStringDoesContain(t, "check command", check.RuleParams.Command,
+		"-e '/^# The proguard configuration file for the following section is /d'")
+	android.AssertStringDoesContain(t, "check command", check.RuleParams.Command,
+		"diff -u proguard_configuration.golden out/soong/.intermediates/app/android_common/proguard_configuration.normalized")
+	android.AssertPathsRelativeToTopEquals(t, "check inputs", []string{
+		"out/soong/.intermediates/app/android_common/proguard_configuration",
+		"proguard_configuration.golden",
+	}, check.Inputs)
+}
+
 func TestD8(t *testing.T) {
 	result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, `
 		java_library {
@@ -60,6 +60,11 @@ type Droidstubs struct {
 	apiLintTimestamp              android.WritablePath
 	apiLintReport                 android.WritablePath
 
+	// Structured (JSON) reports of the api-lint findings and of the changes from the last
+	// released API, see generateApiReports.
+	apiLintJson    android.WritablePath
+	apiChangesJson android.WritablePath
+
 	checkNullabilityWarningsTimestamp android.WritablePath
 
 	annotationsZip android.WritablePath
@@ -218,6 +223,16 @@ func (d *Droidstubs) OutputFiles(tag string) (android.Paths, error) {
 		return android.Paths{d.annotationsZip}, nil
 	case ".api_versions.xml":
 		return android.Paths{d.apiVersionsXml}, nil
+	case ".api_lint.json":
+		if d.apiLintJson == nil {
+			return nil, fmt.Errorf("%q is not generated because check_api.api_lint.enabled is not set", tag)
+		}
+		return android.Paths{d.apiLintJson}, nil
+	case ".api_changes.json":
+		if d.apiChangesJson == nil {
+			return nil, fmt.Errorf("%q is not generated because check_api.last_released is not set", tag)
+		}
+		return android.Paths{d.apiChangesJson}, nil
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
@@ -235,6 +250,17 @@ func (d *Droidstubs) RemovedApiFilePath() android.Path {
 	return d.removedApiFile
 }
 
+// ApiLintJsonPath returns the JSON report of the api-lint findings, or nil if api-lint is disabled.
+func (d *Droidstubs) ApiLintJsonPath() android.Path {
+	return d.apiLintJson
+}
+
+// ApiChangesJsonPath returns the JSON report of the changes from the last released API, or nil if
+// the API is not checked against the last released API.
+func (d *Droidstubs) ApiChangesJsonPath() android.Path {
+	return d.apiChangesJson
+}
+
 func (d *Droidstubs) StubsSrcJar() android.Path {
 	return d.stubsSrcJar
 }
@@ -727,6 +753,8 @@ func (d *Droidstubs) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 	rule.Build("metalava", "metalava merged")
 
+	d.generateApiReports(ctx, doApiLint, doCheckReleased)
+
 	if apiCheckEnabled(ctx, d.properties.Check_api.Current, "current") {
 
 		if len(d.Javadoc.properties.Out) > 0 {
@@ -842,6 +870,83 @@ func (d *Droidstubs) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 }
 
+// metalavaErrorExitStatus is the exit status of metalava when it reports errors.
+const metalavaErrorExitStatus = 255
+
+// metalavaAddedApiIssues are the compatibility issues that metalava reports for added APIs. They
+// are hidden by default, so they are reported with lint severity, which never fails the build,
+// when checking the API changes for the report.
+var metalavaAddedApiIssues = []string{
+	"AddedPackage",
+	"AddedClass",
+	"AddedInterface",
+	"AddedMethod",
+	"AddedField",
+}
+
+// generateApiReports writes structured (JSON) reports of the api-lint findings and of the APIs
+// added, removed or changed since the last released API, so that they can be aggregated across
+// modules into a single report for API review, see sdkLibraryApiReportSingleton.
+//
+// Both are converted from the issues reported by metalava, which include the suppressed and
+// baselined ones. The api-lint issues come from the main metalava run, while the changes come from
+// a separate compatibility check of the generated API file against the last released one, which
+// also reports the added APIs.
+func (d *Droidstubs) generateApiReports(ctx android.ModuleContext, doApiLint, doCheckReleased bool) {
+	if doApiLint {
+		d.apiLintJson = android.PathForModuleOut(ctx, "metalava", "api_lint.json")
+
+		rule := android.NewRuleBuilder(pctx, ctx)
+		rule.Command().
+			BuiltTool("api_report").
+			Text("lint").
+			FlagWithArg("--module ", ctx.ModuleName()).
+			FlagWithInput("--report ", d.apiLintReport).
+			FlagWithOutput("--output ", d.apiLintJson)
+		rule.Build("metalavaApiLintReport", "api lint report")
+	}
+
+	if doCheckReleased {
+		lastReleasedApiFile := android.PathForModuleSrc(ctx, String(d.properties.Check_api.Last_released.Api_file))
+		changesReport := android.PathForModuleOut(ctx, "metalava", "api_changes_report.txt")
+		homeDir := android.PathForModuleOut(ctx, "metalava", "api_changes_home")
+		d.apiChangesJson = android.PathForModuleOut(ctx, "metalava", "api_changes.json")
+
+		rule := android.NewRuleBuilder(pctx, ctx)
+		rule.Command().Text("rm -rf").Flag(homeDir.String())
+		rule.Command().Text("mkdir -p").Flag(homeDir.String())
+		// Remove the report of the previous build so that it is never published if metalava
+		// fails without writing one.
+		rule.Command().Text("rm -f").Flag(changesReport.String())
+		cmd := rule.Command().
+			FlagWithArg("ANDROID_PREFS_ROOT=", homeDir.String()).
+			BuiltTool("metalava").ImplicitTool(ctx.Config().HostJavaToolPath(ctx, "metalava.jar")).
+			Flag(config.JavacVmFlags).
+			Flag("-J--add-opens=java.base/java.util=ALL-UNNAMED").
+			FlagWithInput("--source-files ", d.apiFile).
+			Flag("--no-banner").
+			Flag("--quiet").
+			Flag("--format=v2").
+			FlagWithInput("--check-compatibility:api:released ", lastReleasedApiFile)
+		for _, issue := range metalavaAddedApiIssues {
+			cmd.FlagWithArg("--lint ", issue)
+		}
+		// Incompatible changes are enforced by the main metalava run, here they are only
+		// reported, so the exit status with which metalava reports errors is ignored as long as
+		// it wrote the report. Any other failure, e.g. a crash, fails the build.
+		cmd.FlagWithOutput("--report-even-if-suppressed ", changesReport).
+			Textf("|| [ $? -eq %d -a -f %s ]", metalavaErrorExitStatus, changesReport.String())
+		rule.Command().
+			BuiltTool("api_report").
+			Text("changes").
+			FlagWithArg("--module ", ctx.ModuleName()).
+			FlagWithInput("--report ", changesReport).
+			FlagWithOutput("--output ", d.apiChangesJson)
+		rule.Command().Text("rm -rf").Flag(homeDir.String())
+		rule.Build("metalavaApiChangesReport", "api changes report")
+	}
+}
+
 var _ android.ApiProvider = (*Droidstubs)(nil)
 
 type bazelJavaApiContributionAttributes struct {
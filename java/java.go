@@ -490,6 +490,7 @@ type deps struct {
 	processorPath           classpath
 	errorProneProcessorPath classpath
 	processorClasses        []string
+	kspProcessorPath        classpath
 	staticJars              android.Paths
 	staticHeaderJars        android.Paths
 	staticResourceJars      android.Paths
@@ -234,6 +234,97 @@ func kotlinKapt(ctx android.ModuleContext, srcJarOutputFile, resJarOutputFile an
 	TurbineApt(ctx, srcJarOutputFile, resJarOutputFile, javaSrcFiles, turbineSrcJars, flags)
 }
 
+var ksp = pctx.AndroidRemoteStaticRule("ksp", android.RemoteRuleSupports{Goma: true},
+	blueprint.RuleParams{
+		Command: `rm -rf "$srcJarDir" "$kotlinBuildFile" "$kspDir" "$emptyDir" && ` +
+			`mkdir -p "$srcJarDir" "$kspDir/kotlin" "$kspDir/java" "$kspDir/classes" "$kspDir/resources" ` +
+			`"$kspDir/caches" "$emptyDir" && ` +
+			`${config.ZipSyncCmd} -d $srcJarDir -l $srcJarDir/list -f "*.java" -f "*.kt" $srcJars && ` +
+			`${config.GenKotlinBuildFileCmd} --classpath "$classpath" --name "$name"` +
+			` --srcs "$out.rsp" --srcs "$srcJarDir/list"` +
+			` $commonSrcFilesArg --out "$kotlinBuildFile" && ` +
+			`${config.KotlincCmd} ${config.KotlincGlobalFlags} ` +
+			`${config.KotlincSuppressJDK9Warnings} ${config.JavacHeapFlags} ` +
+			`$kotlincFlags -jvm-target $kotlinJvmTarget -kotlin-home $emptyDir ` +
+			`-Xplugin=${config.KotlinKspApiJar} -Xplugin=${config.KotlinKspCmdlineJar} ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:apclasspath=$kspProcessorPath ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:projectBaseDir=$kspDir ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:kspOutputDir=$kspDir ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:kotlinOutputDir=$kspDir/kotlin ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:javaOutputDir=$kspDir/java ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:classOutputDir=$kspDir/classes ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:resourceOutputDir=$kspDir/resources ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:cachesDir=$kspDir/caches ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:incremental=false ` +
+			`-Xbuild-file=$kotlinBuildFile && ` +
+			`${config.SoongZipCmd} -jar -o $out -C $kspDir/kotlin -D $kspDir/kotlin -C $kspDir/java -D $kspDir/java && ` +
+			`${config.SoongZipCmd} -jar -o $resJar -C $kspDir/classes -D $kspDir/classes ` +
+			`-C $kspDir/resources -D $kspDir/resources && ` +
+			`rm -rf "$srcJarDir"`,
+		CommandDeps: []string{
+			"${config.KotlincCmd}",
+			"${config.KotlinCompilerJar}",
+			"${config.KotlinKspApiJar}",
+			"${config.KotlinKspCmdlineJar}",
+			"${config.GenKotlinBuildFileCmd}",
+			"${config.SoongZipCmd}",
+			"${config.ZipSyncCmd}",
+		},
+		Rspfile:        "$out.rsp",
+		RspfileContent: `$in`,
+	},
+	"kotlincFlags", "kspProcessorPath", "classpath", "srcJars", "commonSrcFilesArg", "srcJarDir",
+	"kspDir", "emptyDir", "kotlinJvmTarget", "kotlinBuildFile", "name", "resJar")
+
+// kotlinKsp runs Kotlin Symbol Processing processors.  It takes .kt and .java sources and srcjars, and runs the KSP
+// processors in flags.kspProcessorPath over all of them, producing a srcjar of generated .kt and .java code in
+// srcJarOutputFile and a jar of generated classes and resources in resJarOutputFile.  Unlike kapt, KSP does not need
+// to generate stubs.  The srcjar should be added as an additional input to the kotlinc and javac rules.
+func kotlinKsp(ctx android.ModuleContext, srcJarOutputFile, resJarOutputFile android.WritablePath,
+	srcFiles, commonSrcFiles, srcJars android.Paths,
+	flags javaBuilderFlags) {
+
+	var deps android.Paths
+	deps = append(deps, flags.kotlincClasspath...)
+	deps = append(deps, flags.kotlincDeps...)
+	deps = append(deps, srcJars...)
+	deps = append(deps, flags.kspProcessorPath...)
+	deps = append(deps, commonSrcFiles...)
+
+	commonSrcsList := kotlinCommonSrcsList(ctx, commonSrcFiles)
+	commonSrcFilesArg := ""
+	if commonSrcsList.Valid() {
+		deps = append(deps, commonSrcsList.Path())
+		commonSrcFilesArg = "--common_srcs " + commonSrcsList.String()
+	}
+
+	kotlinName := filepath.Join(ctx.ModuleDir(), ctx.ModuleSubDir(), ctx.ModuleName())
+	kotlinName = strings.ReplaceAll(kotlinName, "/", "__")
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:           ksp,
+		Description:    "ksp",
+		Output:         srcJarOutputFile,
+		ImplicitOutput: resJarOutputFile,
+		Inputs:         srcFiles,
+		Implicits:      deps,
+		Args: map[string]string{
+			"classpath":         flags.kotlincClasspath.FormJavaClassPath(""),
+			"kotlincFlags":      flags.kotlincFlags,
+			"commonSrcFilesArg": commonSrcFilesArg,
+			"srcJars":           strings.Join(srcJars.Strings(), " "),
+			"srcJarDir":         android.PathForModuleOut(ctx, "ksp", "srcJars").String(),
+			"kotlinBuildFile":   android.PathForModuleOut(ctx, "ksp", "build.xml").String(),
+			"kspProcessorPath":  strings.Join(flags.kspProcessorPath.Strings(), ":"),
+			"kspDir":            android.PathForModuleOut(ctx, "ksp/gen").String(),
+			"emptyDir":          android.PathForModuleOut(ctx, "ksp", "empty").String(),
+			"kotlinJvmTarget":   flags.javaVersion.StringForKotlinc(),
+			"name":              kotlinName,
+			"resJar":            resJarOutputFile.String(),
+		},
+	})
+}
+
 // kapt converts a list of key, value pairs into a base64 encoded Java serialization, which is what kapt expects.
 func kaptEncodeFlags(options [][2]string) string {
 	buf := &bytes.Buffer{}
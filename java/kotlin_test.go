@@ -15,6 +15,7 @@
 package java
 
 import (
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
@@ -280,6 +281,141 @@ func TestKapt(t *testing.T) {
 	})
 }
 
+func TestKsp(t *testing.T) {
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["a.java", "b.kt"],
+			plugins: ["bar", "baz"],
+		}
+
+		java_plugin {
+			name: "bar",
+			kind: "ksp",
+			srcs: ["b.java"],
+		}
+
+		java_plugin {
+			name: "baz",
+			processor_class: "com.baz",
+			srcs: ["b.java"],
+		}
+	`
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+	).RunTestWithBp(t, bp)
+
+	buildOS := result.Config.BuildOS.String()
+
+	foo := result.ModuleForTests("foo", "android_common")
+	ksp := foo.Rule("ksp")
+	kaptStubs := foo.Rule("kapt")
+	turbineApt := foo.Description("turbine apt")
+	kotlinc := foo.Rule("kotlinc")
+	javac := foo.Rule("javac")
+
+	bar := result.ModuleForTests("bar", buildOS+"_common").Rule("javac").Output.String()
+	baz := result.ModuleForTests("baz", buildOS+"_common").Rule("javac").Output.String()
+
+	// Test that the kotlin and java sources are passed to ksp
+	android.AssertPathsRelativeToTopEquals(t, "ksp inputs", []string{"a.java", "b.kt"}, ksp.Inputs)
+
+	// Test that only the ksp processor is passed to ksp, and only the annotation processor to kapt
+	android.AssertStringEquals(t, "ksp processor path", bar, ksp.Args["kspProcessorPath"])
+	android.AssertStringEquals(t, "kapt processor path",
+		"-P plugin:org.jetbrains.kotlin.kapt3:apclasspath="+baz, kaptStubs.Args["kaptProcessorPath"])
+	android.AssertStringDoesNotContain(t, "turbine apt flags", turbineApt.Args["turbineFlags"], bar)
+
+	// Test that the ksp srcjar is passed to kapt, kotlinc and javac
+	kspSrcJar := ksp.Output.String()
+	android.AssertStringListContains(t, "kapt implicits", kaptStubs.Implicits.Strings(), kspSrcJar)
+	android.AssertStringDoesContain(t, "kapt srcjars", kaptStubs.Args["srcJars"], kspSrcJar)
+	android.AssertStringListContains(t, "kotlinc implicits", kotlinc.Implicits.Strings(), kspSrcJar)
+	android.AssertStringDoesContain(t, "kotlinc srcjars", kotlinc.Args["srcJars"], kspSrcJar)
+	android.AssertStringListContains(t, "javac implicits", javac.Implicits.Strings(), kspSrcJar)
+	android.AssertStringDoesContain(t, "javac srcjars", javac.Args["srcJars"], kspSrcJar)
+
+	// Test that the ksp resource jar is merged into the output jar
+	kspResJar := ksp.ImplicitOutput.String()
+	combineJar := foo.Description("for javac")
+	android.AssertStringListContains(t, "combined jar inputs", combineJar.Inputs.Strings(), kspResJar)
+}
+
+func TestKspErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		bp   string
+		err  string
+	}{
+		{
+			name: "no kotlin sources",
+			bp: `
+				java_library {
+					name: "foo",
+					srcs: ["a.java"],
+					plugins: ["bar"],
+				}
+
+				java_plugin {
+					name: "bar",
+					kind: "ksp",
+					srcs: ["b.java"],
+				}
+			`,
+			err: `ksp plugins can only be used in modules with kotlin sources`,
+		},
+		{
+			name: "processor_class",
+			bp: `
+				java_plugin {
+					name: "bar",
+					kind: "ksp",
+					processor_class: "com.bar",
+					srcs: ["b.java"],
+				}
+			`,
+			err: `processor_class: not supported for ksp plugins`,
+		},
+		{
+			name: "unknown kind",
+			bp: `
+				java_plugin {
+					name: "bar",
+					kind: "kotlin",
+					srcs: ["b.java"],
+				}
+			`,
+			err: `unknown plugin kind "kotlin"`,
+		},
+		{
+			name: "exported",
+			bp: `
+				java_library {
+					name: "foo",
+					srcs: ["a.java", "b.kt"],
+					exported_plugins: ["bar"],
+				}
+
+				java_plugin {
+					name: "bar",
+					kind: "ksp",
+					srcs: ["b.java"],
+				}
+			`,
+			err: `ksp plugin "bar" cannot be exported`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			android.GroupFixturePreparers(
+				PrepareForTestWithJavaDefaultModules,
+			).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(regexp.QuoteMeta(tc.err))).
+				RunTestWithBp(t, tc.bp)
+		})
+	}
+}
+
 func TestKaptEncodeFlags(t *testing.T) {
 	// Compares the kaptEncodeFlags against the results of the example implementation at
 	// https://kotlinlang.org/docs/reference/kapt.html#apjavac-options-encoding
@@ -57,12 +57,25 @@ type platformBootclasspathModule struct {
 
 	// Path to the monolithic hiddenapi-unsupported.csv file.
 	hiddenAPIMetadataCSV android.OutputPath
+
+	// Path to the report of the differences between the monolithic hiddenapi-flags.csv file and
+	// the reference flags, nil if no reference flags were specified.
+	hiddenAPIFlagsDiff android.Path
 }
 
 type platformBootclasspathProperties struct {
 	BootclasspathFragmentsDepsProperties
 
 	HiddenAPIFlagFileProperties
+
+	// Path to a reference set of hidden API flags, e.g. the hiddenapi-flags.csv file from the
+	// previous release.
+	//
+	// If specified then a report is generated that lists every signature that moved between the
+	// sdk, unsupported, blocked and max-target-* lists since the reference, along with the modules
+	// and flag files that are responsible. It is available from the hiddenapi-flags-diff.txt output
+	// tag and built by the hiddenapi-flags-diff phony target.
+	Hidden_api_reference_flags *string `android:"path"`
 }
 
 func platformBootclasspathFactory() android.SingletonModule {
@@ -95,6 +108,11 @@ func (b *platformBootclasspathModule) OutputFiles(tag string) (android.Paths, er
 		return android.Paths{b.hiddenAPIIndexCSV}, nil
 	case "hiddenapi-metadata.csv":
 		return android.Paths{b.hiddenAPIMetadataCSV}, nil
+	case "hiddenapi-flags-diff.txt":
+		if b.hiddenAPIFlagsDiff == nil {
+			return nil, fmt.Errorf("hidden_api_reference_flags is not specified")
+		}
+		return android.Paths{b.hiddenAPIFlagsDiff}, nil
 	}
 
 	return nil, fmt.Errorf("unknown tag %s", tag)
@@ -325,6 +343,12 @@ func (b *platformBootclasspathModule) generateHiddenAPIBuildActions(ctx android.
 	allFlags := hiddenAPISingletonPaths(ctx).flags
 	buildRuleToGenerateHiddenApiFlags(ctx, "hiddenAPIFlagsFile", "monolithic hidden API flags", allFlags, stubFlags, allAnnotationFlagFiles, monolithicInfo.FlagsFilesByCategory, monolithicInfo.FlagSubsets, android.OptionalPath{})
 
+	// Compare the monolithic hiddenapi-flags.csv file against the reference flags, if any.
+	if referenceFlags := android.OptionalPathForModuleSrc(ctx, b.properties.Hidden_api_reference_flags); referenceFlags.Valid() {
+		b.hiddenAPIFlagsDiff = b.buildRuleToGenerateHiddenAPIFlagsDiff(ctx, referenceFlags.Path(), allFlags, classpathElements)
+		ctx.Phony("hiddenapi-flags-diff", b.hiddenAPIFlagsDiff)
+	}
+
 	// Generate an intermediate monolithic hiddenapi-metadata.csv file directly from the annotations
 	// in the source code.
 	intermediateMetadataCSV := android.PathForModuleOut(ctx, "hiddenapi-monolithic", "metadata-from-classes.csv")
@@ -378,6 +402,52 @@ func (b *platformBootclasspathModule) createAndProvideMonolithicHiddenAPIInfo(ct
 	return monolithicInfo
 }
 
+// buildRuleToGenerateHiddenAPIFlagsDiff generates the rule that reports the signatures whose API
+// list differs between the reference flags and the monolithic flags.
+//
+// Each flag file that contributed to the monolithic flags is passed to the tool along with the name
+// of the module that specified it, i.e. this module or one of the fragments, so that each change
+// can be attributed to the module and flag file responsible.
+func (b *platformBootclasspathModule) buildRuleToGenerateHiddenAPIFlagsDiff(ctx android.ModuleContext, referenceFlags, allFlags android.Path, classpathElements ClasspathElements) android.Path {
+	outputPath := android.PathForModuleOut(ctx, "hiddenapi-monolithic", "hiddenapi-flags-diff.txt")
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	command := rule.Command().
+		BuiltTool("diff_flags").
+		FlagWithInput("--reference ", referenceFlags).
+		FlagWithInput("--current ", allFlags)
+
+	addFlagFiles := func(moduleName string, flagFilesByCategory FlagFilesByCategory) {
+		for _, category := range HiddenAPIFlagFileCategories {
+			for _, path := range flagFilesByCategory[category] {
+				command.Implicit(path).
+					FlagWithArg("--flag-file ", moduleName+":"+category.PropertyName+":"+path.String())
+			}
+		}
+	}
+
+	// The flag files specified on this module.
+	propertyInfo := newHiddenAPIPropertyInfo()
+	propertyInfo.extractFlagFilesFromProperties(ctx, &b.properties.HiddenAPIFlagFileProperties)
+	addFlagFiles(ctx.ModuleName(), propertyInfo.FlagFilesByCategory)
+
+	// The flag files provided by each of the fragments.
+	for _, element := range classpathElements {
+		if e, ok := element.(*ClasspathFragmentElement); ok {
+			fragment := e.Module()
+			if ctx.OtherModuleHasProvider(fragment, HiddenAPIInfoProvider) {
+				info := ctx.OtherModuleProvider(fragment, HiddenAPIInfoProvider).(HiddenAPIInfo)
+				addFlagFiles(ctx.OtherModuleName(fragment), info.FlagFilesByCategory)
+			}
+		}
+	}
+
+	command.FlagWithOutput("--output ", outputPath)
+
+	rule.Build("hiddenAPIFlagsDiff", "hidden API flags diff")
+	return outputPath
+}
+
 func (b *platformBootclasspathModule) buildRuleMergeCSV(ctx android.ModuleContext, desc string, inputPaths android.Paths, outputPath android.WritablePath) {
 	rule := android.NewRuleBuilder(pctx, ctx)
 	rule.Command().
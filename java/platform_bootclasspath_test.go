@@ -357,3 +357,46 @@ func TestPlatformBootclasspath_HiddenAPIMonolithicFiles(t *testing.T) {
 		out/soong/.intermediates/myplatform-bootclasspath/android_common/hiddenapi-monolithic/index-from-classes.csv
 	`, rule)
 }
+
+func TestPlatformBootclasspath_HiddenAPIFlagsDiff(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		hiddenApiFixtureFactory,
+		FixtureConfigureBootJars("platform:foo"),
+		android.FixtureMergeMockFs(android.MockFS{
+			"reference-flags.csv": nil,
+			"blocked.txt":         nil,
+		}),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			compile_dex: true,
+		}
+
+		platform_bootclasspath {
+			name: "myplatform-bootclasspath",
+			hidden_api: {
+				blocked: ["blocked.txt"],
+			},
+			hidden_api_reference_flags: "reference-flags.csv",
+		}
+	`)
+
+	platformBootclasspath := result.ModuleForTests("myplatform-bootclasspath", "android_common")
+	rule := platformBootclasspath.Output("hiddenapi-monolithic/hiddenapi-flags-diff.txt")
+
+	CheckHiddenAPIRuleInputs(t, "flags diff", `
+		blocked.txt
+		out/soong/hiddenapi/hiddenapi-flags.csv
+		reference-flags.csv
+	`, rule)
+	android.AssertStringDoesContain(t, "command", rule.RuleParams.Command,
+		"--reference reference-flags.csv --current out/soong/hiddenapi/hiddenapi-flags.csv "+
+			"--flag-file myplatform-bootclasspath:blocked:blocked.txt")
+
+	outputs, err := platformBootclasspath.Module().(android.OutputFileProducer).OutputFiles("hiddenapi-flags-diff.txt")
+	android.AssertSame(t, "output files error", nil, err)
+	android.AssertPathsRelativeToTopEquals(t, "output files",
+		[]string{"out/soong/.intermediates/myplatform-bootclasspath/android_common/hiddenapi-monolithic/hiddenapi-flags-diff.txt"},
+		outputs)
+}
@@ -267,6 +267,12 @@ func platformCompatConfigSingletonFactory() android.Singleton {
 type globalCompatConfigProperties struct {
 	// name of the file into which the metadata will be copied.
 	Filename *string
+
+	// path to a checked-in snapshot of the merged compat config. If set, the build fails when a
+	// change ID in the snapshot is removed, or when its enableAfterTargetSdk, enableSinceTargetSdk
+	// or disabled state changes, until the snapshot is updated with
+	// `m <module name>-update-snapshot`.
+	Snapshot *string `android:"path"`
 }
 
 type globalCompatConfig struct {
@@ -275,6 +281,9 @@ type globalCompatConfig struct {
 	properties globalCompatConfigProperties
 
 	outputFilePath android.OutputPath
+
+	checkSnapshotTimestamp  android.WritablePath
+	updateSnapshotTimestamp android.WritablePath
 }
 
 func (c *globalCompatConfig) GenerateAndroidBuildActions(ctx android.ModuleContext) {
@@ -290,6 +299,64 @@ func (c *globalCompatConfig) GenerateAndroidBuildActions(ctx android.ModuleConte
 		Output: c.outputFilePath,
 		Input:  inputPath,
 	})
+
+	if c.properties.Snapshot != nil {
+		c.buildSnapshotRules(ctx, inputPath, android.PathForModuleSrc(ctx, *c.properties.Snapshot))
+	}
+}
+
+// buildSnapshotRules creates the rules to check the merged compat config against the checked-in
+// snapshot, and to update the snapshot.
+func (c *globalCompatConfig) buildSnapshotRules(ctx android.ModuleContext, merged, snapshot android.Path) {
+	updateTarget := ctx.ModuleName() + "-update-snapshot"
+
+	c.checkSnapshotTimestamp = android.PathForModuleOut(ctx, "check_snapshot.timestamp")
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+
+	rule.Command().Text("( true")
+
+	rule.Command().
+		BuiltTool("compat_config_snapshot").
+		FlagWithInput("--snapshot ", snapshot).
+		FlagWithInput("--current ", merged)
+
+	msg := fmt.Sprintf(`\n******************************\n`+
+		`Compat change IDs are API: the changes above break the compat config snapshot\n`+
+		`%s.\n\n`+
+		`If the changes are intended, update the snapshot by running:\n`+
+		`   m %s\n`+
+		`******************************\n`, snapshot, updateTarget)
+
+	rule.Command().
+		Text("touch").Output(c.checkSnapshotTimestamp).
+		Text(") || (").
+		Text("echo").Flag("-e").Flag(`"` + msg + `"`).
+		Text("; exit 38").
+		Text(")")
+
+	rule.Build("compatConfigSnapshotCheck", "check compat config snapshot")
+
+	// The droidcore phony target depends on the check so that the snapshot cannot be broken
+	// accidentally.
+	checkTarget := ctx.ModuleName() + "-check-snapshot"
+	ctx.Phony(checkTarget, c.checkSnapshotTimestamp)
+	ctx.Phony("droidcore", android.PathForPhony(ctx, checkTarget))
+
+	c.updateSnapshotTimestamp = android.PathForModuleOut(ctx, "update_snapshot.timestamp")
+
+	rule = android.NewRuleBuilder(pctx, ctx)
+
+	rule.Command().
+		Text("cp").Flag("-f").
+		Input(merged).Flag(snapshot.String())
+
+	rule.Command().
+		Text("touch").Output(c.updateSnapshotTimestamp)
+
+	rule.Build("compatConfigSnapshotUpdate", "update compat config snapshot")
+
+	ctx.Phony(updateTarget, c.updateSnapshotTimestamp)
 }
 
 func (h *globalCompatConfig) OutputFiles(tag string) (android.Paths, error) {
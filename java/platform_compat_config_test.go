@@ -42,3 +42,34 @@ func TestPlatformCompatConfig(t *testing.T) {
 		"out/soong/.intermediates/myconfig3/myconfig3_meta.xml",
 	)
 }
+
+func TestGlobalCompatConfigSnapshot(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithPlatformCompatConfig,
+		android.FixtureMergeMockFs(android.MockFS{
+			"compat/snapshot.xml": nil,
+		}),
+		android.FixtureWithRootAndroidBp(`
+			platform_compat_config {
+				name: "myconfig",
+			}
+			global_compat_config {
+				name: "global-compat-config",
+				filename: "compat_config.xml",
+				snapshot: "compat/snapshot.xml",
+			}
+		`),
+	).RunTest(t)
+
+	global := result.ModuleForTests("global-compat-config", "android_common")
+
+	check := global.Output("check_snapshot.timestamp")
+	android.AssertStringDoesContain(t, "check command", check.RuleParams.Command,
+		"--snapshot compat/snapshot.xml --current out/soong/compat_config/merged_compat_config.xml")
+	android.AssertStringDoesContain(t, "check message", check.RuleParams.Command,
+		"m global-compat-config-update-snapshot")
+
+	update := global.Output("update_snapshot.timestamp")
+	android.AssertStringDoesContain(t, "update command", update.RuleParams.Command,
+		"cp -f out/soong/compat_config/merged_compat_config.xml compat/snapshot.xml")
+}
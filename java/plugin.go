@@ -55,6 +55,40 @@ type PluginProperties struct {
 	// This necessitates disabling the turbine optimization on modules that use this plugin, which will reduce
 	// parallelism and cause more recompilation for modules that depend on modules that use this plugin.
 	Generates_api *bool
+
+	// The kind of processor this plugin contains, either "annotation_processor" (the default) for a
+	// javac annotation processor, or "ksp" for a Kotlin Symbol Processing processor.  KSP processors
+	// are discovered through their SymbolProcessorProvider services, so processor_class and generates_api
+	// are not supported for them.  KSP processors only run on modules that contain Kotlin sources.
+	Kind *string
+}
+
+const (
+	pluginKindAnnotationProcessor = "annotation_processor"
+	pluginKindKsp                 = "ksp"
+)
+
+// isKsp returns true if the plugin is a Kotlin Symbol Processing processor.
+func (p *Plugin) isKsp() bool {
+	return String(p.pluginProperties.Kind) == pluginKindKsp
+}
+
+func (p *Plugin) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	switch kind := String(p.pluginProperties.Kind); kind {
+	case "", pluginKindAnnotationProcessor:
+	case pluginKindKsp:
+		if p.pluginProperties.Processor_class != nil {
+			ctx.PropertyErrorf("processor_class", "not supported for ksp plugins")
+		}
+		if p.pluginProperties.Generates_api != nil {
+			ctx.PropertyErrorf("generates_api", "not supported for ksp plugins")
+		}
+	default:
+		ctx.PropertyErrorf("kind", "unknown plugin kind %q, must be %q or %q",
+			kind, pluginKindAnnotationProcessor, pluginKindKsp)
+	}
+
+	p.Library.GenerateAndroidBuildActions(ctx)
 }
 
 type pluginAttributes struct {
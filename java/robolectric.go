@@ -58,8 +58,27 @@ type robolectricProperties struct {
 		// Timeout in seconds when running the tests.
 		Timeout *int64
 
-		// Number of shards to use when running the tests.
+		// Number of shards to use when running the tests. The test classes are split between the
+		// shards and each shard gets its own test config, <name>_shard<N>.config, which is
+		// installed in the test suite instead of the test config of the whole module.
 		Shards *int64
+
+		// Only run the tests that match one of the filters, which can be a test class, a package or
+		// a <class>#<method>. Passed to the test runner as include-filter options. The Make runner
+		// can only filter test classes, so it runs all of the tests in a class that a
+		// <class>#<method> filter matches.
+		Include_filters []string
+
+		// Do not run the tests that match one of the filters. Passed to the test runner as
+		// exclude-filter options. The Make runner can only filter test classes, so it ignores
+		// <class>#<method> filters.
+		Exclude_filters []string
+
+		// A file with the run time of each test class from a previous run, used to balance the test
+		// classes between shards. Each line contains a test class and its run time in seconds,
+		// separated by whitespace or a comma. Without it each test class is assumed to take the
+		// same time.
+		Shard_timings *string `android:"path"`
 	}
 
 	// The version number of a robolectric prebuilt to use from prebuilts/misc/common/robolectric
@@ -90,6 +109,9 @@ type robolectricTest struct {
 	testConfig android.Path
 	data       android.Paths
 
+	// The test configs of the shards when the tests are sharded.
+	shardTestConfigs android.Paths
+
 	forceOSType   android.OsType
 	forceArchType android.ArchType
 }
@@ -131,13 +153,22 @@ func (r *robolectricTest) GenerateAndroidBuildActions(ctx android.ModuleContext)
 	r.forceOSType = ctx.Config().BuildOS
 	r.forceArchType = ctx.Config().BuildArch
 
+	var filterOptions []tradefed.Option
+	for _, f := range r.robolectricProperties.Test_options.Include_filters {
+		filterOptions = append(filterOptions, tradefed.Option{Name: "include-filter", Value: f})
+	}
+	for _, f := range r.robolectricProperties.Test_options.Exclude_filters {
+		filterOptions = append(filterOptions, tradefed.Option{Name: "exclude-filter", Value: f})
+	}
+
 	r.testConfig = tradefed.AutoGenTestConfig(ctx, tradefed.AutoGenTestConfigOptions{
-		TestConfigProp:         r.testProperties.Test_config,
-		TestConfigTemplateProp: r.testProperties.Test_config_template,
-		TestSuites:             r.testProperties.Test_suites,
-		AutoGenConfig:          r.testProperties.Auto_gen_config,
-		DeviceTemplate:         "${RobolectricTestConfigTemplate}",
-		HostTemplate:           "${RobolectricTestConfigTemplate}",
+		TestConfigProp:          r.testProperties.Test_config,
+		TestConfigTemplateProp:  r.testProperties.Test_config_template,
+		TestSuites:              r.testProperties.Test_suites,
+		OptionsForAutogenerated: filterOptions,
+		AutoGenConfig:           r.testProperties.Auto_gen_config,
+		DeviceTemplate:          "${RobolectricTestConfigTemplate}",
+		HostTemplate:            "${RobolectricTestConfigTemplate}",
 	})
 	r.data = android.PathsForModuleSrc(ctx, r.testProperties.Data)
 
@@ -201,6 +232,7 @@ func (r *robolectricTest) GenerateAndroidBuildActions(ctx android.ModuleContext)
 
 	// TODO: this could all be removed if tradefed was used as the test runner, it will find everything
 	// annotated as a test and run it.
+	var testSrcs android.Paths
 	for _, src := range r.uniqueSrcFiles {
 		s := src.Rel()
 		if !strings.HasSuffix(s, "Test.java") && !strings.HasSuffix(s, "Test.kt") {
@@ -211,23 +243,44 @@ func (r *robolectricTest) GenerateAndroidBuildActions(ctx android.ModuleContext)
 			s = strings.TrimPrefix(s, "src/")
 		}
 		r.tests = append(r.tests, s)
+		testSrcs = append(testSrcs, src)
 	}
 
 	r.data = append(r.data, r.manifest, r.resourceApk)
 
+	if s := r.robolectricProperties.Test_options.Shards; s != nil && *s > 1 && r.testConfig != nil && len(r.tests) > 0 {
+		// There is no point in having more shards than test classes.
+		numShards := int(*s)
+		if numShards > len(r.tests) {
+			numShards = len(r.tests)
+		}
+		r.shardTestConfigs = r.generateShardTestConfigs(ctx, testSrcs, numShards)
+	}
+
 	runtimes := ctx.GetDirectDepWithTag("robolectric-android-all-prebuilts", roboRuntimesTag)
 
 	installPath := android.PathForModuleInstall(ctx, r.BaseModuleName())
 
 	installedResourceApk := ctx.InstallFile(installPath, ctx.ModuleName()+".apk", r.resourceApk)
 	installedManifest := ctx.InstallFile(installPath, ctx.ModuleName()+"-AndroidManifest.xml", r.manifest)
-	installedConfig := ctx.InstallFile(installPath, ctx.ModuleName()+".config", r.testConfig)
 
 	var installDeps android.Paths
 	for _, runtime := range runtimes.(*robolectricRuntimes).runtimes {
 		installDeps = append(installDeps, runtime)
 	}
-	installDeps = append(installDeps, installedResourceApk, installedManifest, installedConfig)
+	installDeps = append(installDeps, installedResourceApk, installedManifest)
+
+	// The test configs of the shards replace the test config of the whole module, otherwise the
+	// tests would run once for the whole module and once for the shards.
+	if len(r.shardTestConfigs) > 0 {
+		for i, shardTestConfig := range r.shardTestConfigs {
+			installedShardConfig := ctx.InstallFile(installPath, fmt.Sprintf("%s_shard%d.config", ctx.ModuleName(), i), shardTestConfig)
+			installDeps = append(installDeps, installedShardConfig)
+		}
+	} else {
+		installedConfig := ctx.InstallFile(installPath, ctx.ModuleName()+".config", r.testConfig)
+		installDeps = append(installDeps, installedConfig)
+	}
 
 	for _, data := range android.PathsForModuleSrc(ctx, r.testProperties.Data) {
 		installedData := ctx.InstallFile(installPath, data.Rel(), data)
@@ -278,6 +331,39 @@ func generateSameDirRoboTestConfigJar(ctx android.ModuleContext, outputFile andr
 	rule.Build("generate_test_config_samedir", "generate test_config.properties")
 }
 
+// generateShardTestConfigs splits the test classes between numShards shards, balanced by the run
+// times from the shard_timings file if one is specified, and generates a test config for each
+// shard that only runs the test classes in that shard.  The test classes are named after the
+// package declarations of the test sources, which do not have to be in a directory matching the
+// package.
+func (r *robolectricTest) generateShardTestConfigs(ctx android.ModuleContext, testSrcs android.Paths, numShards int) android.Paths {
+	testsFile := android.PathForModuleOut(ctx, "robolectric_shards", "tests.txt")
+	android.WriteFileRule(ctx, testsFile, strings.Join(testSrcs.Strings(), "\n"))
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	cmd := rule.Command().
+		BuiltTool("robolectric_shard").
+		FlagWithInput("--config ", r.testConfig).
+		FlagWithInput("--tests ", testsFile).
+		Implicits(testSrcs)
+	if timings := android.OptionalPathForModuleSrc(ctx, r.robolectricProperties.Test_options.Shard_timings); timings.Valid() {
+		cmd.FlagWithInput("--timings ", timings.Path())
+	}
+	for _, f := range r.robolectricProperties.Test_options.Include_filters {
+		cmd.FlagWithArg("--include-filter ", proptools.ShellEscape(f))
+	}
+
+	var shardTestConfigs android.WritablePaths
+	for i := 0; i < numShards; i++ {
+		shardTestConfig := android.PathForModuleOut(ctx, "robolectric_shards", fmt.Sprintf("%s_shard%d.config", ctx.ModuleName(), i))
+		cmd.FlagWithOutput("--output ", shardTestConfig)
+		shardTestConfigs = append(shardTestConfigs, shardTestConfig)
+	}
+
+	rule.Build("robolectric_shards", "robolectric shard test configs")
+	return shardTestConfigs.Paths()
+}
+
 func (r *robolectricTest) generateRoboSrcJar(ctx android.ModuleContext, outputFile android.WritablePath,
 	instrumentedApp *AndroidApp) {
 
@@ -302,17 +388,22 @@ func (r *robolectricTest) AndroidMkEntries() []android.AndroidMkEntries {
 		func(ctx android.AndroidMkExtraEntriesContext, entries *android.AndroidMkEntries) {
 			entries.SetBool("LOCAL_UNINSTALLABLE_MODULE", true)
 			entries.AddStrings("LOCAL_COMPATIBILITY_SUITE", "robolectric-tests")
-			if r.testConfig != nil {
+			if len(r.shardTestConfigs) > 0 {
+				androidMkWriteExtraTestConfigs(r.shardTestConfigs, entries)
+			} else if r.testConfig != nil {
 				entries.SetPath("LOCAL_FULL_TEST_CONFIG", r.testConfig)
 			}
 		})
 
 	entries.ExtraFooters = []android.AndroidMkExtraFootersFunc{
 		func(w io.Writer, name, prefix, moduleDir string) {
+			tests := filterRobolectricTests(r.tests,
+				r.robolectricProperties.Test_options.Include_filters,
+				r.robolectricProperties.Test_options.Exclude_filters)
 			if s := r.robolectricProperties.Test_options.Shards; s != nil && *s > 1 {
 				numShards := int(*s)
-				shardSize := (len(r.tests) + numShards - 1) / numShards
-				shards := android.ShardStrings(r.tests, shardSize)
+				shardSize := (len(tests) + numShards - 1) / numShards
+				shards := android.ShardStrings(tests, shardSize)
 				for i, shard := range shards {
 					r.writeTestRunner(w, name, "Run"+name+strconv.Itoa(i), shard)
 				}
@@ -326,7 +417,7 @@ func (r *robolectricTest) AndroidMkEntries() []android.AndroidMkEntries {
 				}
 				fmt.Fprintln(w, "")
 			} else {
-				r.writeTestRunner(w, name, "Run"+name, r.tests)
+				r.writeTestRunner(w, name, "Run"+name, tests)
 			}
 		},
 	}
@@ -334,6 +425,46 @@ func (r *robolectricTest) AndroidMkEntries() []android.AndroidMkEntries {
 	return entriesList
 }
 
+// robolectricTestClass returns the name of the test class in a test source file for the Make
+// runner, e.g. com.android.foo.FooTest for com/android/foo/FooTest.java.  Like the Make runner,
+// it assumes that the path of the source below src/ matches its package.
+func robolectricTestClass(test string) string {
+	test = strings.TrimSuffix(strings.TrimSuffix(test, ".java"), ".kt")
+	return strings.ReplaceAll(test, "/", ".")
+}
+
+// robolectricFilterMatches returns true if a test class matches a test class or package filter.
+func robolectricFilterMatches(class, filter string) bool {
+	return class == filter || strings.HasPrefix(class, filter+".")
+}
+
+// filterRobolectricTests applies the include and exclude filters to the test source files for the
+// Make runner, which runs whole test classes.  An include filter for a method selects its class, and
+// an exclude filter for a method is ignored since the rest of its class still has to run.
+func filterRobolectricTests(tests, includeFilters, excludeFilters []string) []string {
+	var filtered []string
+	for _, test := range tests {
+		class := robolectricTestClass(test)
+		included := len(includeFilters) == 0
+		for _, f := range includeFilters {
+			if robolectricFilterMatches(class, strings.SplitN(f, "#", 2)[0]) {
+				included = true
+				break
+			}
+		}
+		for _, f := range excludeFilters {
+			if !strings.Contains(f, "#") && robolectricFilterMatches(class, f) {
+				included = false
+				break
+			}
+		}
+		if included {
+			filtered = append(filtered, test)
+		}
+	}
+	return filtered
+}
+
 func (r *robolectricTest) writeTestRunner(w io.Writer, module, name string, tests []string) {
 	fmt.Fprintln(w, "")
 	fmt.Fprintln(w, "include $(CLEAR_VARS)", " # java.robolectricTest")
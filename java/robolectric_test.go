@@ -0,0 +1,173 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+var prepareRobolectricRuntime = android.GroupFixturePreparers(
+	android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+		ctx.RegisterModuleType("android_robolectric_test", RobolectricTestFactory)
+		ctx.RegisterModuleType("android_robolectric_runtimes", robolectricRuntimesFactory)
+	}),
+	android.FixtureAddTextFile("robolectric/Android.bp", `
+		java_library {
+			name: "Robolectric_all-target",
+			srcs: ["Robo.java"],
+		}
+
+		java_library {
+			name: "mockito-robolectric-prebuilt",
+			srcs: ["Mockito.java"],
+		}
+
+		java_library {
+			name: "truth-prebuilt",
+			srcs: ["Truth.java"],
+		}
+
+		java_library {
+			name: "junitxml",
+			srcs: ["JUnitXml.java"],
+		}
+
+		android_robolectric_runtimes {
+			name: "robolectric-android-all-prebuilts",
+			jars: ["android-all/android-all-R-robolectric-r0.jar"],
+		}
+	`),
+	android.FixtureMergeMockFs(android.MockFS{
+		"robolectric/Robo.java":                                    nil,
+		"robolectric/Mockito.java":                                 nil,
+		"robolectric/Truth.java":                                   nil,
+		"robolectric/JUnitXml.java":                                nil,
+		"robolectric/android-all/android-all-R-robolectric-r0.jar": nil,
+		"src/com/android/foo/FooTest.java":                         nil,
+		"src/com/android/foo/BarTest.java":                         nil,
+		"src/com/android/foo/BazTest.java":                         nil,
+		"timings.txt":                                              nil,
+		"build/make/core/robolectric_test_config_template.xml":     nil,
+	}),
+)
+
+func TestRobolectricShards(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		prepareRobolectricRuntime,
+	).RunTestWithBp(t, `
+		android_app {
+			name: "app",
+			srcs: ["a.java"],
+			sdk_version: "current",
+		}
+
+		android_robolectric_test {
+			name: "robo",
+			srcs: [
+				"src/com/android/foo/FooTest.java",
+				"src/com/android/foo/BarTest.java",
+				"src/com/android/foo/BazTest.java",
+			],
+			instrumentation_for: "app",
+			test_options: {
+				shards: 2,
+				include_filters: ["com.android.foo.FooTest#testFoo", "com.android.foo.BarTest"],
+				exclude_filters: ["com.android.foo.BazTest"],
+				shard_timings: "timings.txt",
+			},
+		}
+	`)
+
+	robo := result.ModuleForTests("robo", "android_common")
+
+	config := robo.Output("robo.config")
+	android.AssertStringDoesContain(t, "include filter", config.Args["extraConfigs"],
+		`<option name="include-filter" value="com.android.foo.FooTest#testFoo" />`)
+	android.AssertStringDoesContain(t, "exclude filter", config.Args["extraConfigs"],
+		`<option name="exclude-filter" value="com.android.foo.BazTest" />`)
+
+	// The shard script names the test classes after the package declarations of the sources.
+	tests := android.ContentFromFileRuleForTests(t, robo.Output("robolectric_shards/tests.txt"))
+	android.AssertStringEquals(t, "test sources",
+		"src/com/android/foo/FooTest.java\nsrc/com/android/foo/BarTest.java\nsrc/com/android/foo/BazTest.java\n", tests)
+
+	shards := robo.Rule("robolectric_shards")
+	android.AssertStringListContains(t, "shard inputs", shards.Implicits.Strings(),
+		"src/com/android/foo/BazTest.java")
+	android.AssertStringDoesContain(t, "shard command", shards.RuleParams.Command,
+		"--config out/soong/.intermediates/robo/android_common/robo.config "+
+			"--tests out/soong/.intermediates/robo/android_common/robolectric_shards/tests.txt "+
+			"--timings timings.txt "+
+			"--include-filter 'com.android.foo.FooTest#testFoo' --include-filter com.android.foo.BarTest "+
+			"--output out/soong/.intermediates/robo/android_common/robolectric_shards/robo_shard0.config "+
+			"--output out/soong/.intermediates/robo/android_common/robolectric_shards/robo_shard1.config")
+
+	robo.Output("robo_shard0.config")
+	robo.Output("robo_shard1.config")
+
+	// The shard configs are installed in the test suite instead of the config of the whole module.
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, robo.Module())[0]
+	android.AssertStringPathsRelativeToTopEquals(t, "LOCAL_EXTRA_FULL_TEST_CONFIGS", result.Config,
+		[]string{
+			"out/soong/.intermediates/robo/android_common/robolectric_shards/robo_shard0.config",
+			"out/soong/.intermediates/robo/android_common/robolectric_shards/robo_shard1.config",
+		},
+		entries.EntryMap["LOCAL_EXTRA_FULL_TEST_CONFIGS"])
+	android.AssertDeepEquals(t, "LOCAL_FULL_TEST_CONFIG", []string(nil), entries.EntryMap["LOCAL_FULL_TEST_CONFIG"])
+
+	// The Make runners are sharded and only run the test classes that the filters select.
+	footer := &strings.Builder{}
+	for _, f := range entries.ExtraFooters {
+		f(footer, "robo", "", "")
+	}
+	android.AssertStringDoesContain(t, "shard 0 runner", footer.String(),
+		"LOCAL_MODULE := Runrobo0\n")
+	android.AssertStringDoesContain(t, "shard 0 tests", footer.String(),
+		"LOCAL_ROBOTEST_FILES := com/android/foo/FooTest.java\n")
+	android.AssertStringDoesContain(t, "shard 1 runner", footer.String(),
+		"LOCAL_MODULE := Runrobo1\n")
+	android.AssertStringDoesContain(t, "shard 1 tests", footer.String(),
+		"LOCAL_ROBOTEST_FILES := com/android/foo/BarTest.java\n")
+	android.AssertStringDoesContain(t, "aggregate runner", footer.String(),
+		".PHONY: Runrobo\nRunrobo : \\\n    Runrobo0 \\\n    Runrobo1 \\\n")
+	android.AssertStringDoesNotContain(t, "excluded test", footer.String(), "BazTest")
+}
+
+func TestFilterRobolectricTests(t *testing.T) {
+	tests := []string{
+		"com/android/foo/FooTest.java",
+		"com/android/foo/bar/BarTest.kt",
+		"com/android/baz/BazTest.java",
+	}
+
+	android.AssertDeepEquals(t, "no filters", tests, filterRobolectricTests(tests, nil, nil))
+	android.AssertDeepEquals(t, "package include filter",
+		[]string{"com/android/foo/FooTest.java", "com/android/foo/bar/BarTest.kt"},
+		filterRobolectricTests(tests, []string{"com.android.foo"}, nil))
+	android.AssertDeepEquals(t, "method include filter",
+		[]string{"com/android/baz/BazTest.java"},
+		filterRobolectricTests(tests, []string{"com.android.baz.BazTest#testBaz"}, nil))
+	android.AssertDeepEquals(t, "class exclude filter",
+		[]string{"com/android/foo/FooTest.java", "com/android/baz/BazTest.java"},
+		filterRobolectricTests(tests, nil, []string{"com.android.foo.bar.BarTest"}))
+	android.AssertDeepEquals(t, "method exclude filter", tests,
+		filterRobolectricTests(tests, nil, []string{"com.android.foo.FooTest#testFoo"}))
+	android.AssertDeepEquals(t, "prefix is not a package", tests,
+		filterRobolectricTests(tests, nil, []string{"com.android.fo"}))
+}
@@ -152,12 +152,8 @@ func (r *RuntimeResourceOverlay) GenerateAndroidBuildActions(ctx android.ModuleC
 	_, _, certificates := collectAppDeps(ctx, r, false, false)
 	r.certificate, certificates = processMainCert(r.ModuleBase, String(r.properties.Certificate), certificates, ctx)
 	signed := android.PathForModuleOut(ctx, "signed", r.Name()+".apk")
-	var lineageFile android.Path
-	if lineage := String(r.properties.Lineage); lineage != "" {
-		lineageFile = android.PathForModuleSrc(ctx, lineage)
-	}
-
-	rotationMinSdkVersion := String(r.properties.RotationMinSdkVersion)
+	lineageFile, rotationMinSdkVersion := signingLineage(ctx, r.properties.Lineage,
+		r.properties.RotationMinSdkVersion, r.certificate)
 
 	SignAppPackage(ctx, signed, r.aapt.exportPackage, certificates, nil, lineageFile, rotationMinSdkVersion)
 
@@ -613,6 +613,12 @@ type scopePaths struct {
 
 	// The path to the latest removed API file.
 	latestRemovedApiPath android.OptionalPath
+
+	// The JSON report of the api-lint findings.
+	apiLintJson android.OptionalPath
+
+	// The JSON report of the changes from the latest API.
+	apiChangesJson android.OptionalPath
 }
 
 func (paths *scopePaths) extractStubsLibraryInfoFromDependency(ctx android.ModuleContext, dep android.Module) error {
@@ -651,6 +657,11 @@ func (paths *scopePaths) extractApiInfoFromApiStubsProvider(provider ApiStubsPro
 	paths.annotationsZip = android.OptionalPathForPath(provider.AnnotationsZip())
 	paths.currentApiFilePath = android.OptionalPathForPath(provider.ApiFilePath())
 	paths.removedApiFilePath = android.OptionalPathForPath(provider.RemovedApiFilePath())
+
+	if reports, ok := provider.(apiReportProvider); ok {
+		paths.apiLintJson = android.OptionalPathForPath(reports.ApiLintJsonPath())
+		paths.apiChangesJson = android.OptionalPathForPath(reports.ApiChangesJsonPath())
+	}
 }
 
 func (paths *scopePaths) extractApiInfoFromDep(ctx android.ModuleContext, dep android.Module) error {
@@ -0,0 +1,106 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"fmt"
+
+	"android/soong/android"
+)
+
+// The API report aggregates the api-lint findings and the API changes from the last release of
+// every java_sdk_library into a single JSON report, so API reviewers get one list of what changed
+// in the build rather than the failure logs of each droidstubs module.
+
+func init() {
+	registerSdkLibraryApiReportBuildComponents(android.InitRegistrationContext)
+}
+
+func registerSdkLibraryApiReportBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterSingletonType("sdk_library_api_report", sdkLibraryApiReportSingletonFactory)
+}
+
+var PrepareForTestWithSdkLibraryApiReport = android.FixtureRegisterWithContext(registerSdkLibraryApiReportBuildComponents)
+
+// apiReportProvider is implemented by modules, i.e. droidstubs, that generate structured reports
+// of their API.
+type apiReportProvider interface {
+	// ApiLintJsonPath returns the JSON report of the api-lint findings, or nil if there is none.
+	ApiLintJsonPath() android.Path
+
+	// ApiChangesJsonPath returns the JSON report of the changes from the last released API, or nil
+	// if there is none.
+	ApiChangesJsonPath() android.Path
+}
+
+var _ apiReportProvider = (*Droidstubs)(nil)
+
+func sdkLibraryApiReportSingletonFactory() android.Singleton {
+	return &sdkLibraryApiReportSingleton{}
+}
+
+type sdkLibraryApiReportSingleton struct {
+	report android.Path
+}
+
+func (s *sdkLibraryApiReportSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	report := android.PathForOutput(ctx, "api_report", "api-changes.json")
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	cmd := rule.Command().
+		BuiltTool("api_report").
+		Text("merge")
+
+	ctx.VisitAllModules(func(module android.Module) {
+		library, ok := module.(*SdkLibrary)
+		if !ok || !isActiveModule(module) {
+			return
+		}
+		name := ctx.ModuleName(module)
+		for _, scope := range allApiScopes {
+			paths := library.findScopePaths(scope)
+			if paths == nil {
+				continue
+			}
+			if paths.apiLintJson.Valid() {
+				cmd.FlagWithInput("--lint "+apiReportLabel(name, scope), paths.apiLintJson.Path())
+			}
+			if paths.apiChangesJson.Valid() {
+				cmd.FlagWithInput("--changes "+apiReportLabel(name, scope), paths.apiChangesJson.Path())
+			}
+		}
+	})
+
+	cmd.FlagWithOutput("--output ", report)
+	rule.Build("sdk_library_api_report", "java_sdk_library API report")
+
+	s.report = report
+
+	ctx.Phony("api-report", report)
+}
+
+// apiReportLabel returns the prefix of the path of a report that tells the api_report tool which
+// library and API scope the report belongs to.
+func apiReportLabel(name string, scope *apiScope) string {
+	return fmt.Sprintf("%s:%s:", name, scope.name)
+}
+
+func (s *sdkLibraryApiReportSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if s.report != nil && !ctx.Config().UnbundledBuild() {
+		ctx.DistForGoal("api-report", s.report)
+	}
+}
+
+var _ android.SingletonMakeVarsProvider = (*sdkLibraryApiReportSingleton)(nil)
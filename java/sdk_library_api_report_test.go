@@ -0,0 +1,94 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestSdkLibraryApiReport(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		PrepareForTestWithJavaSdkLibraryFiles,
+		PrepareForTestWithSdkLibraryApiReport,
+		FixtureWithLastReleaseApis("foo"),
+	).RunTestWithBp(t, `
+		java_sdk_library {
+			name: "foo",
+			srcs: ["a.java"],
+			api_packages: ["foo"],
+			api_lint: {
+				enabled: true,
+			},
+			public: {
+				enabled: true,
+			},
+			system: {
+				enabled: true,
+			},
+		}
+	`)
+
+	stubs := result.ModuleForTests("foo.stubs.source", "android_common")
+
+	lint := stubs.Output("metalava/api_lint.json")
+	android.AssertStringDoesContain(t, "api lint report command", lint.RuleParams.Command,
+		"lint --module foo.stubs.source "+
+			"--report out/soong/.intermediates/foo.stubs.source/android_common/metalava/api_lint_report.txt")
+
+	changes := stubs.Output("metalava/api_changes.json")
+	android.AssertStringDoesContain(t, "api changes check command", changes.RuleParams.Command,
+		"--source-files out/soong/.intermediates/foo.stubs.source/android_common/metalava/foo.stubs.source_api.txt ")
+	android.AssertStringDoesContain(t, "api changes added issues", changes.RuleParams.Command,
+		"--lint AddedClass ")
+	android.AssertStringDoesContain(t, "api changes stale report", changes.RuleParams.Command,
+		"rm -f out/soong/.intermediates/foo.stubs.source/android_common/metalava/api_changes_report.txt && ")
+	android.AssertStringDoesContain(t, "api changes exit status", changes.RuleParams.Command,
+		"|| [ $$? -eq 255 -a -f out/soong/.intermediates/foo.stubs.source/android_common/metalava/api_changes_report.txt ]")
+	android.AssertStringDoesNotContain(t, "api changes exit status", changes.RuleParams.Command, "|| true")
+	android.AssertStringDoesContain(t, "api changes report command", changes.RuleParams.Command,
+		"changes --module foo.stubs.source "+
+			"--report out/soong/.intermediates/foo.stubs.source/android_common/metalava/api_changes_report.txt")
+
+	report := result.SingletonForTests("sdk_library_api_report").Output("api_report/api-changes.json")
+	android.AssertStringDoesContain(t, "public lint report", report.RuleParams.Command,
+		"--lint foo:public:out/soong/.intermediates/foo.stubs.source/android_common/metalava/api_lint.json")
+	android.AssertStringDoesContain(t, "system changes report", report.RuleParams.Command,
+		"--changes foo:system:out/soong/.intermediates/foo.stubs.source.system/android_common/metalava/api_changes.json")
+}
+
+func TestDroidstubsApiReportsNotGenerated(t *testing.T) {
+	ctx, _ := testJavaWithFS(t, `
+		droidstubs {
+			name: "foo-stubs",
+			srcs: ["foo-doc/a.java"],
+		}
+		`,
+		map[string][]byte{
+			"foo-doc/a.java": nil,
+		})
+
+	stubs := ctx.ModuleForTests("foo-stubs", "android_common").Module().(android.OutputFileProducer)
+
+	_, err := stubs.OutputFiles(".api_lint.json")
+	android.AssertErrorMessageEquals(t, "api lint report",
+		`".api_lint.json" is not generated because check_api.api_lint.enabled is not set`, err)
+
+	_, err = stubs.OutputFiles(".api_changes.json")
+	android.AssertErrorMessageEquals(t, "api changes report",
+		`".api_changes.json" is not generated because check_api.last_released is not set`, err)
+}
@@ -571,6 +571,12 @@ func (mod *Module) IsNativeCoverageNeeded(ctx android.BaseModuleContext) bool {
 	return mod.coverage != nil && mod.coverage.Properties.NeedCoverageVariant
 }
 
+var _ cc.CoverageInstrumented = (*Module)(nil)
+
+func (mod *Module) IsCoverageInstrumented() bool {
+	return mod.coverage != nil && mod.coverage.Properties.CoverageEnabled
+}
+
 func (mod *Module) VndkVersion() string {
 	return mod.Properties.VndkVersion
 }
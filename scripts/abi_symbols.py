@@ -0,0 +1,169 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Checks the exported symbols of a shared library against a checked-in list.
+
+Has two sub commands:
+  dump:  writes the .abi.txt file of a linked shared library. It lists one
+         exported symbol per line, sorted by name, as
+         "<name>[@version] <type>[ <size>]". The size is only listed for data
+         symbols, whose size is part of the ABI.
+  check: compares a dumped .abi.txt file with the checked-in one and reports
+         the symbols that were removed, added or changed. Any difference fails
+         the check, so that the checked-in file keeps listing every exported
+         symbol.
+
+This is a cheap guard for libraries that the header ABI checker does not cover:
+it only looks at the dynamic symbol table, not at the types behind the symbols.
+"""
+
+import argparse
+import os
+import subprocess
+import sys
+
+# The symbol types whose size is part of the ABI.
+DATA_TYPES = ('OBJECT', 'TLS')
+
+
+def parse_dyn_syms(output):
+  """Returns the exported symbols listed by llvm-readelf --dyn-syms --wide.
+
+  The result maps each symbol name, including its version if any, to a tuple of
+  its type and its size, which is None for code symbols.
+  """
+  symbols = {}
+  for line in output.splitlines():
+    # Num: Value Size Type Bind Vis Ndx Name
+    fields = line.split()
+    if len(fields) < 8 or not fields[0].endswith(':') or not fields[0][:-1].isdigit():
+      continue
+    size, kind, bind, vis, ndx, name = fields[2], fields[3], fields[4], fields[5], fields[6], fields[7]
+    if ndx == 'UND' or bind not in ('GLOBAL', 'WEAK') or vis not in ('DEFAULT', 'PROTECTED'):
+      continue
+    if kind in DATA_TYPES:
+      symbols[name] = (kind, int(size, 0))
+    else:
+      symbols[name] = (kind, None)
+  return symbols
+
+
+def format_symbols(symbols):
+  """Returns the contents of an .abi.txt file."""
+  lines = []
+  for name in sorted(symbols):
+    kind, size = symbols[name]
+    if size is None:
+      lines.append('%s %s\n' % (name, kind))
+    else:
+      lines.append('%s %s %d\n' % (name, kind, size))
+  return ''.join(lines)
+
+
+def parse_abi_txt(contents):
+  """Parses the contents of an .abi.txt file into the same map as parse_dyn_syms."""
+  symbols = {}
+  for line in contents.splitlines():
+    line = line.strip()
+    if not line or line.startswith('#'):
+      continue
+    fields = line.split()
+    if len(fields) == 2:
+      symbols[fields[0]] = (fields[1], None)
+    elif len(fields) == 3:
+      symbols[fields[0]] = (fields[1], int(fields[2]))
+    else:
+      raise ValueError('malformed line in .abi.txt file: %r' % line)
+  return symbols
+
+
+def describe(kind, size):
+  if size is None:
+    return kind
+  return '%s of size %d' % (kind, size)
+
+
+def compare(reference, current):
+  """Compares the current exported symbols with the reference ones.
+
+  Returns a tuple of the removed, added and changed symbols, as messages.
+  """
+  removed = ['removed: %s' % name for name in sorted(reference) if name not in current]
+  added = ['added: %s' % name for name in sorted(current) if name not in reference]
+  changed = []
+  for name in sorted(reference):
+    if name in current and reference[name] != current[name]:
+      changed.append('changed: %s from %s to %s' % (
+          name, describe(*reference[name]), describe(*current[name])))
+  return removed, added, changed
+
+
+def dump(args):
+  """Writes the .abi.txt file of a shared library."""
+  clang_bin = os.environ.get('CLANG_BIN', '')
+  output = subprocess.check_output(
+      [os.path.join(clang_bin, 'llvm-readelf'), '--dyn-syms', '--wide', args.input], text=True)
+  with open(args.output, 'w') as f:
+    f.write(format_symbols(parse_dyn_syms(output)))
+
+
+def check(args):
+  """Checks a dumped .abi.txt file against the checked-in one, returns the exit code."""
+  reference = {}
+  if args.reference:
+    with open(args.reference, 'r') as f:
+      reference = parse_abi_txt(f.read())
+  else:
+    print('%s: no checked-in .abi.txt file' % args.library, file=sys.stderr)
+  with open(args.current, 'r') as f:
+    current = parse_abi_txt(f.read())
+
+  removed, added, changed = compare(reference, current)
+  if not removed and not added and not changed:
+    return 0
+  for message in removed + changed + added:
+    print('%s: %s' % (args.library, message), file=sys.stderr)
+  return 1
+
+
+def parse_args(argv):
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser()
+  subparsers = parser.add_subparsers(dest='command', required=True)
+
+  dump_parser = subparsers.add_parser('dump', help='dump the exported symbols of a library.')
+  dump_parser.add_argument('--input', required=True, help='linked shared library.')
+  dump_parser.add_argument('--output', required=True, help='.abi.txt file to write.')
+
+  check_parser = subparsers.add_parser('check', help='compare with the checked-in symbols.')
+  check_parser.add_argument('--library', required=True, help='name of the library.')
+  check_parser.add_argument('--reference', help='checked-in .abi.txt file, if it exists.')
+  check_parser.add_argument('--current', required=True, help='.abi.txt file written by dump.')
+
+  return parser.parse_args(argv)
+
+
+def main(argv):
+  """Program entry point."""
+  args = parse_args(argv)
+  if args.command == 'dump':
+    dump(args)
+  elif args.command == 'check':
+    sys.exit(check(args))
+
+
+if __name__ == '__main__':
+  main(sys.argv[1:])
@@ -0,0 +1,94 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for abi_symbols.py."""
+
+import unittest
+
+import abi_symbols
+
+DYN_SYMS = """\
+
+Symbol table '.dynsym' contains 7 entries:
+   Num:    Value          Size Type    Bind   Vis       Ndx Name
+     0: 0000000000000000     0 NOTYPE  LOCAL  DEFAULT   UND
+     1: 0000000000000000     0 FUNC    GLOBAL DEFAULT   UND malloc@LIBC (2)
+     2: 0000000000001000    24 FUNC    GLOBAL DEFAULT    12 foo@@LIBFOO_1
+     3: 0000000000002000     8 OBJECT  GLOBAL DEFAULT    20 foo_table
+     4: 0000000000001100    12 FUNC    WEAK   DEFAULT    12 foo_weak
+     5: 0000000000001200    12 FUNC    GLOBAL HIDDEN     12 foo_hidden
+     6: 0000000000000000     4 TLS     GLOBAL DEFAULT    21 foo_tls
+"""
+
+ABI_TXT = """\
+foo@@LIBFOO_1 FUNC
+foo_table OBJECT 8
+foo_tls TLS 4
+foo_weak FUNC
+"""
+
+
+class DumpTest(unittest.TestCase):
+  """Unit tests for parsing and formatting the exported symbols."""
+
+  def test_parse_dyn_syms(self):
+    self.assertEqual(abi_symbols.parse_dyn_syms(DYN_SYMS), {
+        'foo@@LIBFOO_1': ('FUNC', None),
+        'foo_table': ('OBJECT', 8),
+        'foo_tls': ('TLS', 4),
+        'foo_weak': ('FUNC', None),
+    })
+
+  def test_format_round_trip(self):
+    symbols = abi_symbols.parse_dyn_syms(DYN_SYMS)
+    self.assertEqual(abi_symbols.format_symbols(symbols), ABI_TXT)
+    self.assertEqual(abi_symbols.parse_abi_txt(ABI_TXT), symbols)
+
+  def test_parse_abi_txt_comments(self):
+    self.assertEqual(abi_symbols.parse_abi_txt('# comment\n\nfoo FUNC\n'), {'foo': ('FUNC', None)})
+
+  def test_parse_abi_txt_malformed(self):
+    with self.assertRaises(ValueError):
+      abi_symbols.parse_abi_txt('foo\n')
+
+
+class CompareTest(unittest.TestCase):
+  """Unit tests for compare function."""
+
+  def test_unchanged(self):
+    symbols = abi_symbols.parse_abi_txt(ABI_TXT)
+    self.assertEqual(abi_symbols.compare(symbols, dict(symbols)), ([], [], []))
+
+  def test_removed_added_changed(self):
+    reference = abi_symbols.parse_abi_txt(ABI_TXT)
+    current = abi_symbols.parse_abi_txt(
+        ABI_TXT.replace('foo_weak FUNC\n', 'foo_new FUNC\n').replace('OBJECT 8', 'OBJECT 16'))
+    self.assertEqual(abi_symbols.compare(reference, current), (
+        ['removed: foo_weak'],
+        ['added: foo_new'],
+        ['changed: foo_table from OBJECT of size 8 to OBJECT of size 16'],
+    ))
+
+  def test_version_change_is_removal(self):
+    reference = abi_symbols.parse_abi_txt('foo@@LIBFOO_1 FUNC\n')
+    current = abi_symbols.parse_abi_txt('foo@@LIBFOO_2 FUNC\n')
+    removed, added, _ = abi_symbols.compare(reference, current)
+    self.assertEqual(removed, ['removed: foo@@LIBFOO_1'])
+    self.assertEqual(added, ['added: foo@@LIBFOO_2'])
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
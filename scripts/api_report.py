@@ -0,0 +1,203 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Generates structured (JSON) reports of API lint findings and API changes.
+
+Both reports are converted from the issues that metalava reports, with the
+severities that metalava gave them. Has three sub commands:
+  lint:    converts the report written by metalava api-lint to JSON.
+  changes: converts the report written by the metalava compatibility check
+           against the last released API to a list of added, removed and
+           changed APIs.
+  merge:   merges the reports of many modules into a single report.
+"""
+
+import argparse
+import collections
+import json
+import re
+import sys
+
+# A line of a metalava report, e.g.
+# frameworks/base/Foo.java:12: error: Missing nullability on method `foo` [MissingNullability]
+ISSUE_LINE_RE = re.compile(
+    r'^(?:(?P<file>[^:\s]+?)(?::(?P<line>\d+))?: )?'
+    r'(?P<severity>error|warning|lint|hidden|info): '
+    r'(?P<message>.*?)(?: \[(?P<id>\w+)\])?$')
+
+
+# The metalava compatibility issues reported for added and removed APIs. Other
+# compatibility issues, e.g. AddedFinal or RemovedFinal, are changes to an API.
+ADDED_API_ISSUES = frozenset([
+    'AddedPackage', 'AddedClass', 'AddedInterface', 'AddedMethod', 'AddedField',
+])
+REMOVED_API_ISSUES = frozenset([
+    'RemovedPackage', 'RemovedClass', 'RemovedInterface', 'RemovedMethod', 'RemovedField',
+    'RemovedDeprecatedClass', 'RemovedDeprecatedMethod', 'RemovedDeprecatedField',
+])
+
+
+def parse_metalava_report(lines):
+  """Returns the issues from the lines of a metalava report."""
+  issues = []
+  for line in lines:
+    line = line.rstrip('\n')
+    match = ISSUE_LINE_RE.match(line)
+    if not match:
+      # Continuation of a multi-line message.
+      if issues and line.strip():
+        issues[-1]['message'] += '\n' + line
+      continue
+    issue = {
+        'severity': match.group('severity'),
+        'message': match.group('message'),
+    }
+    if match.group('id'):
+      issue['id'] = match.group('id')
+    if match.group('file'):
+      issue['file'] = match.group('file')
+    if match.group('line'):
+      issue['line'] = int(match.group('line'))
+    issues.append(issue)
+  return issues
+
+
+def change_kind(issue):
+  """Returns whether a compatibility issue is an added, removed or changed API."""
+  issue_id = issue.get('id')
+  if issue_id in ADDED_API_ISSUES:
+    return 'added'
+  if issue_id in REMOVED_API_ISSUES:
+    return 'removed'
+  return 'changed'
+
+
+def compatibility_changes(issues):
+  """Returns the API changes from the issues of a metalava compatibility check."""
+  changes = []
+  for issue in issues:
+    change = dict(issue)
+    change['kind'] = change_kind(issue)
+    changes.append(change)
+  return changes
+
+
+def merge_reports(lint_reports, change_reports):
+  """Merges the reports of many modules.
+
+  Both arguments are lists of (library, scope, report) tuples. Returns a report
+  with an entry per library and scope, and a summary of the counts of findings
+  by severity and of changes by kind.
+  """
+  entries = {}
+  for field, reports in (('lint', lint_reports), ('changes', change_reports)):
+    for library, scope, report in reports:
+      entry = entries.setdefault((library, scope), {
+          'library': library,
+          'scope': scope,
+          'lint': [],
+          'changes': [],
+      })
+      entry[field] = report[field]
+
+  lint_counts = collections.Counter()
+  change_counts = collections.Counter()
+  for entry in entries.values():
+    lint_counts.update(f['severity'] for f in entry['lint'])
+    change_counts.update(c['kind'] for c in entry['changes'])
+
+  return {
+      'libraries': [entries[k] for k in sorted(entries)],
+      'summary': {
+          'lint': dict(lint_counts),
+          'changes': dict(change_counts),
+      },
+  }
+
+
+def read_lines(path):
+  with open(path, 'r') as f:
+    return f.readlines()
+
+
+def read_json(path):
+  with open(path, 'r') as f:
+    return json.load(f)
+
+
+def write_json(path, report):
+  with open(path, 'w') as f:
+    json.dump(report, f, indent=2, sort_keys=True)
+    f.write('\n')
+
+
+def parse_labelled_input(value):
+  """Parses a <library>:<scope>:<path> argument."""
+  fields = value.split(':', 2)
+  if len(fields) != 3:
+    raise argparse.ArgumentTypeError(
+        'expected <library>:<scope>:<path>, got %r' % value)
+  return tuple(fields)
+
+
+def parse_args(argv):
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser()
+  subparsers = parser.add_subparsers(dest='command', required=True)
+
+  lint = subparsers.add_parser('lint', help='convert a metalava api-lint report.')
+  lint.add_argument('--module', required=True, help='name of the module.')
+  lint.add_argument('--report', required=True, help='metalava api-lint report.')
+  lint.add_argument('--output', required=True, help='JSON report to write.')
+
+  changes = subparsers.add_parser('changes', help='convert a metalava compatibility check report.')
+  changes.add_argument('--module', required=True, help='name of the module.')
+  changes.add_argument('--report', required=True,
+                       help='metalava report of the compatibility check against the released API.')
+  changes.add_argument('--output', required=True, help='JSON report to write.')
+
+  merge = subparsers.add_parser('merge', help='merge the reports of many modules.')
+  merge.add_argument('--lint', action='append', default=[], type=parse_labelled_input,
+                     help='<library>:<scope>:<path> of a lint report.')
+  merge.add_argument('--changes', action='append', default=[], type=parse_labelled_input,
+                     help='<library>:<scope>:<path> of an API changes report.')
+  merge.add_argument('--output', required=True, help='JSON report to write.')
+
+  return parser.parse_args(argv)
+
+
+def main(argv):
+  """Program entry point."""
+  args = parse_args(argv)
+
+  if args.command == 'lint':
+    write_json(args.output, {
+        'module': args.module,
+        'lint': parse_metalava_report(read_lines(args.report)),
+    })
+  elif args.command == 'changes':
+    write_json(args.output, {
+        'module': args.module,
+        'changes': compatibility_changes(parse_metalava_report(read_lines(args.report))),
+    })
+  elif args.command == 'merge':
+    write_json(args.output, merge_reports(
+        [(library, scope, read_json(path)) for library, scope, path in args.lint],
+        [(library, scope, read_json(path)) for library, scope, path in args.changes]))
+
+
+if __name__ == '__main__':
+  main(sys.argv[1:])
@@ -0,0 +1,114 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for api_report.py."""
+
+import unittest
+
+import api_report
+
+class ParseMetalavaReportTest(unittest.TestCase):
+  """Unit tests for parse_metalava_report function."""
+
+  def test_parse(self):
+    issues = api_report.parse_metalava_report([
+        'frameworks/base/Foo.java:12: error: Missing nullability on method `bar` [MissingNullability]\n',
+        'frameworks/base/Foo.java: warning: Use a Builder\n',
+        '    for classes with many parameters [BuilderSetStyle]\n',
+        'hidden: Something [HiddenId]\n',
+    ])
+    self.assertEqual(issues, [
+        {
+            'file': 'frameworks/base/Foo.java',
+            'line': 12,
+            'severity': 'error',
+            'message': 'Missing nullability on method `bar`',
+            'id': 'MissingNullability',
+        },
+        {
+            'file': 'frameworks/base/Foo.java',
+            'severity': 'warning',
+            'message': 'Use a Builder\n    for classes with many parameters [BuilderSetStyle]',
+        },
+        {
+            'severity': 'hidden',
+            'message': 'Something',
+            'id': 'HiddenId',
+        },
+    ])
+
+
+class CompatibilityChangesTest(unittest.TestCase):
+  """Unit tests for compatibility_changes function."""
+
+  def test_changes(self):
+    changes = api_report.compatibility_changes(api_report.parse_metalava_report([
+        'api/current.txt:5: lint: Added method android.foo.Foo.quux(java.util.List<java.lang.String>) '
+        '[AddedMethod]\n',
+        'api/released.txt:7: error: Removed method android.foo.Foo.bar(String) [RemovedMethod]\n',
+        'api/current.txt:6: error: Method android.foo.Foo.baz has changed return type from int to long '
+        '[ChangedType]\n',
+        'api/current.txt:9: warning: Method android.foo.Foo.qux has added \'final\' qualifier '
+        '[AddedFinal]\n',
+    ]))
+    self.assertEqual([(c['kind'], c['severity'], c['id']) for c in changes], [
+        ('added', 'lint', 'AddedMethod'),
+        ('removed', 'error', 'RemovedMethod'),
+        ('changed', 'error', 'ChangedType'),
+        ('changed', 'warning', 'AddedFinal'),
+    ])
+    self.assertEqual(changes[1]['message'], 'Removed method android.foo.Foo.bar(String)')
+    self.assertEqual(changes[1]['file'], 'api/released.txt')
+
+  def test_no_changes(self):
+    self.assertEqual(api_report.compatibility_changes(api_report.parse_metalava_report([])), [])
+
+
+class MergeReportsTest(unittest.TestCase):
+  """Unit tests for merge_reports function."""
+
+  def test_merge(self):
+    report = api_report.merge_reports(
+        [('foo', 'public', {'module': 'foo.stubs.source', 'lint': [{'severity': 'error'}]})],
+        [
+            ('foo', 'public', {'module': 'foo.stubs.source', 'changes': [{'kind': 'added'}]}),
+            ('bar', 'system', {'module': 'bar.stubs.source.system', 'changes': [
+                {'kind': 'added'}, {'kind': 'removed'}]}),
+        ])
+    self.assertEqual(report, {
+        'libraries': [
+            {
+                'library': 'bar',
+                'scope': 'system',
+                'lint': [],
+                'changes': [{'kind': 'added'}, {'kind': 'removed'}],
+            },
+            {
+                'library': 'foo',
+                'scope': 'public',
+                'lint': [{'severity': 'error'}],
+                'changes': [{'kind': 'added'}],
+            },
+        ],
+        'summary': {
+            'lint': {'error': 1},
+            'changes': {'added': 2, 'removed': 1},
+        },
+    })
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
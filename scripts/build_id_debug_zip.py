@@ -0,0 +1,80 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Zips a separate debug info file at its path in a build-id tree.
+
+The debug info file of an ELF file with build ID 0123abcd... is stored as
+.build-id/01/23abcd....debug, which is the layout debuggers look up in their
+debug file directories, and that debuginfod-compatible servers serve. The zips
+of all the modules are merged into the symbols artifact. A file without a build
+ID results in an empty zip.
+"""
+
+import argparse
+import os
+import re
+import subprocess
+import sys
+import zipfile
+
+BUILD_ID_RE = re.compile(r'^\s*Build ID:\s*([0-9a-fA-F]+)\s*$', re.MULTILINE)
+
+# A fixed timestamp, so that the zips only change when the debug info does.
+ZIP_DATE_TIME = (2008, 1, 1, 0, 0, 0)
+
+
+def parse_build_id(output):
+  """Returns the build ID listed by llvm-readelf --notes, or None."""
+  match = BUILD_ID_RE.search(output)
+  if not match:
+    return None
+  return match.group(1).lower()
+
+
+def build_id_path(build_id):
+  """Returns the path of the debug info file of a build ID in the build-id tree."""
+  return '.build-id/%s/%s.debug' % (build_id[:2], build_id[2:])
+
+
+def write_zip(output, debug_file, build_id):
+  """Writes the zip of a debug info file, stored as is as its sections are already compressed."""
+  with zipfile.ZipFile(output, 'w', zipfile.ZIP_STORED) as z:
+    if build_id:
+      info = zipfile.ZipInfo(build_id_path(build_id), ZIP_DATE_TIME)
+      info.external_attr = 0o644 << 16
+      with open(debug_file, 'rb') as f:
+        z.writestr(info, f.read())
+
+
+def parse_args(argv):
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser()
+  parser.add_argument('--input', required=True, help='separate debug info file.')
+  parser.add_argument('--output', required=True, help='zip to write.')
+  return parser.parse_args(argv)
+
+
+def main(argv):
+  """Program entry point."""
+  args = parse_args(argv)
+  clang_bin = os.environ.get('CLANG_BIN', '')
+  output = subprocess.check_output(
+      [os.path.join(clang_bin, 'llvm-readelf'), '--notes', args.input], text=True)
+  write_zip(args.output, args.input, parse_build_id(output))
+
+
+if __name__ == '__main__':
+  main(sys.argv[1:])
@@ -0,0 +1,69 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for build_id_debug_zip.py."""
+
+import os
+import tempfile
+import unittest
+import zipfile
+
+import build_id_debug_zip
+
+NOTES = """\
+Displaying notes found in: .note.android.ident
+  Owner                Data size 	Description
+  Android              0x00000084	NT_VERSION (version)
+
+Displaying notes found in: .note.gnu.build-id
+  Owner                Data size 	Description
+  GNU                  0x00000010	NT_GNU_BUILD_ID (unique build ID bitstring)
+    Build ID: 0123456789ABCDEF0123456789abcdef
+"""
+
+
+class BuildIdDebugZipTest(unittest.TestCase):
+  """Unit tests for build_id_debug_zip."""
+
+  def test_parse_build_id(self):
+    self.assertEqual(build_id_debug_zip.parse_build_id(NOTES), '0123456789abcdef0123456789abcdef')
+
+  def test_parse_no_build_id(self):
+    self.assertIsNone(build_id_debug_zip.parse_build_id('Displaying notes found in: .note\n'))
+
+  def test_build_id_path(self):
+    self.assertEqual(build_id_debug_zip.build_id_path('0123456789abcdef'),
+                     '.build-id/01/23456789abcdef.debug')
+
+  def test_write_zip(self):
+    with tempfile.TemporaryDirectory() as tmp:
+      debug_file = os.path.join(tmp, 'libfoo.so.debug')
+      with open(debug_file, 'wb') as f:
+        f.write(b'debug info')
+      output = os.path.join(tmp, 'out.zip')
+
+      build_id_debug_zip.write_zip(output, debug_file, '0123')
+      with zipfile.ZipFile(output) as z:
+        self.assertEqual(z.namelist(), ['.build-id/01/23.debug'])
+        self.assertEqual(z.read('.build-id/01/23.debug'), b'debug info')
+
+      build_id_debug_zip.write_zip(output, debug_file, None)
+      with zipfile.ZipFile(output) as z:
+        self.assertEqual(z.namelist(), [])
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
@@ -0,0 +1,220 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""A tool for checking the size of an APEX against its size budget.
+
+Writes a JSON report of every file in the APEX image directory, attributed to
+the module that provided it, and fails with a breakdown by module and by file
+type when the APEX is larger than its budget. The breakdown is diffed against a
+reference report, usually the report of a previous build that was within the
+budget.
+"""
+
+from __future__ import print_function
+
+import argparse
+import collections
+import json
+import os
+import sys
+
+UNATTRIBUTED_MODULE = '<unattributed>'
+UNATTRIBUTED_TYPE = 'OTHER'
+
+
+def parse_args(argv):
+    """Parse commandline arguments."""
+
+    parser = argparse.ArgumentParser()
+    parser.add_argument(
+        '--name', dest='name', required=True, help='name of the APEX')
+    parser.add_argument(
+        '--image-dir',
+        dest='image_dir',
+        required=True,
+        help='staging directory the APEX payload was built from')
+    parser.add_argument(
+        '--file-modules',
+        dest='file_modules',
+        required=True,
+        help='file with a "<path> <module> <type>" line for every file the '
+        'build system put in the APEX')
+    parser.add_argument(
+        '--apex', dest='apex', required=True, help='the signed APEX file')
+    parser.add_argument(
+        '--max-size',
+        dest='max_size',
+        type=int,
+        help='maximum size of the uncompressed APEX in bytes')
+    parser.add_argument(
+        '--capex', dest='capex', help='the signed compressed APEX file')
+    parser.add_argument(
+        '--max-compressed-size',
+        dest='max_compressed_size',
+        type=int,
+        help='maximum size of the compressed APEX in bytes')
+    parser.add_argument(
+        '--report',
+        dest='report',
+        required=True,
+        help='output JSON report')
+    parser.add_argument(
+        '--reference',
+        dest='reference',
+        help='report of a previous build within the budget, which the '
+        'breakdown is diffed against')
+    parser.add_argument(
+        '--output',
+        dest='output',
+        required=True,
+        help='timestamp file written when the APEX is within its budget')
+    return parser.parse_args(argv)
+
+
+def read_file_modules(path):
+    """Reads the file written by the build system mapping files to modules."""
+    file_modules = {}
+    with open(path, 'r') as f:
+        for line in f:
+            fields = line.split()
+            if len(fields) != 3:
+                continue
+            file_modules[os.path.normpath(fields[0])] = (fields[1], fields[2])
+    return file_modules
+
+
+def collect_files(image_dir, file_modules):
+    """Lists the files and symlinks in the image directory with their sizes."""
+    files = []
+    for root, dirs, names in os.walk(image_dir):
+        dirs.sort()
+        for name in sorted(names):
+            full_path = os.path.join(root, name)
+            path = os.path.normpath(os.path.relpath(full_path, image_dir))
+            module, file_type = file_modules.get(
+                path, (UNATTRIBUTED_MODULE, UNATTRIBUTED_TYPE))
+            files.append({
+                'path': path,
+                'size': os.lstat(full_path).st_size,
+                'module': module,
+                'type': file_type,
+            })
+    return files
+
+
+def build_report(args):
+    """Builds the report for the current build."""
+    file_modules = read_file_modules(args.file_modules)
+    report = {
+        'name': args.name,
+        'uncompressed_size': os.path.getsize(args.apex),
+        'compressed_size': None,
+        'files': collect_files(args.image_dir, file_modules),
+    }
+    if args.capex:
+        report['compressed_size'] = os.path.getsize(args.capex)
+    return report
+
+
+def load_report(path):
+    """Loads a report."""
+    with open(path, 'r') as f:
+        return json.load(f)
+
+
+def sizes_by(report, key):
+    """Sums the sizes of the files in a report by the given field."""
+    sizes = collections.defaultdict(int)
+    if report:
+        for f in report['files']:
+            sizes[f[key]] += f['size']
+    return sizes
+
+
+def format_breakdown(title, current, reference):
+    """Formats a size breakdown, largest first, with deltas against the
+    reference."""
+    lines = [title + ':']
+    for name in sorted(
+            set(current) | set(reference),
+            key=lambda n: (-current.get(n, 0), n)):
+        size = current.get(name, 0)
+        delta = size - reference.get(name, 0)
+        lines.append('  %12d  %+12d  %s' % (size, delta, name))
+    return lines
+
+
+def check_budget(report, reference, max_size, max_compressed_size):
+    """Returns a list of error lines if the APEX is over budget."""
+    errors = []
+    if max_size is not None and report['uncompressed_size'] > max_size:
+        errors.append(
+            '%s is %d bytes, which exceeds its size_budget.uncompressed of %d '
+            'bytes by %d bytes.' %
+            (report['name'], report['uncompressed_size'], max_size,
+             report['uncompressed_size'] - max_size))
+    if (max_compressed_size is not None and
+            report['compressed_size'] is not None and
+            report['compressed_size'] > max_compressed_size):
+        errors.append(
+            '%s is %d bytes compressed, which exceeds its '
+            'size_budget.compressed of %d bytes by %d bytes.' %
+            (report['name'], report['compressed_size'], max_compressed_size,
+             report['compressed_size'] - max_compressed_size))
+    if not errors:
+        return errors
+
+    if reference:
+        errors.append('Deltas are relative to the size_budget.reference '
+                      'report.')
+    else:
+        errors.append('There is no size_budget.reference report; deltas are '
+                      'relative to an empty APEX.')
+    errors.extend(
+        format_breakdown('Size by module', sizes_by(report, 'module'),
+                         sizes_by(reference, 'module')))
+    errors.extend(
+        format_breakdown('Size by file type', sizes_by(report, 'type'),
+                         sizes_by(reference, 'type')))
+    return errors
+
+
+def write_report(report, path):
+    """Writes a report as JSON."""
+    with open(path, 'w') as f:
+        json.dump(report, f, indent=2, sort_keys=True)
+
+
+def main(argv):
+    """Program entry point."""
+    args = parse_args(argv)
+
+    report = build_report(args)
+    write_report(report, args.report)
+
+    reference = load_report(args.reference) if args.reference else None
+    errors = check_budget(report, reference, args.max_size,
+                          args.max_compressed_size)
+    if errors:
+        print('error: ' + '\n'.join(errors), file=sys.stderr)
+        sys.exit(1)
+
+    with open(args.output, 'w') as f:
+        f.write('')
+
+
+if __name__ == '__main__':
+    main(sys.argv[1:])
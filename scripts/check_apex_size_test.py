@@ -0,0 +1,189 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_apex_size.py."""
+
+import os
+import shutil
+import sys
+import tempfile
+import unittest
+
+import check_apex_size
+
+sys.dont_write_bytecode = True
+
+
+def report(uncompressed_size, compressed_size, files):
+    return {
+        'name': 'com.android.foo',
+        'uncompressed_size': uncompressed_size,
+        'compressed_size': compressed_size,
+        'files': [{
+            'path': path,
+            'size': size,
+            'module': module,
+            'type': file_type
+        } for path, size, module, file_type in files],
+    }
+
+
+class CollectFilesTest(unittest.TestCase):
+
+    def setUp(self):
+        self.image_dir = tempfile.mkdtemp()
+
+    def tearDown(self):
+        shutil.rmtree(self.image_dir)
+
+    def write(self, path, size):
+        full_path = os.path.join(self.image_dir, path)
+        if not os.path.isdir(os.path.dirname(full_path)):
+            os.makedirs(os.path.dirname(full_path))
+        with open(full_path, 'wb') as f:
+            f.write(b'x' * size)
+
+    def test_attribution(self):
+        self.write('lib64/libfoo.so', 100)
+        self.write('apex_manifest.pb', 10)
+        files = check_apex_size.collect_files(
+            self.image_dir,
+            {'lib64/libfoo.so': ('libfoo', 'SHARED_LIBRARIES')})
+        self.assertEqual(files, [
+            {
+                'path': 'apex_manifest.pb',
+                'size': 10,
+                'module': check_apex_size.UNATTRIBUTED_MODULE,
+                'type': check_apex_size.UNATTRIBUTED_TYPE,
+            },
+            {
+                'path': 'lib64/libfoo.so',
+                'size': 100,
+                'module': 'libfoo',
+                'type': 'SHARED_LIBRARIES',
+            },
+        ])
+
+
+class CheckBudgetTest(unittest.TestCase):
+
+    def test_within_budget(self):
+        current = report(1000, 500, [('lib64/libfoo.so', 900, 'libfoo',
+                                      'SHARED_LIBRARIES')])
+        self.assertEqual(
+            check_apex_size.check_budget(current, None, 1000, 500), [])
+
+    def test_compressed_budget_ignored_without_capex(self):
+        current = report(1000, None, [])
+        self.assertEqual(check_apex_size.check_budget(current, None, None, 1),
+                         [])
+
+    def test_over_budget(self):
+        reference = report(1000, None, [
+            ('lib64/libfoo.so', 800, 'libfoo', 'SHARED_LIBRARIES'),
+            ('bin/foo', 100, 'foo', 'EXECUTABLES'),
+        ])
+        current = report(1500, None, [
+            ('lib64/libfoo.so', 1200, 'libfoo', 'SHARED_LIBRARIES'),
+            ('javalib/bar.jar', 200, 'bar', 'JAVA_LIBRARIES'),
+        ])
+        errors = check_apex_size.check_budget(current, reference, 1200, None)
+        self.assertEqual(errors, [
+            'com.android.foo is 1500 bytes, which exceeds its '
+            'size_budget.uncompressed of 1200 bytes by 300 bytes.',
+            'Deltas are relative to the size_budget.reference report.',
+            'Size by module:',
+            '          1200          +400  libfoo',
+            '           200          +200  bar',
+            '             0          -100  foo',
+            'Size by file type:',
+            '          1200          +400  SHARED_LIBRARIES',
+            '           200          +200  JAVA_LIBRARIES',
+            '             0          -100  EXECUTABLES',
+        ])
+
+    def test_over_compressed_budget(self):
+        current = report(1000, 600, [])
+        errors = check_apex_size.check_budget(current, None, None, 500)
+        self.assertEqual(errors[0],
+                         'com.android.foo is 600 bytes compressed, which '
+                         'exceeds its size_budget.compressed of 500 bytes by '
+                         '100 bytes.')
+
+
+class MainTest(unittest.TestCase):
+
+    def setUp(self):
+        self.tmp = tempfile.mkdtemp()
+        self.image_dir = os.path.join(self.tmp, 'image')
+        os.makedirs(os.path.join(self.image_dir, 'lib64'))
+        self.file_modules = self.path('file_modules.txt')
+        with open(self.file_modules, 'w') as f:
+            f.write('lib64/libfoo.so libfoo SHARED_LIBRARIES\n')
+        self.apex = self.path('foo.apex')
+
+    def tearDown(self):
+        shutil.rmtree(self.tmp)
+
+    def path(self, name):
+        return os.path.join(self.tmp, name)
+
+    def build(self, libfoo_size, apex_size, reference=None):
+        with open(os.path.join(self.image_dir, 'lib64/libfoo.so'), 'wb') as f:
+            f.write(b'x' * libfoo_size)
+        with open(self.apex, 'wb') as f:
+            f.write(b'x' * apex_size)
+        argv = [
+            '--name', 'com.android.foo',
+            '--image-dir', self.image_dir,
+            '--file-modules', self.file_modules,
+            '--apex', self.apex,
+            '--max-size', '1000',
+            '--report', self.path('report.json'),
+            '--output', self.path('timestamp'),
+        ]
+        if reference:
+            argv.extend(['--reference', reference])
+        try:
+            check_apex_size.main(argv)
+        except SystemExit as e:
+            return e.code
+        return 0
+
+    def test_within_budget(self):
+        self.assertEqual(self.build(100, 900), 0)
+        self.assertTrue(os.path.exists(self.path('timestamp')))
+        self.assertEqual(
+            check_apex_size.load_report(self.path('report.json'))['files']
+            [0]['size'], 100)
+
+    def test_over_budget_with_reference(self):
+        self.assertEqual(self.build(100, 900), 0)
+        shutil.copy(self.path('report.json'), self.path('reference.json'))
+        os.remove(self.path('timestamp'))
+
+        self.assertEqual(self.build(300, 1100, self.path('reference.json')), 1)
+        self.assertFalse(os.path.exists(self.path('timestamp')))
+
+        # The reference report is only read.
+        reference = check_apex_size.load_report(self.path('reference.json'))
+        self.assertEqual(reference['files'][0]['size'], 100)
+        report = check_apex_size.load_report(self.path('report.json'))
+        errors = check_apex_size.check_budget(report, reference, 1000, None)
+        self.assertIn('           300          +200  libfoo', errors)
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
@@ -0,0 +1,185 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Checks that a C/C++ module only includes headers of its direct dependencies.
+
+Reads the include tree of each source of the module, i.e. the name of the
+source followed by the output of clang -H, and the include directories of the
+module, from a file with one tab separated line per directory:
+  own          <dir>
+  allowed      <dir>
+  transitive   <dir> <owner> <via>
+  include_dirs <dir>
+where:
+  own:          the directory belongs to the module.
+  allowed:      the directory is exported by one of the direct dependencies.
+  transitive:   the directory is exported by owner, which is not a direct
+                dependency, and is only on the include path because the direct
+                dependency via re-exports it.
+  include_dirs: the directory is on the include path because of the
+                include_dirs property of the module.
+
+Each header is attributed to the longest directory containing it. Headers that
+are not below any of the directories, e.g. those of the toolchain, are ignored.
+Only the headers included by the source or by headers of the module itself are
+checked. A header that a dependency includes from its own headers, e.g. one
+that it re-exports from another library, is not a violation of the module.
+"""
+
+import argparse
+import re
+import sys
+
+CWD_PREFIX = '/proc/self/cwd/'
+
+# The kinds of directories whose headers belong to the module itself.
+OWN_KINDS = ('own',)
+# The order in which the kinds win when a directory is reachable in more than one way.
+KIND_PRIORITY = {'own': 0, 'allowed': 1, 'transitive': 2, 'include_dirs': 3}
+
+# A line printed by clang -H, with one dot per level of nesting.
+INCLUDE_RE = re.compile(r'^(\.+) (.+)$')
+
+
+def parse_args():
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser()
+  parser.add_argument('--module', required=True, help='name of the module.')
+  parser.add_argument('--dirs', required=True, help='file listing the include directories.')
+  parser.add_argument('--output', required=True, help='report to write.')
+  parser.add_argument('--enforce', action='store_true',
+                      help='fail if the module includes headers of indirect dependencies.')
+  parser.add_argument('deps', nargs='*', help='include trees written by clang -H.')
+  return parser.parse_args()
+
+
+def normalize(path):
+  """Returns a path relative to the root of the source tree."""
+  if path.startswith(CWD_PREFIX):
+    path = path[len(CWD_PREFIX):]
+  while path.startswith('./'):
+    path = path[2:]
+  return path.rstrip('/')
+
+
+def read_dirs(lines):
+  """Returns a map from directory to its (kind, owner, via)."""
+  dirs = {}
+  for line in lines:
+    fields = line.rstrip('\n').split('\t')
+    if not fields[0]:
+      continue
+    kind, path = fields[0], normalize(fields[1])
+    owner = fields[2] if len(fields) > 2 else ''
+    via = fields[3] if len(fields) > 3 else ''
+    # A directory may be reachable in more than one way, the most permissive one wins.
+    if path not in dirs or KIND_PRIORITY[kind] < KIND_PRIORITY[dirs[path][0]]:
+      dirs[path] = (kind, owner, via)
+  return dirs
+
+
+def parse_includes(content):
+  """Returns the source and the (includer, header) pairs listed by an include tree."""
+  lines = content.splitlines()
+  if not lines or not lines[0].strip():
+    return None, []
+  source = normalize(lines[0].strip())
+  # The files that are being included at each level of nesting, starting with the source.
+  stack = [source]
+  includes = []
+  for line in lines[1:]:
+    match = INCLUDE_RE.match(line)
+    if not match:
+      continue
+    depth, header = len(match.group(1)), normalize(match.group(2).strip())
+    if depth > len(stack):
+      # Malformed output; attribute the header to the innermost file.
+      depth = len(stack)
+    del stack[depth:]
+    includes.append((stack[-1], header))
+    stack.append(header)
+  return source, includes
+
+
+def containing_dir(path, dirs):
+  """Returns the longest directory of dirs that contains path, or None."""
+  best = None
+  for d in dirs:
+    if path.startswith(d + '/') and (best is None or len(d) > len(best)):
+      best = d
+  return best
+
+
+def belongs_to_dependency(path, dirs):
+  """Returns true if path is a header of one of the dependencies of the module."""
+  d = containing_dir(path, dirs)
+  return d is not None and dirs[d][0] not in OWN_KINDS
+
+
+def find_violations(source, includes, dirs):
+  """Returns the violations of the includes of a source file."""
+  violations = []
+  for includer, header in includes:
+    if includer != source and belongs_to_dependency(includer, dirs):
+      continue
+    d = containing_dir(header, dirs)
+    if d is None:
+      continue
+    kind, owner, via = dirs[d]
+    if kind == 'transitive':
+      violations.append('%s: includes %s of %s, which is not a direct dependency but is '
+                        're-exported by %s' % (includer, header, owner, via))
+    elif kind == 'include_dirs':
+      violations.append('%s: includes %s through include_dirs %s instead of a dependency' %
+                        (includer, header, d))
+  return violations
+
+
+def check(module, dirs, deps_contents):
+  """Returns the text of the report and the number of violations."""
+  violations = []
+  for content in deps_contents:
+    source, includes = parse_includes(content)
+    if source:
+      violations.extend(find_violations(source, includes, dirs))
+  violations = sorted(set(violations))
+  lines = ['Include layering check of %s' % module,
+           '%d violations' % len(violations)]
+  lines.extend(violations)
+  return '\n'.join(lines) + '\n', len(violations)
+
+
+def main():
+  """Program entry point."""
+  args = parse_args()
+  with open(args.dirs, 'r') as f:
+    dirs = read_dirs(f)
+  deps_contents = []
+  for path in args.deps:
+    with open(path, 'r') as f:
+      deps_contents.append(f.read())
+  report, violations = check(args.module, dirs, deps_contents)
+  with open(args.output, 'w') as f:
+    f.write(report)
+  if violations and args.enforce:
+    sys.stderr.write(report)
+    sys.stderr.write('Add the libraries that own the headers to the dependencies of %s.\n' %
+                     args.module)
+    sys.exit(1)
+
+
+if __name__ == '__main__':
+  main()
@@ -0,0 +1,94 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_include_layering.py."""
+
+import unittest
+
+import check_include_layering
+
+DIRS = [
+    'own\tfoo',
+    'allowed\tsystem/libbar/include',
+    'transitive\tsystem/libbaz/include\tlibbaz\tlibbar',
+    'include_dirs\texternal/qux',
+    'allowed\texternal/qux/include',
+]
+
+INCLUDES = """\
+foo/foo.cpp
+. foo/foo.h
+.. system/libbaz/include/baz_types.h
+. /proc/self/cwd/system/libbar/include/bar.h
+.. system/libbaz/include/baz.h
+... system/libbaz/include/baz_types.h
+. system/libbaz/include/baz.h
+. external/qux/qux.h
+.. external/qux/internal.h
+. external/qux/include/qux.h
+.. prebuilts/clang/include/stddef.h
+Multiple include guards may be useful for:
+foo/foo.h
+"""
+
+
+class CheckTest(unittest.TestCase):
+  """Unit tests for check function."""
+
+  def setUp(self):
+    self.dirs = check_include_layering.read_dirs(DIRS)
+
+  def test_parse_includes(self):
+    source, includes = check_include_layering.parse_includes(INCLUDES)
+    self.assertEqual(source, 'foo/foo.cpp')
+    self.assertEqual(includes[:4], [
+        ('foo/foo.cpp', 'foo/foo.h'),
+        ('foo/foo.h', 'system/libbaz/include/baz_types.h'),
+        ('foo/foo.cpp', 'system/libbar/include/bar.h'),
+        ('system/libbar/include/bar.h', 'system/libbaz/include/baz.h'),
+    ])
+    self.assertEqual(len(includes), 10)
+
+  def test_violations(self):
+    report, violations = check_include_layering.check('libfoo', self.dirs, [INCLUDES])
+    self.assertEqual(violations, 3)
+    self.assertEqual(report.splitlines()[1:], [
+        '3 violations',
+        'foo/foo.cpp: includes external/qux/qux.h through include_dirs external/qux instead '
+        'of a dependency',
+        'foo/foo.cpp: includes system/libbaz/include/baz.h of libbaz, which is not a direct '
+        'dependency but is re-exported by libbar',
+        'foo/foo.h: includes system/libbaz/include/baz_types.h of libbaz, which is not a '
+        'direct dependency but is re-exported by libbar',
+    ])
+
+  def test_nested_include_through_allowed_header(self):
+    includes = """\
+foo/foo.cpp
+. system/libbar/include/bar.h
+.. system/libbaz/include/baz.h
+"""
+    report, violations = check_include_layering.check('libfoo', self.dirs, [includes])
+    self.assertEqual(violations, 0, report)
+
+  def test_allowed_wins(self):
+    dirs = check_include_layering.read_dirs(DIRS + ['allowed\tsystem/libbaz/include'])
+    _, violations = check_include_layering.check('libfoo', dirs, [INCLUDES])
+    self.assertEqual(violations, 1)
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
@@ -0,0 +1,100 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Checks the merged compat config against a checked-in snapshot.
+
+Compat change IDs are effectively API: apps and tests refer to them by ID, and
+whether a change is enabled for an app depends on its target SDK. The check
+fails when a change ID in the snapshot is removed from the merged compat config,
+or when its enableAfterTargetSdk, enableSinceTargetSdk or disabled state
+differs from the snapshot. Changes that are not in the snapshot yet are
+reported but do not fail the check.
+"""
+
+import argparse
+import sys
+import xml.etree.ElementTree as ET
+
+# The attributes of a compat change that affect its behavior on device.
+CHECKED_ATTRIBUTES = ('enableAfterTargetSdk', 'enableSinceTargetSdk', 'disabled')
+
+
+def parse_args():
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser()
+  parser.add_argument('--snapshot', required=True, help='checked-in snapshot of the compat config.')
+  parser.add_argument('--current', required=True, help='merged compat config of the build.')
+  return parser.parse_args()
+
+
+def read_changes(path):
+  """Returns a map from change ID to the attributes of each change in a compat config file."""
+  return parse_changes(ET.parse(path).getroot())
+
+
+def parse_changes(root):
+  """Returns a map from change ID to the attributes of each change below a <config> element."""
+  changes = {}
+  for element in root.iter('compat-change'):
+    change_id = element.get('id')
+    if change_id is None:
+      raise ValueError('compat-change without an id: %s' % ET.tostring(element, encoding='unicode'))
+    changes[int(change_id)] = element.attrib
+  return changes
+
+
+def describe(change_id, change):
+  return '%d (%s)' % (change_id, change.get('name', 'unnamed'))
+
+
+def compare(snapshot, current):
+  """Compares the changes of the snapshot with the current ones.
+
+  Returns a tuple of the errors and of the notes about changes missing from the
+  snapshot.
+  """
+  errors = []
+  notes = []
+  for change_id in sorted(snapshot):
+    old = snapshot[change_id]
+    if change_id not in current:
+      errors.append('change %s was removed' % describe(change_id, old))
+      continue
+    new = current[change_id]
+    for attribute in CHECKED_ATTRIBUTES:
+      if old.get(attribute) != new.get(attribute):
+        errors.append('change %s: %s changed from %s to %s' % (
+            describe(change_id, new), attribute, old.get(attribute, 'unset'),
+            new.get(attribute, 'unset')))
+  for change_id in sorted(set(current) - set(snapshot)):
+    notes.append('change %s is not in the snapshot' % describe(change_id, current[change_id]))
+  return errors, notes
+
+
+def main():
+  """Program entry point."""
+  args = parse_args()
+  errors, notes = compare(read_changes(args.snapshot), read_changes(args.current))
+  for note in notes:
+    print('note: ' + note)
+  for error in errors:
+    print('error: ' + error, file=sys.stderr)
+  if errors:
+    sys.exit(1)
+
+
+if __name__ == '__main__':
+  main()
@@ -0,0 +1,69 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for compat_config_snapshot.py."""
+
+import unittest
+import xml.etree.ElementTree as ET
+
+import compat_config_snapshot
+
+SNAPSHOT = """\
+<config>
+  <compat-change description="Foo" enableAfterTargetSdk="29" id="1000" name="FOO">
+    <meta-data definedIn="android.foo.Foo" sourcePosition="Foo.java:10"/>
+  </compat-change>
+  <compat-change disabled="true" id="1001" name="BAR"/>
+  <compat-change id="1002" name="BAZ"/>
+</config>
+"""
+
+
+def parse(xml):
+  return compat_config_snapshot.parse_changes(ET.fromstring(xml))
+
+
+class CompareTest(unittest.TestCase):
+  """Unit tests for compare function."""
+
+  def test_unchanged(self):
+    # Descriptions and source positions may change freely.
+    current = SNAPSHOT.replace('description="Foo"', 'description="Better Foo"').replace(
+        'Foo.java:10', 'Foo.java:12')
+    self.assertEqual(compat_config_snapshot.compare(parse(SNAPSHOT), parse(current)), ([], []))
+
+  def test_removed(self):
+    current = SNAPSHOT.replace('<compat-change id="1002" name="BAZ"/>', '')
+    errors, _ = compat_config_snapshot.compare(parse(SNAPSHOT), parse(current))
+    self.assertEqual(errors, ['change 1002 (BAZ) was removed'])
+
+  def test_state_changed(self):
+    current = SNAPSHOT.replace('enableAfterTargetSdk="29"', 'enableAfterTargetSdk="30"').replace(
+        'disabled="true" ', '')
+    errors, _ = compat_config_snapshot.compare(parse(SNAPSHOT), parse(current))
+    self.assertEqual(errors, [
+        'change 1000 (FOO): enableAfterTargetSdk changed from 29 to 30',
+        'change 1001 (BAR): disabled changed from true to unset',
+    ])
+
+  def test_added(self):
+    current = SNAPSHOT.replace('</config>', '<compat-change id="1003" name="QUX"/></config>')
+    self.assertEqual(compat_config_snapshot.compare(parse(SNAPSHOT), parse(current)),
+                     ([], ['change 1003 (QUX) is not in the snapshot']))
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
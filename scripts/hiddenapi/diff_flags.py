@@ -0,0 +1,213 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Report the hidden API signatures that moved between API lists.
+
+Compares the hidden API flags of the current build against a reference set of
+flags, e.g. the all-flags.csv file from the previous release, and lists every
+signature whose API list (sdk, unsupported, blocked or max-target-*) changed,
+together with the flag files in the current build that mention it.
+"""
+
+import argparse
+import collections
+import csv
+import sys
+
+# The flags that identify the API list to which a signature belongs. The
+# max-target-* lists are matched by prefix so that lists added in future
+# releases are picked up automatically.
+FLAG_SDK = "sdk"
+FLAG_UNSUPPORTED = "unsupported"
+FLAG_BLOCKED = "blocked"
+FLAG_MAX_TARGET_PREFIX = "max-target-"
+
+# The API list used for signatures that are not present in a set of flags.
+LIST_ABSENT = "<absent>"
+
+# A flag file that contributed to the flags of the current build.
+#
+# module is the name of the module, e.g. a bootclasspath_fragment, whose
+# hidden_api properties specified the file, category is the name of the
+# property, e.g. "blocked", and path is the path to the file.
+FlagFile = collections.namedtuple("FlagFile", ["module", "category", "path"])
+
+# A signature that moved from one API list to another.
+Change = collections.namedtuple(
+    "Change", ["signature", "old_list", "new_list", "flag_files"])
+
+
+def is_api_list_flag(flag):
+    return flag in (FLAG_SDK, FLAG_UNSUPPORTED, FLAG_BLOCKED) or \
+        flag.startswith(FLAG_MAX_TARGET_PREFIX)
+
+
+def api_list(flags):
+    """Returns the API list from the flags of a signature."""
+    lists = sorted(f for f in flags if is_api_list_flag(f))
+    return "|".join(lists) if lists else LIST_ABSENT
+
+
+def read_api_lists_from_stream(stream):
+    """Reads a flags CSV file and returns a map from signature to API list."""
+    result = {}
+    for row in csv.reader(stream, delimiter=",", quotechar="|"):
+        if not row:
+            continue
+        result[row[0]] = api_list(row[1:])
+    return result
+
+
+def read_api_lists_from_file(path):
+    with open(path, "r", encoding="utf8") as stream:
+        return read_api_lists_from_stream(stream)
+
+
+def read_flag_file_entries_from_stream(stream):
+    """Reads the signatures, or packages, listed in a flag file."""
+    entries = set()
+    for line in stream:
+        line = line.strip()
+        if not line or line.startswith("#"):
+            continue
+        entries.add(line)
+    return entries
+
+
+def read_flag_file_entries_from_file(path):
+    with open(path, "r", encoding="utf8") as stream:
+        return read_flag_file_entries_from_stream(stream)
+
+
+def extract_package(signature):
+    """Extracts the package from a signature, e.g. java.lang from
+    Ljava/lang/Object;->hashCode()I.
+    """
+    class_name = signature.split(";->")[0]
+    return "/".join(class_name[1:].split("/")[:-1]).replace("/", ".")
+
+
+class FlagFileIndex:
+    """An index from signature to the flag files that mention it."""
+
+    def __init__(self):
+        self.by_signature = collections.defaultdict(list)
+        self.by_package = collections.defaultdict(list)
+
+    def add(self, flag_file, entries):
+        # The unsupported_packages category lists packages rather than
+        # signatures.
+        index = self.by_package if flag_file.category == \
+            "unsupported_packages" else self.by_signature
+        for entry in entries:
+            if flag_file not in index[entry]:
+                index[entry].append(flag_file)
+
+    def lookup(self, signature):
+        return sorted(
+            self.by_signature.get(signature, []) +
+            self.by_package.get(extract_package(signature), []))
+
+
+def compute_changes(reference, current, index):
+    """Returns the signatures in both sets of flags whose API list changed."""
+    changes = []
+    for signature in sorted(reference.keys() & current.keys()):
+        old_list = reference[signature]
+        new_list = current[signature]
+        if old_list != new_list:
+            changes.append(
+                Change(signature, old_list, new_list, index.lookup(signature)))
+    return changes
+
+
+def format_report(reference_path, current_path, reference, current, changes):
+    lines = [
+        "Hidden API flag changes from %s to %s" % (reference_path,
+                                                  current_path),
+        "",
+        "%d signature(s) moved between API lists" % len(changes),
+        "%d signature(s) added" % len(current.keys() - reference.keys()),
+        "%d signature(s) removed" % len(reference.keys() - current.keys()),
+    ]
+
+    by_transition = collections.defaultdict(list)
+    for change in changes:
+        by_transition[(change.old_list, change.new_list)].append(change)
+
+    for (old_list, new_list), transition_changes in sorted(
+            by_transition.items()):
+        lines.append("")
+        lines.append("%s -> %s (%d)" % (old_list, new_list,
+                                       len(transition_changes)))
+        for change in transition_changes:
+            lines.append("  " + change.signature)
+            if not change.flag_files:
+                lines.append("    no flag file, derived from annotations or "
+                             "the API stubs")
+            for flag_file in change.flag_files:
+                lines.append("    %s: %s: %s" % (flag_file.module,
+                                                 flag_file.category,
+                                                 flag_file.path))
+
+    return "\n".join(lines) + "\n"
+
+
+def parse_flag_file_arg(arg):
+    parts = arg.split(":", 2)
+    if len(parts) != 3:
+        raise argparse.ArgumentTypeError(
+            "expected <module>:<category>:<path>, got %r" % arg)
+    return FlagFile(*parts)
+
+
+def main(argv):
+    args_parser = argparse.ArgumentParser(
+        description="Report the hidden API signatures whose API list changed "
+        "between a reference set of flags and the flags of the current build.")
+    args_parser.add_argument(
+        "--reference", required=True,
+        help="The reference flags, e.g. all-flags.csv from a previous release")
+    args_parser.add_argument(
+        "--current", required=True, help="The flags of the current build")
+    args_parser.add_argument(
+        "--flag-file",
+        action="append",
+        type=parse_flag_file_arg,
+        default=[],
+        help="A flag file that contributed to the flags of the current build, "
+        "specified as <module>:<category>:<path>. Used to identify the module "
+        "and file responsible for each change. Specify once for each file.")
+    args_parser.add_argument(
+        "--output", required=True, help="The file to which the report is written")
+    args = args_parser.parse_args(argv[1:])
+
+    reference = read_api_lists_from_file(args.reference)
+    current = read_api_lists_from_file(args.current)
+
+    index = FlagFileIndex()
+    for flag_file in args.flag_file:
+        index.add(flag_file, read_flag_file_entries_from_file(flag_file.path))
+
+    changes = compute_changes(reference, current, index)
+
+    with open(args.output, "w", encoding="utf8") as f:
+        f.write(
+            format_report(args.reference, args.current, reference, current,
+                          changes))
+
+
+if __name__ == "__main__":
+    main(sys.argv)
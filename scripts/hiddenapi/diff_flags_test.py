@@ -0,0 +1,94 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Unit tests for diff_flags.py."""
+import io
+import unittest
+
+import diff_flags as df
+
+
+class TestDiffFlags(unittest.TestCase):
+
+    @staticmethod
+    def read_api_lists_from_string(csvdata):
+        with io.StringIO(csvdata) as f:
+            return df.read_api_lists_from_stream(f)
+
+    def test_api_list(self):
+        self.assertEqual("sdk", df.api_list(["public-api", "sdk"]))
+        self.assertEqual("max-target-o",
+                         df.api_list(["max-target-o", "lo-prio"]))
+        self.assertEqual(df.LIST_ABSENT, df.api_list(["public-api"]))
+
+    def test_extract_package(self):
+        self.assertEqual(
+            "java.lang", df.extract_package("Ljava/lang/Object;->hashCode()I"))
+        self.assertEqual("", df.extract_package("LFoo;->bar()V"))
+
+    def test_compute_changes(self):
+        reference = self.read_api_lists_from_string(
+            "La/b/C;->m1()V,public-api,sdk\n"
+            "La/b/C;->m2()V,unsupported\n"
+            "La/b/C;->m3()V,blocked\n"
+            "La/b/C;->removed()V,blocked\n")
+        current = self.read_api_lists_from_string(
+            "La/b/C;->m1()V,public-api,sdk\n"
+            "La/b/C;->m2()V,blocked\n"
+            "La/b/C;->m3()V,max-target-o,lo-prio\n"
+            "La/b/C;->added()V,blocked\n")
+
+        index = df.FlagFileIndex()
+        blocked = df.FlagFile("art-bootclasspath-fragment", "blocked",
+                              "hiddenapi/blocked.txt")
+        packages = df.FlagFile("platform-bootclasspath", "unsupported_packages",
+                               "hiddenapi/packages.txt")
+        index.add(blocked, {"La/b/C;->m2()V"})
+        index.add(packages, {"a.b"})
+
+        changes = df.compute_changes(reference, current, index)
+        self.assertEqual([
+            df.Change("La/b/C;->m2()V", "unsupported", "blocked",
+                      [blocked, packages]),
+            df.Change("La/b/C;->m3()V", "blocked", "max-target-o", [packages]),
+        ], changes)
+
+        report = df.format_report("ref.csv", "cur.csv", reference, current,
+                                  changes)
+        self.assertEqual(
+            """Hidden API flag changes from ref.csv to cur.csv
+
+2 signature(s) moved between API lists
+1 signature(s) added
+1 signature(s) removed
+
+blocked -> max-target-o (1)
+  La/b/C;->m3()V
+    platform-bootclasspath: unsupported_packages: hiddenapi/packages.txt
+
+unsupported -> blocked (1)
+  La/b/C;->m2()V
+    art-bootclasspath-fragment: blocked: hiddenapi/blocked.txt
+    platform-bootclasspath: unsupported_packages: hiddenapi/packages.txt
+""", report)
+
+    def test_read_flag_file_entries(self):
+        with io.StringIO("# comment\n\nLa/b/C;->m()V\n") as f:
+            self.assertEqual({"La/b/C;->m()V"},
+                             df.read_flag_file_entries_from_stream(f))
+
+
+if __name__ == "__main__":
+    unittest.main(verbosity=2)
@@ -0,0 +1,133 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Generates source-based coverage reports for native binaries with llvm-cov.
+
+The .profraw and .profdata files of all the test runs are merged into one
+.profdata file, which is used to export LCOV and JSON reports and to render an
+HTML report of the coverage-instrumented binaries. Soong compiles with
+PWD=/proc/self/cwd, so the source paths in the binaries are mapped back to
+paths relative to the root of the source tree.
+"""
+
+import argparse
+import json
+import os
+import subprocess
+import sys
+import tempfile
+import zipfile
+
+# The directory the compiler runs in, see cc/builder.go.
+COMPILATION_DIR = '/proc/self/cwd'
+
+# A fixed timestamp, so that the HTML report zip only changes with its contents.
+ZIP_DATE_TIME = (2008, 1, 1, 0, 0, 0)
+
+
+def source_path(path, top):
+  """Returns the path of a source file relative to the root of the source tree."""
+  for prefix in (COMPILATION_DIR, top):
+    if path.startswith(prefix + '/'):
+      return path[len(prefix) + 1:]
+  return path
+
+
+def map_lcov_paths(lcov, top):
+  """Maps the source file paths of an LCOV report to the source tree."""
+  lines = []
+  for line in lcov.splitlines():
+    if line.startswith('SF:'):
+      line = 'SF:' + source_path(line[len('SF:'):], top)
+    lines.append(line)
+  return '\n'.join(lines) + '\n'
+
+
+def map_json_paths(exported, top):
+  """Maps the source file paths of a JSON report exported by llvm-cov to the source tree."""
+  for data in exported.get('data', []):
+    for f in data.get('files', []):
+      f['filename'] = source_path(f['filename'], top)
+    for function in data.get('functions', []):
+      function['filenames'] = [source_path(p, top) for p in function.get('filenames', [])]
+  return exported
+
+
+def object_args(binaries):
+  """Returns the llvm-cov arguments listing the binaries to report."""
+  args = [binaries[0]]
+  for binary in binaries[1:]:
+    args += ['-object', binary]
+  return args
+
+
+def write_zip(output, directory):
+  """Writes the files of a directory to a zip."""
+  with zipfile.ZipFile(output, 'w', zipfile.ZIP_DEFLATED) as z:
+    for root, dirs, files in os.walk(directory):
+      dirs.sort()
+      for name in sorted(files):
+        path = os.path.join(root, name)
+        info = zipfile.ZipInfo(os.path.relpath(path, directory), ZIP_DATE_TIME)
+        info.external_attr = 0o644 << 16
+        info.compress_type = zipfile.ZIP_DEFLATED
+        with open(path, 'rb') as f:
+          z.writestr(info, f.read())
+
+
+def parse_args(argv):
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser()
+  parser.add_argument('--binary', action='append', required=True,
+                      help='unstripped coverage-instrumented binary to report.')
+  parser.add_argument('--profdata', required=True, help='merged .profdata file to write.')
+  parser.add_argument('--lcov', required=True, help='LCOV report to write.')
+  parser.add_argument('--json', required=True, help='JSON report to write.')
+  parser.add_argument('--html', required=True, help='zip of the HTML report to write.')
+  parser.add_argument('profiles', nargs='+', help='.profraw and .profdata files of the test runs.')
+  return parser.parse_args(argv)
+
+
+def main(argv):
+  """Program entry point."""
+  args = parse_args(argv)
+  clang_bin = os.environ.get('CLANG_BIN', '')
+  llvm_profdata = os.path.join(clang_bin, 'llvm-profdata')
+  llvm_cov = os.path.join(clang_bin, 'llvm-cov')
+  top = os.getcwd()
+
+  subprocess.check_call([llvm_profdata, 'merge', '-sparse', '-o', args.profdata] + args.profiles)
+
+  common_args = ['-instr-profile', args.profdata,
+                 '-path-equivalence=%s,%s' % (COMPILATION_DIR, top)] + object_args(args.binary)
+
+  lcov = subprocess.check_output([llvm_cov, 'export', '-format=lcov'] + common_args, text=True)
+  with open(args.lcov, 'w') as f:
+    f.write(map_lcov_paths(lcov, top))
+
+  exported = json.loads(
+      subprocess.check_output([llvm_cov, 'export', '-format=text'] + common_args, text=True))
+  with open(args.json, 'w') as f:
+    json.dump(map_json_paths(exported, top), f)
+
+  with tempfile.TemporaryDirectory() as html_dir:
+    subprocess.check_call([llvm_cov, 'show', '-format=html', '-output-dir=' + html_dir] +
+                          common_args)
+    write_zip(args.html, html_dir)
+
+
+if __name__ == '__main__':
+  main(sys.argv[1:])
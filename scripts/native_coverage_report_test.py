@@ -0,0 +1,88 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for native_coverage_report.py."""
+
+import os
+import tempfile
+import unittest
+import zipfile
+
+import native_coverage_report
+
+TOP = '/src/top'
+
+
+class NativeCoverageReportTest(unittest.TestCase):
+  """Unit tests for native_coverage_report."""
+
+  def test_source_path(self):
+    self.assertEqual(native_coverage_report.source_path('/proc/self/cwd/external/foo.cpp', TOP),
+                     'external/foo.cpp')
+    self.assertEqual(native_coverage_report.source_path('/src/top/system/bar.rs', TOP),
+                     'system/bar.rs')
+    self.assertEqual(native_coverage_report.source_path('/src/topper/baz.c', TOP),
+                     '/src/topper/baz.c')
+    self.assertEqual(native_coverage_report.source_path('bionic/libc.c', TOP), 'bionic/libc.c')
+
+  def test_map_lcov_paths(self):
+    lcov = ('SF:/proc/self/cwd/external/foo.cpp\n'
+            'DA:1,1\n'
+            'end_of_record\n'
+            'SF:/src/top/system/bar.rs\n'
+            'end_of_record\n')
+    self.assertEqual(native_coverage_report.map_lcov_paths(lcov, TOP),
+                     'SF:external/foo.cpp\n'
+                     'DA:1,1\n'
+                     'end_of_record\n'
+                     'SF:system/bar.rs\n'
+                     'end_of_record\n')
+
+  def test_map_json_paths(self):
+    exported = {
+        'data': [{
+            'files': [{'filename': '/proc/self/cwd/external/foo.cpp', 'summary': {}}],
+            'functions': [{'name': 'foo', 'filenames': ['/src/top/external/foo.h']}],
+        }],
+        'type': 'llvm.coverage.json.export',
+    }
+    mapped = native_coverage_report.map_json_paths(exported, TOP)
+    self.assertEqual(mapped['data'][0]['files'][0]['filename'], 'external/foo.cpp')
+    self.assertEqual(mapped['data'][0]['functions'][0]['filenames'], ['external/foo.h'])
+
+  def test_object_args(self):
+    self.assertEqual(native_coverage_report.object_args(['a']), ['a'])
+    self.assertEqual(native_coverage_report.object_args(['a', 'b', 'c']),
+                     ['a', '-object', 'b', '-object', 'c'])
+
+  def test_write_zip(self):
+    with tempfile.TemporaryDirectory() as tmp:
+      html_dir = os.path.join(tmp, 'html')
+      os.makedirs(os.path.join(html_dir, 'coverage'))
+      with open(os.path.join(html_dir, 'index.html'), 'w') as f:
+        f.write('index')
+      with open(os.path.join(html_dir, 'coverage', 'foo.cpp.html'), 'w') as f:
+        f.write('foo')
+      output = os.path.join(tmp, 'html.zip')
+
+      native_coverage_report.write_zip(output, html_dir)
+      with zipfile.ZipFile(output) as z:
+        self.assertEqual(z.namelist(), ['index.html', 'coverage/foo.cpp.html'])
+        self.assertEqual(z.read('coverage/foo.cpp.html'), b'foo')
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
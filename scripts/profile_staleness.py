@@ -0,0 +1,195 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Reports how much of the AFDO and PGO profile of each module still matches its code.
+
+The input is a manifest listing, for each source compiled with a profile, the
+profile, the module and the file holding the clang diagnostics of compiling the
+source again with the profile diagnostics enabled. For AFDO, clang reports the
+profile records applied to each function with a profile. A function matches if
+most of its records still apply; functions defined in headers are only counted
+once. For PGO, clang reports the number of functions of each source, and how
+many of them have mismatched or no profile data.
+
+The staleness is computed and flagged per module, as the modules sharing a
+profile may not all have drifted from it. The totals of each profile over all
+the modules using it are reported too.
+"""
+
+import argparse
+import collections
+import json
+import re
+import sys
+
+# The percentage of the profile records of a function that must apply for the function to match.
+FUNCTION_MATCH_PERCENT = 90
+
+AFDO_RE = re.compile(r'(\S+:\d+): (\d+) of (\d+) available profile records \(\d+%\) were applied')
+PGO_MISMATCHED_RE = re.compile(
+    r'profile data may be out of date: of (\d+) functions?, (\d+) (?:has|have) mismatched data')
+PGO_MISSING_RE = re.compile(
+    r'profile data may be incomplete: of (\d+) functions?, (\d+) (?:has|have) no data')
+
+
+class ProfileStats:
+  """The diagnostics of the sources compiled with a profile."""
+
+  def __init__(self):
+    self.modules = set()
+    # The (used, total) profile records of each function with an AFDO profile, by location.
+    self.afdo_records = {}
+    # The number of functions and of mismatched or missing ones of each source with PGO.
+    self.pgo_sources = []
+
+  def add_diagnostics(self, text):
+    """Adds the diagnostics of compiling a source."""
+    for match in AFDO_RE.finditer(text):
+      location, used, total = match.group(1), int(match.group(2)), int(match.group(3))
+      # A function defined in a header is compiled in many sources, keep its best match.
+      if location not in self.afdo_records or used > self.afdo_records[location][0]:
+        self.afdo_records[location] = (used, total)
+
+    functions = 0
+    unmatched = 0
+    for regex in (PGO_MISMATCHED_RE, PGO_MISSING_RE):
+      for match in regex.finditer(text):
+        functions = max(functions, int(match.group(1)))
+        unmatched += int(match.group(2))
+    if functions:
+      self.pgo_sources.append((functions, min(unmatched, functions)))
+
+  def stale_functions(self):
+    """Returns the locations of the functions whose AFDO profile no longer matches."""
+    return sorted(location for location, (used, total) in self.afdo_records.items()
+                  if total and used * 100 < total * FUNCTION_MATCH_PERCENT)
+
+  def functions(self):
+    """Returns the number of profiled functions."""
+    return len(self.afdo_records) + sum(functions for functions, _ in self.pgo_sources)
+
+  def matched_functions(self):
+    """Returns the number of profiled functions that still match their profile."""
+    return (len(self.afdo_records) - len(self.stale_functions()) +
+            sum(functions - unmatched for functions, unmatched in self.pgo_sources))
+
+
+def read_manifest(manifest):
+  """Returns the (profile, module, diagnostics file) entries of a manifest."""
+  entries = []
+  for line in manifest.splitlines():
+    if line.strip():
+      profile, module, path = line.split('\t')
+      entries.append((profile, module, path))
+  return entries
+
+
+def summary(stats, threshold=None):
+  """Returns the summary of the diagnostics, flagged as stale below the threshold if any."""
+  functions = stats.functions()
+  matched = stats.matched_functions()
+  matched_percent = round(100.0 * matched / functions, 1) if functions else 100.0
+  ret = {
+      'functions': functions,
+      'matched_functions': matched,
+      'matched_percent': matched_percent,
+  }
+  if threshold is not None:
+    ret['stale'] = bool(functions) and matched_percent < threshold
+    ret['stale_functions'] = stats.stale_functions()
+  return ret
+
+
+def create_report(entries, read_file, threshold):
+  """Returns the report of the modules and profiles listed in the manifest entries."""
+  module_stats = collections.defaultdict(ProfileStats)
+  profile_stats = collections.defaultdict(ProfileStats)
+  for profile, module, path in entries:
+    diagnostics = read_file(path)
+    module_stats[(module, profile)].add_diagnostics(diagnostics)
+    profile_stats[profile].modules.add(module)
+    profile_stats[profile].add_diagnostics(diagnostics)
+
+  modules = []
+  for (module, profile), stats in module_stats.items():
+    modules.append(dict(module=module, profile=profile, **summary(stats, threshold)))
+  modules.sort(key=lambda m: (m['matched_percent'], m['module'], m['profile']))
+
+  profiles = []
+  for profile, stats in profile_stats.items():
+    profiles.append(dict(profile=profile, modules=sorted(stats.modules), **summary(stats)))
+  profiles.sort(key=lambda p: (p['matched_percent'], p['profile']))
+  return {'threshold': threshold, 'modules': modules, 'profiles': profiles}
+
+
+def format_module(module):
+  """Returns the summary line of a module."""
+  return '  %s (%s): %s%% of %d functions match\n' % (
+      module['module'], module['profile'], module['matched_percent'], module['functions'])
+
+
+def format_profile(profile):
+  """Returns the summary line of a profile."""
+  return '  %s: %s%% of %d functions match (%s)\n' % (
+      profile['profile'], profile['matched_percent'], profile['functions'],
+      ', '.join(profile['modules']))
+
+
+def format_report(report):
+  """Returns the text summary of a report."""
+  text = ''
+  stale = [m for m in report['modules'] if m['stale']]
+  if stale:
+    text += 'Stale modules (fewer than %d%% of the functions match)\n' % report['threshold']
+    text += ''.join(format_module(m) for m in stale)
+    text += '\n'
+  text += 'All modules\n'
+  text += ''.join(format_module(m) for m in report['modules'])
+  text += '\n'
+  text += 'All profiles\n'
+  text += ''.join(format_profile(p) for p in report['profiles'])
+  return text
+
+
+def read_file(path):
+  with open(path) as f:
+    return f.read()
+
+
+def parse_args(argv):
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser()
+  parser.add_argument('--manifest', required=True,
+                      help='file listing the profile, module and diagnostics file of each source.')
+  parser.add_argument('--threshold', type=int, default=50,
+                      help='percentage of the functions below which a module is stale.')
+  parser.add_argument('--json', required=True, help='JSON report to write.')
+  parser.add_argument('--text', required=True, help='text summary to write.')
+  return parser.parse_args(argv)
+
+
+def main(argv):
+  """Program entry point."""
+  args = parse_args(argv)
+  report = create_report(read_manifest(read_file(args.manifest)), read_file, args.threshold)
+  with open(args.json, 'w') as f:
+    json.dump(report, f, indent=2)
+  with open(args.text, 'w') as f:
+    f.write(format_report(report))
+
+
+if __name__ == '__main__':
+  main(sys.argv[1:])
@@ -0,0 +1,125 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for profile_staleness.py."""
+
+import unittest
+
+import profile_staleness
+
+AFDO_FOO = (
+    'warning: external/foo/foo.cpp:10: 10 of 10 available profile records (100%) were applied '
+    '[-Wbackend-plugin]\n'
+    'warning: external/foo/foo.cpp:30: 2 of 8 available profile records (25%) were applied '
+    '[-Wbackend-plugin]\n'
+    'warning: external/foo/foo.h:5: 1 of 4 available profile records (25%) were applied '
+    '[-Wbackend-plugin]\n'
+    'external/foo/foo.cpp:12:3: warning: unused variable \'x\' [-Wunused-variable]\n')
+
+AFDO_BAR = (
+    'warning: external/foo/foo.h:5: 4 of 4 available profile records (100%) were applied '
+    '[-Wbackend-plugin]\n'
+    'warning: external/foo/bar.cpp:3: 0 of 6 available profile records (0%) were applied '
+    '[-Wbackend-plugin]\n')
+
+PGO_BAZ = (
+    'warning: profile data may be out of date: of 10 functions, 3 have mismatched data that will '
+    'be ignored [-Wprofile-instr-out-of-date]\n'
+    'warning: profile data may be incomplete: of 10 functions, 1 has no data '
+    '[-Wprofile-instr-missing]\n')
+
+
+class ProfileStalenessTest(unittest.TestCase):
+  """Unit tests for profile_staleness."""
+
+  def create_report(self, threshold=50):
+    files = {
+        'foo.profile_check': AFDO_FOO,
+        'bar.profile_check': AFDO_BAR,
+        'baz.profile_check': PGO_BAZ,
+        'qux.profile_check': '',
+    }
+    manifest = ('afdo/libfoo.afdo\tlibfoo\tfoo.profile_check\n'
+                'afdo/libfoo.afdo\tlibbar\tbar.profile_check\n'
+                'pgo/baz.profdata\tbaz\tbaz.profile_check\n'
+                'pgo/qux.profdata\tqux\tqux.profile_check\n')
+    return profile_staleness.create_report(profile_staleness.read_manifest(manifest),
+                                           files.get, threshold)
+
+  def find(self, entries, **keys):
+    return [e for e in entries if all(e[k] == v for k, v in keys.items())][0]
+
+  def test_afdo(self):
+    report = self.create_report()
+    foo = self.find(report['modules'], module='libfoo')
+    self.assertEqual(foo['profile'], 'afdo/libfoo.afdo')
+    self.assertEqual(foo['functions'], 3)
+    self.assertEqual(foo['matched_functions'], 1)
+    self.assertEqual(foo['matched_percent'], 33.3)
+    self.assertTrue(foo['stale'])
+    self.assertEqual(foo['stale_functions'], ['external/foo/foo.cpp:30', 'external/foo/foo.h:5'])
+
+    # The header function matches in libbar.
+    bar = self.find(report['modules'], module='libbar')
+    self.assertEqual(bar['functions'], 2)
+    self.assertEqual(bar['matched_functions'], 1)
+    self.assertFalse(bar['stale'])
+    self.assertEqual(bar['stale_functions'], ['external/foo/bar.cpp:3'])
+
+  def test_afdo_profile_totals(self):
+    report = self.create_report()
+    foo = self.find(report['profiles'], profile='afdo/libfoo.afdo')
+    self.assertEqual(foo['modules'], ['libbar', 'libfoo'])
+    # The header function matches in libbar, which is enough for the profile.
+    self.assertEqual(foo['functions'], 4)
+    self.assertEqual(foo['matched_functions'], 2)
+    self.assertEqual(foo['matched_percent'], 50.0)
+    self.assertNotIn('stale', foo)
+
+  def test_pgo(self):
+    report = self.create_report()
+    baz = self.find(report['modules'], module='baz')
+    self.assertEqual(baz['functions'], 10)
+    self.assertEqual(baz['matched_functions'], 6)
+    self.assertEqual(baz['stale_functions'], [])
+
+    qux = self.find(report['modules'], module='qux')
+    self.assertEqual(qux['functions'], 0)
+    self.assertEqual(qux['matched_percent'], 100.0)
+    self.assertFalse(qux['stale'])
+
+  def test_format_report(self):
+    report = self.create_report(threshold=60)
+    self.assertEqual([m['module'] for m in report['modules']], ['libfoo', 'libbar', 'baz', 'qux'])
+    self.assertEqual([p['profile'] for p in report['profiles']],
+                     ['afdo/libfoo.afdo', 'pgo/baz.profdata', 'pgo/qux.profdata'])
+    text = profile_staleness.format_report(report)
+    self.assertTrue(text.startswith(
+        'Stale modules (fewer than 60% of the functions match)\n'
+        '  libfoo (afdo/libfoo.afdo): 33.3% of 3 functions match\n'
+        '  libbar (afdo/libfoo.afdo): 50.0% of 2 functions match\n'
+        '\n'
+        'All modules\n'), text)
+    self.assertIn('  baz (pgo/baz.profdata): 60.0% of 10 functions match\n', text)
+    self.assertIn('All profiles\n'
+                  '  afdo/libfoo.afdo: 50.0% of 4 functions match (libbar, libfoo)\n', text)
+
+    report = self.create_report(threshold=10)
+    self.assertNotIn('Stale modules', profile_staleness.format_report(report))
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
@@ -0,0 +1,234 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Reports where each resource of an app comes from.
+
+Reads the resource sources that are merged into an app, i.e. the resource
+directories and zips of the app, of its static libraries and the resource
+overlays, and lists for each resource every definition in priority order along
+with the one that wins.
+
+The sources are read from a file with one tab separated line per source, from
+the lowest to the highest priority:
+  <kind> <module> <path> [<zip prefix>]
+where kind is one of:
+  res:     a resource directory or zip of the app or one of its static libraries.
+  overlay: a product or device overlay merged into the app at build time.
+  rro:     a product or device overlay that is turned into a runtime resource
+           overlay (RRO), which overrides the resources of the app on device.
+"""
+
+import argparse
+import collections
+import hashlib
+import os
+import xml.etree.ElementTree as ET
+import zipfile
+
+# The resource type of each element of a values file.
+VALUES_TAG_TYPES = {
+    'array': 'array',
+    'attr': 'attr',
+    'bool': 'bool',
+    'color': 'color',
+    'declare-styleable': 'styleable',
+    'dimen': 'dimen',
+    'drawable': 'drawable',
+    'fraction': 'fraction',
+    'id': 'id',
+    'integer': 'integer',
+    'integer-array': 'array',
+    'macro': 'macro',
+    'plurals': 'plurals',
+    'string': 'string',
+    'string-array': 'array',
+    'style': 'style',
+}
+
+Source = collections.namedtuple('Source', ['kind', 'module', 'path', 'prefix'])
+
+# A definition of a resource, where location is the file that defines it. The
+# value is used to tell whether an override actually changes the resource.
+Candidate = collections.namedtuple('Candidate', ['source', 'location', 'value'])
+
+
+def parse_args():
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser()
+  parser.add_argument('--module', required=True, help='name of the app.')
+  parser.add_argument('--sources', required=True,
+                      help='file listing the resource sources in priority order, lowest first.')
+  parser.add_argument('--output', required=True, help='report to write.')
+  return parser.parse_args()
+
+
+def read_sources(path):
+  """Reads the list of resource sources."""
+  sources = []
+  with open(path, 'r') as f:
+    for line in f:
+      fields = line.rstrip('\n').split('\t')
+      if not fields[0]:
+        continue
+      if len(fields) == 3:
+        fields.append('')
+      if len(fields) != 4:
+        raise ValueError('invalid resource source %r' % line)
+      sources.append(Source(*fields))
+  return sources
+
+
+def split_resource_dir(name):
+  """Splits the name of a resource directory into its type and configuration."""
+  res_type, _, config = name.partition('-')
+  return res_type, config
+
+
+def resource_name(filename):
+  """Returns the name of a file based resource, i.e. its file name without extensions."""
+  return filename.split('.')[0]
+
+
+def parse_values(content):
+  """Returns the (type, name, value) of each resource defined by a values file."""
+  try:
+    root = ET.fromstring(content)
+  except ET.ParseError:
+    return []
+  resources = []
+  for element in root:
+    res_type = element.get('type') if element.tag == 'item' else VALUES_TAG_TYPES.get(element.tag)
+    name = element.get('name')
+    if not res_type or not name:
+      continue
+    value = ET.tostring(element, encoding='unicode').strip()
+    resources.append((res_type, name, value))
+  return resources
+
+
+def resources_in_file(relpath, content):
+  """Returns the (key, value) of each resource defined by a file of a resource directory.
+
+  relpath is the path of the file relative to the resource directory, e.g. values-en/strings.xml.
+  """
+  parts = relpath.split('/')
+  if len(parts) != 2:
+    return []
+  dir_type, config = split_resource_dir(parts[0])
+  if dir_type == 'values':
+    return [((res_type, name, config), value) for res_type, name, value in parse_values(content)]
+  digest = hashlib.sha1(content).hexdigest()
+  return [((dir_type, resource_name(parts[1]), config), digest)]
+
+
+def source_files(source):
+  """Yields the (relative path, content, location) of every file in a resource source."""
+  if os.path.isdir(source.path):
+    for root, _, files in os.walk(source.path):
+      for name in sorted(files):
+        if name.startswith('.'):
+          continue
+        path = os.path.join(root, name)
+        with open(path, 'rb') as f:
+          yield os.path.relpath(path, source.path), f.read(), path
+  elif zipfile.is_zipfile(source.path):
+    prefix = source.prefix.rstrip('/') + '/' if source.prefix else ''
+    with zipfile.ZipFile(source.path) as z:
+      for name in sorted(z.namelist()):
+        if name.startswith(prefix) and not name.endswith('/'):
+          yield name[len(prefix):], z.read(name), '%s!/%s' % (source.path, name)
+
+
+def collect_candidates(sources, read_files=source_files):
+  """Returns a map from resource key to its candidates in priority order, lowest first."""
+  candidates = collections.defaultdict(list)
+  for source in sources:
+    for relpath, content, location in read_files(source):
+      for key, value in resources_in_file(relpath, content):
+        candidates[key].append(Candidate(source, location, value))
+  return candidates
+
+
+def find_warnings(module, key, candidates):
+  """Returns the warnings about unexpected overrides of a resource."""
+  warnings = []
+  winner = candidates[-1]
+  if all(c.source.kind != 'res' for c in candidates):
+    warnings.append('only defined by overlays, which cannot add resources that the app and its '
+                    'libraries do not define')
+  elif winner.source.kind == 'res' and winner.source.module != module:
+    # One static library overriding another one depends on the order of the static_libs
+    # rather than on an intended override by the app or an overlay.
+    overridden = sorted(set(
+        c.source.module for c in candidates[:-1]
+        if c.source.kind == 'res' and c.source.module not in (module, winner.source.module) and
+        c.value != winner.value))
+    if overridden:
+      warnings.append('library %s overrides the value from %s' %
+                      (winner.source.module, ', '.join(overridden)))
+  return warnings
+
+
+def format_key(key):
+  res_type, name, config = key
+  if config:
+    return '@%s/%s [%s]' % (res_type, name, config)
+  return '@%s/%s' % (res_type, name)
+
+
+def format_report(module, candidates):
+  """Returns the text of the report."""
+  lines = []
+  all_warnings = []
+  for key in sorted(candidates):
+    key_candidates = candidates[key]
+    lines.append(format_key(key))
+    for i, candidate in enumerate(key_candidates):
+      is_winner = i == len(key_candidates) - 1
+      notes = []
+      if is_winner and candidate.source.kind == 'rro':
+        notes.append('overrides the app at runtime')
+      if i > 0 and candidate.value == key_candidates[i - 1].value:
+        notes.append('same value')
+      lines.append('  %s %-8s %-30s %s%s' % (
+          '*' if is_winner else ' ', candidate.source.kind, candidate.source.module,
+          candidate.location,
+          ' (%s)' % ', '.join(notes) if notes else ''))
+    for warning in find_warnings(module, key, key_candidates):
+      lines.append('  warning: ' + warning)
+      all_warnings.append('warning: %s: %s' % (format_key(key), warning))
+
+  header = ['Resource provenance of %s' % module,
+            '%d resources, %d warnings' % (len(candidates), len(all_warnings)),
+            'Definitions are listed from the lowest to the highest priority, * marks the one used.',
+            'runtime_resource_overlay modules targeting the app are not included, they may override',
+            'any of these resources on the device.',
+            '']
+  if all_warnings:
+    header += all_warnings + ['']
+  return '\n'.join(header + lines) + '\n'
+
+
+def main():
+  """Program entry point."""
+  args = parse_args()
+  candidates = collect_candidates(read_sources(args.sources))
+  with open(args.output, 'w') as f:
+    f.write(format_report(args.module, candidates))
+
+
+if __name__ == '__main__':
+  main()
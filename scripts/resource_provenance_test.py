@@ -0,0 +1,124 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for resource_provenance.py."""
+
+import unittest
+
+import resource_provenance
+from resource_provenance import Source
+
+LIB_A = Source('res', 'lib_a', 'lib_a/res', '')
+LIB_B = Source('res', 'lib_b', 'lib_b/res', '')
+APP = Source('res', 'Foo', 'app/res', '')
+OVERLAY = Source('overlay', 'Foo', 'device/overlay/app/res', '')
+RRO = Source('rro', 'Foo', 'product/overlay/app/res', '')
+
+
+def strings(**values):
+  return ('<resources>%s</resources>' % ''.join(
+      '<string name="%s">%s</string>' % (name, value) for name, value in values.items())).encode()
+
+
+FILES = {
+    LIB_A: [('values/strings.xml', strings(title='A', shared='same')),
+            ('drawable-hdpi/icon.9.png', b'a')],
+    LIB_B: [('values/strings.xml', strings(title='B', shared='same'))],
+    APP: [('values/strings.xml', strings(label='Foo')),
+          ('values-en/strings.xml', strings(label='Foo en'))],
+    OVERLAY: [('values/strings.xml', strings(label='Bar'))],
+    RRO: [('values/strings.xml', strings(label='Baz', typo='Oops'))],
+}
+
+
+def read_files(source):
+  return [(relpath, content, source.path + '/' + relpath) for relpath, content in FILES[source]]
+
+
+def collect(sources):
+  return resource_provenance.collect_candidates(sources, read_files)
+
+
+class ResourcesInFileTest(unittest.TestCase):
+  """Unit tests for resources_in_file function."""
+
+  def test_values(self):
+    content = (b'<resources>'
+               b'<string name="a">A</string>'
+               b'<item type="id" name="b"/>'
+               b'<string-array name="c"><item>C</item></string-array>'
+               b'<eat-comment/>'
+               b'</resources>')
+    self.assertEqual([key for key, _ in resource_provenance.resources_in_file(
+        'values-en-rUS/strings.xml', content)], [
+            ('string', 'a', 'en-rUS'),
+            ('id', 'b', 'en-rUS'),
+            ('array', 'c', 'en-rUS'),
+        ])
+
+  def test_file(self):
+    resources = resource_provenance.resources_in_file('drawable-hdpi/icon.9.png', b'png')
+    self.assertEqual([key for key, _ in resources], [('drawable', 'icon', 'hdpi')])
+
+
+class CollectCandidatesTest(unittest.TestCase):
+  """Unit tests for collect_candidates function."""
+
+  def test_priority_order(self):
+    candidates = collect([LIB_A, LIB_B, APP, OVERLAY, RRO])
+    self.assertEqual([c.source for c in candidates[('string', 'label', '')]], [APP, OVERLAY, RRO])
+    self.assertEqual([c.source for c in candidates[('string', 'label', 'en')]], [APP])
+    self.assertEqual([c.source for c in candidates[('string', 'title', '')]], [LIB_A, LIB_B])
+
+
+class FindWarningsTest(unittest.TestCase):
+  """Unit tests for find_warnings function."""
+
+  def setUp(self):
+    self.candidates = collect([LIB_A, LIB_B, APP, OVERLAY, RRO])
+
+  def warnings(self, key):
+    return resource_provenance.find_warnings('Foo', key, self.candidates[key])
+
+  def test_overlays_override_app(self):
+    self.assertEqual(self.warnings(('string', 'label', '')), [])
+
+  def test_library_overrides_library(self):
+    self.assertEqual(self.warnings(('string', 'title', '')),
+                     ['library lib_b overrides the value from lib_a'])
+
+  def test_library_overrides_library_with_same_value(self):
+    self.assertEqual(self.warnings(('string', 'shared', '')), [])
+
+  def test_only_defined_by_overlays(self):
+    self.assertEqual(len(self.warnings(('string', 'typo', ''))), 1)
+
+
+class FormatReportTest(unittest.TestCase):
+  """Unit tests for format_report function."""
+
+  def test_report(self):
+    report = resource_provenance.format_report('Foo', collect([APP, OVERLAY, RRO]))
+    self.assertIn('3 resources, 1 warnings', report)
+    self.assertIn('runtime_resource_overlay modules targeting the app are not included', report)
+    self.assertIn('warning: @string/typo: only defined by overlays', report)
+    self.assertIn('@string/label [en]\n  * res', report)
+    self.assertIn('  * rro      Foo                            product/overlay/app/res/values/strings.xml'
+                  ' (overrides the app at runtime)', report)
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
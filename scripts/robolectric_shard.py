@@ -0,0 +1,171 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+
+"""Splits the test classes of a robolectric test between shards.
+
+The test classes are named after the package declarations of the test source
+files, which do not have to be below a directory matching the package.
+Writes one test config per shard, each a copy of the test config of the whole
+module restricted to the test classes of the shard with include-filter options
+of its <test> element.
+When the run times of the test classes from a previous run are available the
+classes are balanced between the shards by run time, otherwise each class is
+assumed to take the same time.
+"""
+
+import argparse
+import os
+import re
+from xml.sax import saxutils
+
+# An include filter that matches no test class, used for shards that have no
+# test classes as a shard without any include filters would run every test.
+EMPTY_SHARD_FILTER = 'android.robolectric.EmptyShard'
+
+# The time assumed for a test class when no timing data is available at all.
+DEFAULT_TIME = 1.0
+
+INCLUDE_FILTER_RE = re.compile(r'^[ \t]*<option\s+name="include-filter"[^>]*/>[ \t]*\n', re.MULTILINE)
+
+# The line closing the <test> element of the config, the indentation in group 1.
+TEST_END_RE = re.compile(r'^([ \t]*)</test>', re.MULTILINE)
+
+# The package declaration of a Java or Kotlin source file, the package in group 1.
+PACKAGE_RE = re.compile(r'^[ \t]*package[ \t]+([A-Za-z_][\w.]*)[ \t]*;?[ \t]*$', re.MULTILINE)
+
+
+def parse_args():
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser()
+  parser.add_argument('--config', required=True,
+                      help='test config of the whole module.')
+  parser.add_argument('--tests', required=True,
+                      help='file listing the test source files, one per line.')
+  parser.add_argument('--timings',
+                      help='file with the run time in seconds of each test class from a previous run, '
+                      'one "<class> <seconds>" or "<class>,<seconds>" pair per line.')
+  parser.add_argument('--include-filter', dest='include_filters', action='append', default=[],
+                      help='only run the tests matching the filter, either a class, a package or '
+                      'a <class>#<method>.')
+  parser.add_argument('--output', dest='outputs', action='append', default=[],
+                      help='file to which the config of a shard is written, one per shard.')
+  return parser.parse_args()
+
+
+def read_lines(path):
+  with open(path, 'r') as f:
+    return [line.strip() for line in f if line.strip() and not line.startswith('#')]
+
+
+def test_class(path, source):
+  """Returns the name of the test class in a Java or Kotlin source file."""
+  name = os.path.splitext(os.path.basename(path))[0]
+  match = PACKAGE_RE.search(source)
+  if not match:
+    raise ValueError('%s has no package declaration' % path)
+  return match.group(1) + '.' + name
+
+
+def parse_timings(lines):
+  """Returns a map from test class to run time in seconds."""
+  timings = {}
+  for line in lines:
+    fields = line.replace(',', ' ').split()
+    if len(fields) != 2:
+      raise ValueError('invalid timing line %r, expected "<class> <seconds>"' % line)
+    timings[fields[0]] = float(fields[1])
+  return timings
+
+
+def filters_for_class(test_class, include_filters):
+  """Returns the include filters that select the tests to run from a test class.
+
+  Returns an empty list if the class does not match any of the include filters.
+  """
+  if not include_filters:
+    return [test_class]
+  filters = []
+  for f in include_filters:
+    target = f.split('#')[0]
+    if target == test_class:
+      filters.append(f)
+    elif test_class.startswith(target + '.'):
+      filters.append(test_class)
+  return sorted(set(filters))
+
+
+def balance(test_classes, timings, num_shards):
+  """Splits the test classes into num_shards lists with similar total run times.
+
+  Uses the longest processing time first heuristic, i.e. assigns the classes in order of
+  decreasing run time to the shard with the smallest total run time so far. Classes without
+  timing data are assumed to take the average time of those that have it.
+  """
+  known = [timings[c] for c in test_classes if c in timings]
+  default_time = sum(known) / len(known) if known else DEFAULT_TIME
+
+  def time(test_class):
+    return timings.get(test_class, default_time)
+
+  shards = [[] for _ in range(num_shards)]
+  totals = [0.0] * num_shards
+  for test_class in sorted(test_classes, key=lambda c: (-time(c), c)):
+    index = totals.index(min(totals))
+    shards[index].append(test_class)
+    totals[index] += time(test_class)
+  return [sorted(shard) for shard in shards]
+
+
+def shard_config(config, filters):
+  """Returns the config restricted to the tests selected by the filters."""
+  if not filters:
+    filters = [EMPTY_SHARD_FILTER]
+  config = INCLUDE_FILTER_RE.sub('', config)
+  match = TEST_END_RE.search(config)
+  if not match:
+    raise ValueError('test config has no </test> element to add the include filters to')
+  options = ''.join('%s    <option name="include-filter" value="%s" />\n' %
+                    (match.group(1), saxutils.escape(f, {'"': '&quot;'})) for f in filters)
+  return config[:match.start()] + options + config[match.start():]
+
+
+def main():
+  """Program entry point."""
+  args = parse_args()
+
+  with open(args.config, 'r') as f:
+    config = f.read()
+
+  filters_by_class = {}
+  for path in read_lines(args.tests):
+    with open(path, 'r') as f:
+      name = test_class(path, f.read())
+    filters = filters_for_class(name, args.include_filters)
+    if filters:
+      filters_by_class[name] = filters
+
+  timings = parse_timings(read_lines(args.timings)) if args.timings else {}
+
+  shards = balance(list(filters_by_class), timings, len(args.outputs))
+  for output, shard in zip(args.outputs, shards):
+    filters = [f for test_class in shard for f in filters_by_class[test_class]]
+    with open(output, 'w') as f:
+      f.write(shard_config(config, filters))
+
+
+if __name__ == '__main__':
+  main()
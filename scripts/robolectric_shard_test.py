@@ -0,0 +1,134 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+
+"""Unit tests for robolectric_shard.py."""
+
+import unittest
+
+import robolectric_shard
+
+
+class BalanceTest(unittest.TestCase):
+  """Unit tests for balance function."""
+
+  def test_without_timings(self):
+    shards = robolectric_shard.balance(['a.A', 'a.B', 'a.C', 'a.D', 'a.E'], {}, 2)
+    self.assertEqual(shards, [['a.A', 'a.C', 'a.E'], ['a.B', 'a.D']])
+
+  def test_with_timings(self):
+    timings = {'a.A': 10, 'a.B': 4, 'a.C': 3, 'a.D': 2}
+    shards = robolectric_shard.balance(['a.A', 'a.B', 'a.C', 'a.D'], timings, 2)
+    self.assertEqual(shards, [['a.A'], ['a.B', 'a.C', 'a.D']])
+
+  def test_unknown_classes_use_average(self):
+    timings = {'a.A': 6, 'a.B': 2}
+    # a.C is assumed to take 4 seconds.
+    shards = robolectric_shard.balance(['a.A', 'a.B', 'a.C'], timings, 2)
+    self.assertEqual(shards, [['a.A'], ['a.B', 'a.C']])
+
+  def test_more_shards_than_classes(self):
+    shards = robolectric_shard.balance(['a.A'], {}, 3)
+    self.assertEqual(shards, [['a.A'], [], []])
+
+
+class FiltersForClassTest(unittest.TestCase):
+  """Unit tests for filters_for_class function."""
+
+  def test_no_filters(self):
+    self.assertEqual(robolectric_shard.filters_for_class('a.b.FooTest', []), ['a.b.FooTest'])
+
+  def test_filters(self):
+    filters = ['a.b.FooTest#testBar', 'a.c', 'a.b.BazTest']
+    self.assertEqual(robolectric_shard.filters_for_class('a.b.FooTest', filters),
+                     ['a.b.FooTest#testBar'])
+    self.assertEqual(robolectric_shard.filters_for_class('a.c.QuxTest', filters), ['a.c.QuxTest'])
+    self.assertEqual(robolectric_shard.filters_for_class('a.b.BazTest', filters), ['a.b.BazTest'])
+    self.assertEqual(robolectric_shard.filters_for_class('a.d.QuxTest', filters), [])
+
+
+class TestClassTest(unittest.TestCase):
+  """Unit tests for test_class function."""
+
+  def test_java(self):
+    source = ('/*\n'
+              ' * package in a comment\n'
+              ' */\n'
+              'package com.android.foo;\n'
+              '\n'
+              'public class FooTest {}\n')
+    self.assertEqual(robolectric_shard.test_class('tests/src/com/android/foo/FooTest.java', source),
+                     'com.android.foo.FooTest')
+
+  def test_kotlin(self):
+    self.assertEqual(robolectric_shard.test_class('BarTest.kt', 'package com.android.bar\n'),
+                     'com.android.bar.BarTest')
+
+  def test_no_package(self):
+    with self.assertRaises(ValueError):
+      robolectric_shard.test_class('FooTest.java', 'public class FooTest {}\n')
+
+
+class ParseTimingsTest(unittest.TestCase):
+  """Unit tests for parse_timings function."""
+
+  def test_parse(self):
+    self.assertEqual(robolectric_shard.parse_timings(['a.A 1.5', 'a.B,2']),
+                     {'a.A': 1.5, 'a.B': 2.0})
+
+  def test_invalid(self):
+    with self.assertRaises(ValueError):
+      robolectric_shard.parse_timings(['a.A'])
+
+
+class ShardConfigTest(unittest.TestCase):
+  """Unit tests for shard_config function."""
+
+  config = ('<configuration description="Runs Foo">\n'
+            '    <option name="include-filter" value="a.b.FooTest" />\n'
+            '    <option name="exclude-filter" value="a.b.BarTest" />\n'
+            '    <test class="com.android.tradefed.testtype.IsolatedHostTest" >\n'
+            '        <option name="jar" value="Foo.jar" />\n'
+            '    </test>\n'
+            '</configuration>\n')
+
+  def test_shard_config(self):
+    self.assertEqual(
+        robolectric_shard.shard_config(self.config, ['a.b.BazTest']),
+        '<configuration description="Runs Foo">\n'
+        '    <option name="exclude-filter" value="a.b.BarTest" />\n'
+        '    <test class="com.android.tradefed.testtype.IsolatedHostTest" >\n'
+        '        <option name="jar" value="Foo.jar" />\n'
+        '        <option name="include-filter" value="a.b.BazTest" />\n'
+        '    </test>\n'
+        '</configuration>\n')
+
+  def test_no_test_element(self):
+    with self.assertRaises(ValueError):
+      robolectric_shard.shard_config('<configuration>\n</configuration>\n', ['a.b.BazTest'])
+
+  def test_escape(self):
+    self.assertIn('<option name="include-filter" value="a.b.FooTest#test&lt;&amp;&quot;&gt;" />',
+                  robolectric_shard.shard_config(self.config, ['a.b.FooTest#test<&">']))
+
+  def test_empty_shard(self):
+    self.assertIn('<option name="include-filter" value="%s" />' %
+                  robolectric_shard.EMPTY_SHARD_FILTER,
+                  robolectric_shard.shard_config(self.config, []))
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
@@ -29,6 +29,7 @@
 #   --keep-symbols
 #   --keep-symbols-and-debug-frame
 #   --remove-build-id
+#   --split-debug-info=${file}
 
 set -o pipefail
 
@@ -43,6 +44,8 @@ Options:
         --keep-symbols                  Keep symbols in out-file
         --keep-symbols-and-debug-frame  Keep symbols and .debug_frame in out-file
         --remove-build-id               Remove the gnu build-id section in out-file
+        --split-debug-info=FILE         Write the debug info with compressed sections to FILE, and
+                                        add a gnu-debuglink section pointing to it to out-file
 EOF
     exit 1
 }
@@ -122,6 +125,11 @@ do_add_gnu_debuglink() {
     "${CLANG_BIN}/llvm-objcopy" --add-gnu-debuglink="${infile}" "${outfile}.tmp"
 }
 
+do_split_debug_info() {
+    "${CLANG_BIN}/llvm-objcopy" --only-keep-debug --compress-debug-sections=zlib "${infile}" "${debug_file}"
+    "${CLANG_BIN}/llvm-objcopy" --add-gnu-debuglink="${debug_file}" "${outfile}.tmp"
+}
+
 do_remove_build_id() {
     "${CLANG_BIN}/llvm-strip" --remove-section=.note.gnu.build-id "${outfile}.tmp" -o "${outfile}.tmp.no-build-id"
     rm -f "${outfile}.tmp"
@@ -141,6 +149,7 @@ while getopts $OPTSTRING opt; do
                 keep-symbols) keep_symbols=true ;;
                 keep-symbols-and-debug-frame) keep_symbols_and_debug_frame=true ;;
                 remove-build-id) remove_build_id=true ;;
+                split-debug-info=*) debug_file="${OPTARG#*=}" ;;
                 *) echo "Unknown option --${OPTARG}"; usage ;;
             esac;;
         ?) usage ;;
@@ -188,6 +197,11 @@ if [ ! -z "${add_gnu_debuglink}" -a ! -z "${keep_mini_debug_info}" ]; then
     usage
 fi
 
+if [ ! -z "${add_gnu_debuglink}" -a ! -z "${debug_file}" ]; then
+    echo "--add-gnu-debuglink cannot be used with --split-debug-info"
+    usage
+fi
+
 rm -f "${outfile}.tmp"
 
 if [ ! -z "${keep_symbols}" ]; then
@@ -206,6 +220,10 @@ if [ ! -z "${add_gnu_debuglink}" ]; then
     do_add_gnu_debuglink
 fi
 
+if [ ! -z "${debug_file}" ]; then
+    do_split_debug_info
+fi
+
 if [ ! -z "${remove_build_id}" ]; then
     do_remove_build_id
 fi
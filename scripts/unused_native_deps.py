@@ -0,0 +1,213 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Finds the shared_libs and static_libs of a native module that are not used.
+
+Has two sub commands:
+  analyze: inspects a linked binary or shared library. A shared library
+           dependency is unused if it is not in DT_NEEDED, or if none of the
+           symbols it exports is an undefined symbol of the output. A static
+           library dependency is unused if the linker extracted none of its
+           members, as recorded by lld --why-extract. The symbols of the output
+           can't tell, as version scripts and -fvisibility=hidden make the
+           symbols of the static libraries local. Static libraries are not
+           analyzed without the --why-extract file.
+  merge:   merges the reports of all the variants of all the modules. A
+           dependency of a module is removable if it is unused by every variant
+           that links against it.
+
+The merged report maps each module name to the removable entries of its
+shared_libs and static_libs properties, which bpfix -remove-unused-deps
+consumes to delete them, e.g.:
+  {"libfoo": {"shared_libs": ["libbar"], "static_libs": ["libbaz"]}}
+Unused dependencies may still provide headers, so the build should be checked
+after removing them.
+"""
+
+import argparse
+import json
+import os
+import re
+import subprocess
+import sys
+
+NEEDED_RE = re.compile(r'\(NEEDED\)\s+Shared library: \[(.+)\]')
+PROPERTIES = ('shared_libs', 'static_libs')
+
+
+def parse_symbols(output):
+  """Returns the (name, type) of each symbol listed by llvm-nm --format=posix."""
+  symbols = []
+  for line in output.splitlines():
+    fields = line.split()
+    # Skip the names of the members of archives.
+    if len(fields) < 2 or line.endswith(':'):
+      continue
+    symbols.append((fields[0], fields[1]))
+  return symbols
+
+
+def parse_extracted(output):
+  """Returns the archive members extracted by the link listed by lld --why-extract."""
+  extracted = set()
+  for line in output.splitlines()[1:]:
+    fields = line.split('\t')
+    if len(fields) == 3:
+      extracted.add(fields[1])
+  return extracted
+
+
+def global_defined(symbols):
+  """Returns the names of the global symbols defined in a list of (name, type)."""
+  return {name for name, kind in symbols if kind.isupper() and kind != 'U'}
+
+
+def undefined(symbols):
+  """Returns the names of the undefined symbols in a list of (name, type)."""
+  return {name for name, kind in symbols if kind in ('U', 'w', 'v')}
+
+
+def parse_needed(output):
+  """Returns the DT_NEEDED entries listed by llvm-readelf -d."""
+  return [m.group(1) for m in NEEDED_RE.finditer(output)]
+
+
+def find_unused(needed, binary_undefined, extracted, shared_libs, static_libs):
+  """Returns the unused shared and static library dependencies of a binary.
+
+  shared_libs maps each shared library dependency to the pair of its file name
+  and the symbols it exports, static_libs maps each static library dependency to
+  the path of its archive as passed to the linker. extracted lists the archive
+  members extracted by the link, as <archive>(<member>).
+  """
+  unused = {'shared_libs': [], 'static_libs': []}
+  for name, (filename, exports) in sorted(shared_libs.items()):
+    if filename not in needed or not exports & binary_undefined:
+      unused['shared_libs'].append(name)
+  for name, archive in sorted(static_libs.items()):
+    if not any(member.startswith(archive + '(') for member in extracted):
+      unused['static_libs'].append(name)
+  return unused
+
+
+def merge_reports(reports):
+  """Merges the reports of the variants of modules into the removable dependencies by module."""
+  analyzed = {}
+  used = {}
+  for report in reports:
+    module = report['module']
+    for prop in PROPERTIES:
+      for dep in report['analyzed'][prop]:
+        analyzed.setdefault((module, prop), set()).add(dep)
+        if dep not in report['unused'][prop]:
+          used.setdefault((module, prop), set()).add(dep)
+  merged = {}
+  for (module, prop), deps in sorted(analyzed.items()):
+    removable = sorted(deps - used.get((module, prop), set()))
+    if removable:
+      merged.setdefault(module, {})[prop] = removable
+  return merged
+
+
+def nm(clang_bin, path, *args):
+  return parse_symbols(subprocess.check_output(
+      [os.path.join(clang_bin, 'llvm-nm'), '--format=posix'] + list(args) + [path], text=True))
+
+
+def analyze(args):
+  """Analyzes a linked binary against its dependencies."""
+  clang_bin = os.environ.get('CLANG_BIN', '')
+  needed = parse_needed(subprocess.check_output(
+      [os.path.join(clang_bin, 'llvm-readelf'), '-d', '--wide', args.binary], text=True))
+  binary_undefined = undefined(nm(clang_bin, args.binary, '--dynamic', '--undefined-only'))
+
+  shared_libs = {}
+  for name, path in args.shared_lib:
+    exports = global_defined(nm(clang_bin, path, '--dynamic', '--defined-only'))
+    shared_libs[name] = (os.path.basename(path), exports)
+  static_libs = {}
+  extracted = set()
+  if args.why_extract:
+    static_libs = dict(args.static_lib)
+    with open(args.why_extract, 'r') as f:
+      extracted = parse_extracted(f.read())
+
+  return {
+      'module': args.module,
+      'analyzed': {
+          'shared_libs': sorted(shared_libs),
+          'static_libs': sorted(static_libs),
+      },
+      'unused': find_unused(needed, binary_undefined, extracted, shared_libs, static_libs),
+  }
+
+
+def parse_dep(value):
+  """Parses a <name>:<path> argument."""
+  name, sep, path = value.partition(':')
+  if not sep:
+    raise argparse.ArgumentTypeError('expected <name>:<path>, got %r' % value)
+  return name, path
+
+
+def parse_args(argv):
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser()
+  subparsers = parser.add_subparsers(dest='command', required=True)
+
+  analyze_parser = subparsers.add_parser('analyze', help='analyze a linked module.')
+  analyze_parser.add_argument('--module', required=True, help='name of the module.')
+  analyze_parser.add_argument('--binary', required=True, help='unstripped linked output.')
+  analyze_parser.add_argument('--shared-lib', action='append', default=[], type=parse_dep,
+                              help='<name>:<path> of a shared_libs dependency.')
+  analyze_parser.add_argument('--static-lib', action='append', default=[], type=parse_dep,
+                              help='<name>:<path> of a static_libs dependency.')
+  analyze_parser.add_argument('--why-extract',
+                              help='--why-extract output of the link, required to analyze the '
+                              'static_libs dependencies.')
+  analyze_parser.add_argument('--output', required=True, help='JSON report to write.')
+
+  merge_parser = subparsers.add_parser('merge', help='merge the reports of many modules.')
+  merge_parser.add_argument('--output', required=True, help='JSON report to write.')
+  merge_parser.add_argument('--reports', required=True,
+                            help='file listing the reports written by analyze.')
+
+  return parser.parse_args(argv)
+
+
+def write_json(path, report):
+  with open(path, 'w') as f:
+    json.dump(report, f, indent=2, sort_keys=True)
+    f.write('\n')
+
+
+def main(argv):
+  """Program entry point."""
+  args = parse_args(argv)
+  if args.command == 'analyze':
+    write_json(args.output, analyze(args))
+  elif args.command == 'merge':
+    reports = []
+    with open(args.reports, 'r') as f:
+      paths = f.read().split()
+    for path in paths:
+      with open(path, 'r') as f:
+        reports.append(json.load(f))
+    write_json(args.output, merge_reports(reports))
+
+
+if __name__ == '__main__':
+  main(sys.argv[1:])
@@ -0,0 +1,101 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for unused_native_deps.py."""
+
+import unittest
+
+import unused_native_deps
+
+NM_ARCHIVE = """\
+libbaz.a[baz.o]:
+baz_init T 0 10
+baz_helper t 10 4
+baz_table D 0 8
+"""
+
+WHY_EXTRACT = """\
+reference\textracted\tsymbol
+main.o\tout/libbaz.a(baz.o)\tbaz_init
+out/libbaz.a(baz.o)\tout/libbaz_helpers.a(helpers.o)\tbaz_helper
+"""
+
+READELF = """\
+Dynamic section at offset 0x1000 contains 3 entries:
+  Tag                Type     Name/Value
+  0x0000000000000001 (NEEDED) Shared library: [libbar.so]
+  0x0000000000000001 (NEEDED) Shared library: [libc.so]
+"""
+
+
+class ParseTest(unittest.TestCase):
+  """Unit tests for the parsing functions."""
+
+  def test_parse_symbols(self):
+    symbols = unused_native_deps.parse_symbols(NM_ARCHIVE + 'malloc U\nweak w\n')
+    self.assertEqual(unused_native_deps.global_defined(symbols), {'baz_init', 'baz_table'})
+    self.assertEqual(unused_native_deps.undefined(symbols), {'malloc', 'weak'})
+
+  def test_parse_extracted(self):
+    self.assertEqual(unused_native_deps.parse_extracted(WHY_EXTRACT),
+                     {'out/libbaz.a(baz.o)', 'out/libbaz_helpers.a(helpers.o)'})
+
+  def test_parse_needed(self):
+    self.assertEqual(unused_native_deps.parse_needed(READELF), ['libbar.so', 'libc.so'])
+
+
+class FindUnusedTest(unittest.TestCase):
+  """Unit tests for find_unused function."""
+
+  def test_find_unused(self):
+    unused = unused_native_deps.find_unused(
+        needed=['libbar.so', 'libqux.so'],
+        binary_undefined={'bar', 'malloc'},
+        extracted={'out/libbaz.a(baz.o)', 'out/libbaz_helpers.a(helpers.o)'},
+        shared_libs={
+            'libbar': ('libbar.so', {'bar'}),
+            'libqux': ('libqux.so', {'qux'}),
+            'libquux': ('libquux.so', {'malloc'}),
+        },
+        static_libs={
+            'libbaz': 'out/libbaz.a',
+            'libunused': 'out/libunused.a',
+        })
+    self.assertEqual(unused, {'shared_libs': ['libquux', 'libqux'], 'static_libs': ['libunused']})
+
+
+class MergeReportsTest(unittest.TestCase):
+  """Unit tests for merge_reports function."""
+
+  def test_merge(self):
+    def report(module, shared_libs, unused):
+      return {
+          'module': module,
+          'analyzed': {'shared_libs': shared_libs, 'static_libs': []},
+          'unused': {'shared_libs': unused, 'static_libs': []},
+      }
+
+    merged = unused_native_deps.merge_reports([
+        report('libfoo', ['libbar', 'libbaz'], ['libbar', 'libbaz']),
+        # libbaz is used by the other variant, and libqux is only linked by this one.
+        report('libfoo', ['libbaz', 'libqux'], ['libqux']),
+        report('libused', ['libbar'], []),
+    ])
+    self.assertEqual(merged, {'libfoo': {'shared_libs': ['libbar', 'libqux']}})
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
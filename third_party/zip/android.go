@@ -16,12 +16,82 @@ package zip
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"strings"
 )
 
 const DataDescriptorFlag = 0x8
 const ExtendedTimeStampTag = 0x5455
 
+// AlignmentExtraId is the extra field used by zipalign and apksigner to pad the local file header
+// of a stored entry so that its data starts at an aligned offset.  It contains the alignment as a
+// uint16 followed by zero padding.
+const AlignmentExtraId = 0xd935
+
+// alignmentExtraLen is the size of an alignment extra field without padding.
+const alignmentExtraLen = 6
+
+// MaxStoredAlignment is the largest alignment supported by SetStoredAlignment, the same as the
+// limit of zipalign.  The padding of a larger alignment may not fit in the alignment extra field.
+const MaxStoredAlignment = 32768
+
+// ValidateStoredAlignment returns an error if alignment is not 0 or a power of two no larger than
+// MaxStoredAlignment.
+func ValidateStoredAlignment(alignment uint) error {
+	if alignment > MaxStoredAlignment || alignment&(alignment-1) != 0 {
+		return fmt.Errorf("alignment %d is not a power of two of at most %d", alignment, MaxStoredAlignment)
+	}
+	return nil
+}
+
+// SetStoredAlignment causes the data of every stored file written after the call to start at an
+// offset from the beginning of the zip file that is a multiple of alignment, by padding an
+// alignment extra field in the local file header.  Page aligned uncompressed native libraries can
+// be loaded directly from an APK.  An alignment of 0 or 1 disables alignment.  The alignment must
+// be a power of two no larger than MaxStoredAlignment.
+func (w *Writer) SetStoredAlignment(alignment uint16) error {
+	if err := ValidateStoredAlignment(uint(alignment)); err != nil {
+		return err
+	}
+	w.storedAlignment = alignment
+	return nil
+}
+
+// writeAlignedHeader writes the local file header for fh, adding an alignment extra field if fh is
+// a stored file and alignment was requested with SetStoredAlignment.
+func (w *Writer) writeAlignedHeader(fh *FileHeader) error {
+	if w.storedAlignment <= 1 || fh.Method != Store || strings.HasSuffix(fh.Name, "/") {
+		return writeHeader(w.cw, fh)
+	}
+
+	// writeHeader appends a zip64 extra to the local file header when the sizes don't fit in
+	// the 32-bit fields, which moves the data too.
+	extraLen := len(fh.Extra)
+	if fh.Flags&DataDescriptorFlag == 0 &&
+		(fh.CompressedSize64 > uint32max || fh.UncompressedSize64 > uint32max) {
+		extraLen += 20
+	}
+
+	alignment := int64(w.storedAlignment)
+	dataOffset := w.cw.count + fileHeaderLen + int64(len(fh.Name)) + int64(extraLen) + alignmentExtraLen
+	padding := (alignment - dataOffset%alignment) % alignment
+
+	buf := make([]byte, alignmentExtraLen+padding)
+	b := writeBuf(buf)
+	b.uint16(AlignmentExtraId)
+	b.uint16(uint16(2 + padding))
+	b.uint16(w.storedAlignment)
+
+	// The alignment extra is only written to the local file header, the central directory entry
+	// written by Close keeps the original extras.
+	extra := fh.Extra
+	defer func() { fh.Extra = extra }()
+	fh.Extra = append(extra[:len(extra):len(extra)], buf...)
+
+	return writeHeader(w.cw, fh)
+}
+
 func (w *Writer) CopyFrom(orig *File, newName string) error {
 	if w.last != nil && !w.last.closed {
 		if err := w.last.close(); err != nil {
@@ -53,7 +123,7 @@ func (w *Writer) CopyFrom(orig *File, newName string) error {
 		fh.UncompressedSize = uint32(fh.UncompressedSize64)
 	}
 
-	if err := writeHeader(w.cw, fh); err != nil {
+	if err := w.writeAlignedHeader(fh); err != nil {
 		return err
 	}
 	dataOffset, err := orig.DataOffset()
@@ -94,6 +164,8 @@ func (w *Writer) CopyFrom(orig *File, newName string) error {
 // File Header.
 // Extended-Timestamp extra(LFH): <tag-size-flag-modtime-actime-changetime>
 // Extended-Timestamp extra(CDH): <tag-size-flag-modtime>
+//
+// The alignment extra is only valid for the offset the entry had in the original zip file.
 func stripExtras(input []byte) []byte {
 	ret := []byte{}
 
@@ -104,7 +176,7 @@ func stripExtras(input []byte) []byte {
 		if int(size) > len(r) {
 			break
 		}
-		if tag != zip64ExtraId && tag != ExtendedTimeStampTag {
+		if tag != zip64ExtraId && tag != ExtendedTimeStampTag && tag != AlignmentExtraId {
 			ret = append(ret, input[:4+size]...)
 		}
 		input = input[4+size:]
@@ -158,7 +230,7 @@ func (w *Writer) CreateCompressedHeader(fh *FileHeader) (io.WriteCloser, error)
 	w.dir = append(w.dir, h)
 	fw.header = h
 
-	if err := writeHeader(w.cw, fh); err != nil {
+	if err := w.writeAlignedHeader(fh); err != nil {
 		return nil, err
 	}
 
@@ -16,6 +16,7 @@ package zip
 
 import (
 	"bytes"
+	"io"
 	"testing"
 )
 
@@ -64,6 +65,11 @@ var stripZip64Testcases = []struct {
 		in:   []byte{1, 0, 8, 0, 1, 2, 3, 4, 5, 6, 7, 8, 85, 84, 5, 0, 1, 1, 2, 3, 4, 2, 0, 0, 0},
 		out:  []byte{2, 0, 0, 0},
 	},
+	{
+		name: "alignment extra and valid non-zip64 extra",
+		in:   []byte{0x35, 0xd9, 4, 0, 0, 16, 0, 0, 2, 0, 0, 0},
+		out:  []byte{2, 0, 0, 0},
+	},
 }
 
 func TestStripZip64Extras(t *testing.T) {
@@ -141,6 +147,123 @@ func TestCopyFromZip64(t *testing.T) {
 	}
 }
 
+func TestStoredAlignment(t *testing.T) {
+	const alignment = 4096
+
+	fromZipBytes := &bytes.Buffer{}
+	fromZip := NewWriter(fromZipBytes)
+	for _, name := range []string{"a", "b"} {
+		w, err := fromZip.CreateHeaderAndroid(&FileHeader{
+			Name:               name,
+			Method:             Store,
+			UncompressedSize64: 3,
+			CompressedSize64:   3,
+		})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if _, err := w.Write([]byte("foo")); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := fromZip.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	fromZipReader, err := NewReader(bytes.NewReader(fromZipBytes.Bytes()), int64(fromZipBytes.Len()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+
+	toZipBytes := &bytes.Buffer{}
+	toZip := NewWriter(toZipBytes)
+	toZip.SetStoredAlignment(alignment)
+
+	// A deflated entry that is not aligned.
+	w, err := toZip.CreateHeaderAndroid(&FileHeader{Name: "deflated", Method: Deflate})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := w.Write([]byte("bar")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	// A stored entry written with CreateHeaderAndroid.
+	w, err = toZip.CreateHeaderAndroid(&FileHeader{
+		Name:               "stored",
+		Method:             Store,
+		UncompressedSize64: 3,
+		CompressedSize64:   3,
+		CRC32:              0x8c736521,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := w.Write([]byte("foo")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	// Stored entries copied with CopyFrom.
+	for _, f := range fromZipReader.File {
+		if err := toZip.CopyFrom(f, "copied_"+f.Name); err != nil {
+			t.Fatalf("CopyFrom: %v", err)
+		}
+	}
+	if err := toZip.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	toZipReader, err := NewReader(bytes.NewReader(toZipBytes.Bytes()), int64(toZipBytes.Len()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if len(toZipReader.File) != 4 {
+		t.Fatalf("Expected 4 files in toZip, got %d", len(toZipReader.File))
+	}
+
+	for _, f := range toZipReader.File {
+		offset, err := f.DataOffset()
+		if err != nil {
+			t.Fatalf("DataOffset: %v", err)
+		}
+		if f.Method == Store && offset%alignment != 0 {
+			t.Errorf("Expected %s to be aligned to %d, got offset %d", f.Name, alignment, offset)
+		}
+		for _, tag := range extraTags(f.Extra) {
+			if tag == AlignmentExtraId {
+				t.Errorf("Expected no alignment extra in the central directory entry for %s", f.Name)
+			}
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("Read %s: %v", f.Name, err)
+		}
+		if f.Name != "deflated" && string(data) != "foo" {
+			t.Errorf("Expected %s to contain %q, got %q", f.Name, "foo", data)
+		}
+	}
+}
+
+func extraTags(extra []byte) []uint16 {
+	var tags []uint16
+	for len(extra) >= 4 {
+		r := readBuf(extra)
+		tags = append(tags, r.uint16())
+		size := r.uint16()
+		if int(size) > len(r) {
+			break
+		}
+		extra = extra[4+size:]
+	}
+	return tags
+}
+
 // Test for b/187485108: zip64 output can't be read by p7zip 16.02.
 func TestZip64P7ZipRecords(t *testing.T) {
 	if testing.Short() {
@@ -220,3 +343,25 @@ func TestZip64P7ZipRecords(t *testing.T) {
 		t.Errorf("wanted directoryOffset > %d, got %d", w, g)
 	}
 }
+
+func TestStoredAlignmentBound(t *testing.T) {
+	for _, alignment := range []uint{0, 1, 2, 4096, MaxStoredAlignment} {
+		if err := ValidateStoredAlignment(alignment); err != nil {
+			t.Errorf("ValidateStoredAlignment(%d): unexpected error %v", alignment, err)
+		}
+	}
+	// 65534 would need 65534 bytes of padding, which overflows the size of the alignment extra.
+	for _, alignment := range []uint{3, 4095, 65534, 65535, 2 * MaxStoredAlignment} {
+		if err := ValidateStoredAlignment(alignment); err == nil {
+			t.Errorf("ValidateStoredAlignment(%d): expected an error", alignment)
+		}
+	}
+
+	w := NewWriter(&bytes.Buffer{})
+	if err := w.SetStoredAlignment(65535); err == nil {
+		t.Errorf("SetStoredAlignment(65535): expected an error")
+	}
+	if w.storedAlignment != 0 {
+		t.Errorf("SetStoredAlignment(65535): expected alignment to stay disabled, got %d", w.storedAlignment)
+	}
+}
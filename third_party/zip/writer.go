@@ -22,6 +22,10 @@ type Writer struct {
 	last        *fileWriter
 	closed      bool
 	compressors map[uint16]Compressor
+
+	// BEGIN ANDROID CHANGE add alignment of stored entries
+	storedAlignment uint16
+	// END ANDROID CHANGE
 }
 
 type header struct {
@@ -265,9 +269,11 @@ func (w *Writer) createHeaderImpl(fh *FileHeader) (io.Writer, error) {
 	w.dir = append(w.dir, h)
 	fw.header = h
 
-	if err := writeHeader(w.cw, fh); err != nil {
+	// BEGIN ANDROID CHANGE add alignment of stored entries
+	if err := w.writeAlignedHeader(fh); err != nil {
 		return nil, err
 	}
+	// END ANDROID CHANGE
 
 	w.last = fw
 	return fw, nil
@@ -164,6 +164,7 @@ func main() {
 	cpuProfile := flags.String("cpuprofile", "", "write cpu profile to file")
 	traceFile := flags.String("trace", "", "write trace to file")
 	sha256Checksum := flags.Bool("sha256", false, "add a zip header to each file containing its SHA256 digest")
+	align := flags.Uint("align", 0, "align the data of files stored without compression to a multiple of this many bytes (e.g. 4096)")
 
 	flags.Var(&rootPrefix{}, "P", "path prefix within the zip at which to place files")
 	flags.Var(&listFiles{}, "l", "file containing list of files to zip")
@@ -181,6 +182,11 @@ func main() {
 		flags.Usage()
 	}
 
+	if err := zip.ValidateStoredAlignment(*align); err != nil {
+		fmt.Fprintf(os.Stderr, "-align: %s\n", err)
+		flags.Usage()
+	}
+
 	if *cpuProfile != "" {
 		f, err := os.Create(*cpuProfile)
 		if err != nil {
@@ -226,6 +232,7 @@ func main() {
 		StoreSymlinks:            *symlinks,
 		IgnoreMissingFiles:       *ignoreMissingFiles,
 		Sha256Checksum:           *sha256Checksum,
+		StoredAlignment:          uint16(*align),
 	})
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err.Error())
@@ -244,6 +244,8 @@ type ZipWriter struct {
 	fs     pathtools.FileSystem
 
 	sha256Checksum bool
+
+	storedAlignment uint16
 }
 
 type zipEntry struct {
@@ -272,10 +274,21 @@ type ZipArgs struct {
 	IgnoreMissingFiles       bool
 	Sha256Checksum           bool
 
+	// StoredAlignment aligns the data of files stored without compression to a multiple of
+	// this many bytes, for example 4096 for native libraries that will be loaded directly from
+	// an APK.  0 disables alignment, otherwise it must pass ValidateStoredAlignment.
+	StoredAlignment uint16
+
 	Stderr     io.Writer
 	Filesystem pathtools.FileSystem
 }
 
+// ValidateStoredAlignment returns an error if alignment is not 0 or a power of two no larger than
+// the largest alignment zipalign supports.
+func ValidateStoredAlignment(alignment uint) error {
+	return zip.ValidateStoredAlignment(alignment)
+}
+
 func zipTo(args ZipArgs, w io.Writer) error {
 	if args.EmulateJar {
 		args.AddDirectoryEntriesToZip = true
@@ -295,6 +308,7 @@ func zipTo(args ZipArgs, w io.Writer) error {
 		stderr:             args.Stderr,
 		fs:                 args.Filesystem,
 		sha256Checksum:     args.Sha256Checksum,
+		storedAlignment:    args.StoredAlignment,
 	}
 
 	if z.fs == nil {
@@ -488,6 +502,11 @@ func (z *ZipWriter) write(f io.Writer, pathMappings []pathMapping, manifest stri
 		return errors.New("must specify --jar when specifying a manifest via -m")
 	}
 
+	zipw := zip.NewWriter(f)
+	if err := zipw.SetStoredAlignment(z.storedAlignment); err != nil {
+		return err
+	}
+
 	if emulateJar {
 		// manifest may be empty, in which case addManifest will fill in a default
 		pathMappings = append(pathMappings, pathMapping{jar.ManifestFile, manifest, zip.Deflate})
@@ -512,8 +531,6 @@ func (z *ZipWriter) write(f io.Writer, pathMappings []pathMapping, manifest stri
 		}
 	}()
 
-	zipw := zip.NewWriter(f)
-
 	var currentWriteOpChan chan *zipEntry
 	var currentWriter io.WriteCloser
 	var currentReaders chan chan io.Reader
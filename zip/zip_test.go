@@ -139,6 +139,7 @@ func TestZip(t *testing.T) {
 		storeSymlinks      bool
 		ignoreMissingFiles bool
 		sha256Checksum     bool
+		storedAlignment    uint16
 
 		files []zip.FileHeader
 		err   error
@@ -412,6 +413,22 @@ func TestZip(t *testing.T) {
 				fh("a/a/b", fileB, zip.Deflate),
 			},
 		},
+		{
+			name: "aligned non deflated files",
+			args: fileArgsBuilder().
+				File("a/a/a").
+				File("a/a/b").
+				File("c"),
+			compressionLevel: 9,
+			nonDeflatedFiles: map[string]bool{"a/a/a": true, "c": true},
+			storedAlignment:  4096,
+
+			files: []zip.FileHeader{
+				fh("a/a/a", fileA, zip.Store),
+				fh("a/a/b", fileB, zip.Deflate),
+				fh("c", fileC, zip.Store),
+			},
+		},
 		{
 			name: "ignore missing files",
 			args: fileArgsBuilder().
@@ -508,6 +525,7 @@ func TestZip(t *testing.T) {
 			args.StoreSymlinks = test.storeSymlinks
 			args.IgnoreMissingFiles = test.ignoreMissingFiles
 			args.Sha256Checksum = test.sha256Checksum
+			args.StoredAlignment = test.storedAlignment
 			args.Filesystem = mockFs
 			args.Stderr = &bytes.Buffer{}
 
@@ -563,6 +581,17 @@ func TestZip(t *testing.T) {
 					t.Errorf("incorrect crc for %s, want %x got %x", f.Name, f.CRC32, crc)
 				}
 
+				if test.storedAlignment != 0 && f.Method == zip.Store {
+					offset, err := f.DataOffset()
+					if err != nil {
+						t.Fatalf("error getting data offset of %s: %s", f.Name, err)
+					}
+					if offset%int64(test.storedAlignment) != 0 {
+						t.Errorf("incorrect alignment for %s, want multiple of %d got offset %d",
+							f.Name, test.storedAlignment, offset)
+					}
+				}
+
 				files = append(files, f.FileHeader)
 			}
 